@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/sourcecd/gofermart/internal/accrualstub"
+	"github.com/sourcecd/gofermart/internal/logging"
+)
+
+const serverShutdownTime = 10
+
+type config struct {
+	serverAddr string
+	step       time.Duration
+	rpm        int
+}
+
+func loadConfiguration(cfg *config) {
+	flag.StringVar(&cfg.serverAddr, "a", "localhost:8081", "Server bind addres and port")
+	flag.DurationVar(&cfg.step, "step", 5*time.Second, "duration of each order status before moving forward")
+	flag.IntVar(&cfg.rpm, "rpm", 0, "max order requests per minute, 0 is unlimited")
+	flag.Parse()
+
+	if a := os.Getenv("RUN_ADDRESS"); a != "" {
+		if _, _, err := net.SplitHostPort(a); err != nil {
+			log.Fatal("wrong server listen address")
+		}
+		cfg.serverAddr = a
+	}
+}
+
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	var cfg config
+	loadConfiguration(&cfg)
+
+	srv := http.Server{
+		Addr:    cfg.serverAddr,
+		Handler: accrualstub.NewServer(cfg.step, cfg.rpm).Router(),
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTime*time.Second)
+		defer cancel()
+
+		srv.Shutdown(ctx)
+	}()
+
+	logging.Slog.Info("Starting accrual stub on", slog.String("address", cfg.serverAddr))
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	logging.Slog.Info("Server successful shutdown")
+}
@@ -0,0 +1,172 @@
+// Package accrualtest provides a scriptable fake accrual system for tests.
+package accrualtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sourcecd/gofermart/internal/models"
+)
+
+// Response is one scripted answer of GET /api/orders/{number}.
+type Response struct {
+	StatusCode int
+	RetryAfter string
+	Accrual    *models.Accrual
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	responses   map[string][]Response
+	requests    map[string]int
+	orders      []models.AccrualOrder
+	goods       []models.AccrualGoods
+	orderStatus int
+	goodsStatus int
+}
+
+// NewServer starts the fake, by default every order is unknown (204)
+// and every registration succeeds.
+func NewServer() *Server {
+	s := &Server{
+		responses:   make(map[string][]Response),
+		requests:    make(map[string]int),
+		orderStatus: http.StatusAccepted,
+		goodsStatus: http.StatusOK,
+	}
+	mux := chi.NewRouter()
+	mux.Get("/api/orders/{number}", s.getOrder)
+	mux.Post("/api/orders", s.registerOrder)
+	mux.Post("/api/goods", s.registerGoods)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Status is a 200 answer with the given accrual status.
+func Status(number string, status models.OrderStatus) Response {
+	return Response{
+		StatusCode: http.StatusOK,
+		Accrual: &models.Accrual{
+			Order:  number,
+			Status: status,
+		},
+	}
+}
+
+// Processed is a 200 PROCESSED answer with accrual.
+func Processed(number string, accrual float64) Response {
+	resp := Status(number, models.StatusProcessed)
+	resp.Accrual.Accrual = &accrual
+	return resp
+}
+
+// TooManyRequests is a 429 answer with Retry-After header.
+func TooManyRequests(retryAfter string) Response {
+	return Response{
+		StatusCode: http.StatusTooManyRequests,
+		RetryAfter: retryAfter,
+	}
+}
+
+// SetOrderResponses scripts answers for the order, they are returned in turn
+// and the last one repeats.
+func (s *Server) SetOrderResponses(number string, resp ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[number] = resp
+}
+
+func (s *Server) SetRegisterOrderStatus(code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.orderStatus = code
+}
+
+func (s *Server) SetRegisterGoodsStatus(code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.goodsStatus = code
+}
+
+// Requests returns how many times the order was requested.
+func (s *Server) Requests(number string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[number]
+}
+
+func (s *Server) RegisteredOrders() []models.AccrualOrder {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.AccrualOrder(nil), s.orders...)
+}
+
+func (s *Server) RegisteredGoods() []models.AccrualGoods {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.AccrualGoods(nil), s.goods...)
+}
+
+func (s *Server) getOrder(w http.ResponseWriter, r *http.Request) {
+	number := chi.URLParam(r, "number")
+
+	s.mu.Lock()
+	s.requests[number]++
+	queue := s.responses[number]
+	if len(queue) == 0 {
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	resp := queue[0]
+	if len(queue) > 1 {
+		s.responses[number] = queue[1:]
+	}
+	s.mu.Unlock()
+
+	if resp.RetryAfter != "" {
+		w.Header().Set("Retry-After", resp.RetryAfter)
+	}
+	if resp.Accrual == nil {
+		w.WriteHeader(resp.StatusCode)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.StatusCode)
+	json.NewEncoder(w).Encode(resp.Accrual)
+}
+
+func (s *Server) registerOrder(w http.ResponseWriter, r *http.Request) {
+	var order models.AccrualOrder
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	code := s.orderStatus
+	if code == http.StatusAccepted {
+		s.orders = append(s.orders, order)
+	}
+	s.mu.Unlock()
+	w.WriteHeader(code)
+}
+
+func (s *Server) registerGoods(w http.ResponseWriter, r *http.Request) {
+	var goods models.AccrualGoods
+	if err := json.NewDecoder(r.Body).Decode(&goods); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	code := s.goodsStatus
+	if code == http.StatusOK {
+		s.goods = append(s.goods, goods)
+	}
+	s.mu.Unlock()
+	w.WriteHeader(code)
+}
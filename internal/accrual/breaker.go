@@ -0,0 +1,145 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// Breaker opens after failureThreshold consecutive failures, rejects calls
+// for openTimeout, then lets calls through half-open and closes again after
+// halfOpenSuccesses successes in a row. Any failure while half-open reopens it.
+type Breaker struct {
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	successes int
+	openedAt  time.Time
+
+	failureThreshold  int
+	openTimeout       time.Duration
+	halfOpenSuccesses int
+
+	now func() time.Time
+}
+
+func NewBreaker(failureThreshold int, openTimeout time.Duration, halfOpenSuccesses int) *Breaker {
+	return &Breaker{
+		failureThreshold:  max(failureThreshold, 1),
+		openTimeout:       openTimeout,
+		halfOpenSuccesses: max(halfOpenSuccesses, 1),
+		now:               time.Now,
+	}
+}
+
+func (b *Breaker) setState(state BreakerState) {
+	if b.state == state {
+		return
+	}
+	slog.Warn("accrual circuit breaker", slog.String("from", b.state.String()), slog.String("to", state.String()))
+	b.state = state
+	b.failures = 0
+	b.successes = 0
+	if state == BreakerOpen {
+		b.openedAt = b.now()
+	}
+}
+
+// refresh moves open breaker to half-open once openTimeout passed.
+func (b *Breaker) refresh() {
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.openTimeout {
+		b.setState(BreakerHalfOpen)
+	}
+}
+
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh()
+	return b.state
+}
+
+// Allow returns ErrAccrualCircuitOpen while the breaker is open.
+func (b *Breaker) Allow() error {
+	if b.State() == BreakerOpen {
+		return prjerrors.ErrAccrualCircuitOpen
+	}
+	return nil
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh()
+	switch b.state {
+	case BreakerClosed:
+		b.failures = 0
+	case BreakerHalfOpen:
+		b.successes++
+		if b.successes >= b.halfOpenSuccesses {
+			b.setState(BreakerClosed)
+		}
+	}
+}
+
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh()
+	switch b.state {
+	case BreakerClosed:
+		b.failures++
+		if b.failures >= b.failureThreshold {
+			b.setState(BreakerOpen)
+		}
+	case BreakerHalfOpen:
+		b.setState(BreakerOpen)
+	}
+}
+
+// isFailure tells whether the error means the accrual system is unhealthy,
+// answers like 204, 400, 409 or 429 come from a working service.
+func isFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch {
+	case errors.Is(err, prjerrors.ErrAccrualNotRegistered),
+		errors.Is(err, prjerrors.ErrAccrualTooManyRequests),
+		errors.Is(err, prjerrors.ErrAccrualBadRequest),
+		errors.Is(err, prjerrors.ErrAccrualAlreadyExists),
+		errors.Is(err, context.Canceled):
+		return false
+	}
+	return true
+}
+
+func (b *Breaker) record(err error) {
+	if isFailure(err) {
+		b.Failure()
+		return
+	}
+	b.Success()
+}
@@ -0,0 +1,72 @@
+package accrual
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sourcecd/gofermart/internal/accrual/accrualtest"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const openTimeout = 30 * time.Second
+
+func TestBreakerStates(t *testing.T) {
+	now := time.Now()
+	b := NewBreaker(2, openTimeout, 2)
+	b.now = func() time.Time { return now }
+
+	assert.Equal(t, BreakerClosed, b.State())
+	b.Failure()
+	b.Success()
+	b.Failure()
+	assert.Equal(t, BreakerClosed, b.State(), "success resets failures")
+
+	b.Failure()
+	assert.Equal(t, BreakerOpen, b.State())
+	require.ErrorIs(t, b.Allow(), prjerrors.ErrAccrualCircuitOpen)
+
+	now = now.Add(openTimeout)
+	assert.Equal(t, BreakerHalfOpen, b.State())
+	require.NoError(t, b.Allow())
+
+	b.Failure()
+	assert.Equal(t, BreakerOpen, b.State(), "failure while half-open reopens")
+
+	now = now.Add(openTimeout)
+	b.Success()
+	assert.Equal(t, BreakerHalfOpen, b.State())
+	b.Success()
+	assert.Equal(t, BreakerClosed, b.State())
+}
+
+func TestEndpointBreaker(t *testing.T) {
+	srv := accrualtest.NewServer()
+	defer srv.Close()
+	srv.SetOrderResponses(orderNum, accrualtest.Response{StatusCode: http.StatusInternalServerError})
+	srv.SetOrderResponses("9278923470", accrualtest.TooManyRequests("1"))
+
+	b := NewBreaker(2, openTimeout, 1)
+	cl := NewFailoverClient(NewEndpoint(srv.URL, NewHTTPClient(srv.URL, timeout), b))
+	ctx := context.Background()
+
+	// throttling and unknown orders are not failures
+	_, err := cl.GetOrder(ctx, "9278923470")
+	require.ErrorIs(t, err, prjerrors.ErrAccrualTooManyRequests)
+	_, err = cl.GetOrder(ctx, "79927398713")
+	require.ErrorIs(t, err, prjerrors.ErrAccrualNotRegistered)
+	assert.Equal(t, BreakerClosed, b.State())
+
+	for i := 0; i < 2; i++ {
+		_, err = cl.GetOrder(ctx, orderNum)
+		require.ErrorIs(t, err, prjerrors.ErrAccrualInternal)
+	}
+	assert.Equal(t, BreakerOpen, b.State())
+
+	_, err = cl.GetOrder(ctx, orderNum)
+	require.ErrorIs(t, err, prjerrors.ErrAccrualCircuitOpen)
+	assert.Equal(t, 2, srv.Requests(orderNum), "open breaker does not call accrual system")
+}
@@ -0,0 +1,119 @@
+package accrual
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+const defaultRetryAfter = 60 * time.Second
+
+// Client is the accrual system API as seen by gophermart.
+type Client interface {
+	GetOrder(ctx context.Context, number string) (*models.Accrual, error)
+	RegisterOrder(ctx context.Context, order *models.AccrualOrder) error
+	RegisterGoods(ctx context.Context, goods *models.AccrualGoods) error
+}
+
+// TooManyRequestsError is returned on 429 and carries the Retry-After value.
+type TooManyRequestsError struct {
+	RetryAfter time.Duration
+}
+
+func (e *TooManyRequestsError) Error() string {
+	return fmt.Sprintf("%s: retry after %s", prjerrors.ErrAccrualTooManyRequests, e.RetryAfter)
+}
+
+func (e *TooManyRequestsError) Unwrap() error {
+	return prjerrors.ErrAccrualTooManyRequests
+}
+
+type HTTPClient struct {
+	cl *resty.Client
+}
+
+func NewHTTPClient(baseURL string, timeout time.Duration) *HTTPClient {
+	return &HTTPClient{
+		cl: resty.New().SetBaseURL(baseURL).SetTimeout(timeout),
+	}
+}
+
+func parseRetryAfter(hdr string) time.Duration {
+	i, err := strconv.Atoi(hdr)
+	if err != nil || i < 0 {
+		return defaultRetryAfter
+	}
+	return time.Duration(i) * time.Second
+}
+
+func statusError(resp *resty.Response) error {
+	switch resp.StatusCode() {
+	case http.StatusBadRequest:
+		return prjerrors.ErrAccrualBadRequest
+	case http.StatusConflict:
+		return prjerrors.ErrAccrualAlreadyExists
+	case http.StatusTooManyRequests:
+		return &TooManyRequestsError{RetryAfter: parseRetryAfter(resp.Header().Get("Retry-After"))}
+	case http.StatusInternalServerError:
+		return prjerrors.ErrAccrualInternal
+	}
+	return fmt.Errorf("%w: %d", prjerrors.ErrAccrualUnexpectedStatus, resp.StatusCode())
+}
+
+func (c *HTTPClient) GetOrder(ctx context.Context, number string) (*models.Accrual, error) {
+	resp, err := c.cl.R().
+		SetContext(ctx).
+		SetPathParam("number", number).
+		Get("/api/orders/{number}")
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode() {
+	case http.StatusOK:
+		var order models.Accrual
+		if err := json.Unmarshal(resp.Body(), &order); err != nil {
+			return nil, err
+		}
+		return &order, nil
+	case http.StatusNoContent:
+		return nil, prjerrors.ErrAccrualNotRegistered
+	}
+	return nil, statusError(resp)
+}
+
+func (c *HTTPClient) RegisterOrder(ctx context.Context, order *models.AccrualOrder) error {
+	resp, err := c.cl.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(order).
+		Post("/api/orders")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() == http.StatusAccepted {
+		return nil
+	}
+	return statusError(resp)
+}
+
+func (c *HTTPClient) RegisterGoods(ctx context.Context, goods *models.AccrualGoods) error {
+	resp, err := c.cl.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(goods).
+		Post("/api/goods")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() == http.StatusOK {
+		return nil
+	}
+	return statusError(resp)
+}
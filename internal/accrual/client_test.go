@@ -0,0 +1,140 @@
+package accrual
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sourcecd/gofermart/internal/accrual/accrualtest"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	orderNum = "12345678903"
+	timeout  = 5 * time.Second
+)
+
+func TestGetOrder(t *testing.T) {
+	testCases := []struct {
+		name       string
+		responses  []accrualtest.Response
+		expErr     error
+		expStatus  models.OrderStatus
+		expAccrual float64
+	}{
+		{
+			name:      "notRegistered",
+			responses: nil,
+			expErr:    prjerrors.ErrAccrualNotRegistered,
+		},
+		{
+			name:      "registered",
+			responses: []accrualtest.Response{accrualtest.Status(orderNum, models.StatusRegistered)},
+			expStatus: models.StatusRegistered,
+		},
+		{
+			name:      "processing",
+			responses: []accrualtest.Response{accrualtest.Status(orderNum, models.StatusProcessing)},
+			expStatus: models.StatusProcessing,
+		},
+		{
+			name:      "invalid",
+			responses: []accrualtest.Response{accrualtest.Status(orderNum, models.StatusInvalid)},
+			expStatus: models.StatusInvalid,
+		},
+		{
+			name:       "processed",
+			responses:  []accrualtest.Response{accrualtest.Processed(orderNum, 500)},
+			expStatus:  models.StatusProcessed,
+			expAccrual: 500,
+		},
+		{
+			name:      "tooManyRequests",
+			responses: []accrualtest.Response{accrualtest.TooManyRequests("60")},
+			expErr:    prjerrors.ErrAccrualTooManyRequests,
+		},
+		{
+			name:      "internal",
+			responses: []accrualtest.Response{{StatusCode: http.StatusInternalServerError}},
+			expErr:    prjerrors.ErrAccrualInternal,
+		},
+		{
+			name:      "unexpected",
+			responses: []accrualtest.Response{{StatusCode: http.StatusBadGateway}},
+			expErr:    prjerrors.ErrAccrualUnexpectedStatus,
+		},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			srv := accrualtest.NewServer()
+			defer srv.Close()
+			srv.SetOrderResponses(orderNum, v.responses...)
+
+			cl := NewHTTPClient(srv.URL, timeout)
+			order, err := cl.GetOrder(context.Background(), orderNum)
+
+			require.ErrorIs(t, err, v.expErr)
+			if v.expErr != nil {
+				return
+			}
+			assert.Equal(t, orderNum, order.Order)
+			assert.Equal(t, v.expStatus, order.Status)
+			if v.expAccrual != 0 {
+				require.NotNil(t, order.Accrual)
+				assert.Equal(t, v.expAccrual, *order.Accrual)
+			}
+			assert.Equal(t, 1, srv.Requests(orderNum))
+		})
+	}
+}
+
+func TestGetOrderRetryAfter(t *testing.T) {
+	srv := accrualtest.NewServer()
+	defer srv.Close()
+	srv.SetOrderResponses(orderNum, accrualtest.TooManyRequests("3"), accrualtest.TooManyRequests("bad"))
+
+	cl := NewHTTPClient(srv.URL, timeout)
+
+	var tooMany *TooManyRequestsError
+	_, err := cl.GetOrder(context.Background(), orderNum)
+	require.ErrorAs(t, err, &tooMany)
+	assert.Equal(t, 3*time.Second, tooMany.RetryAfter)
+
+	_, err = cl.GetOrder(context.Background(), orderNum)
+	require.ErrorAs(t, err, &tooMany)
+	assert.Equal(t, defaultRetryAfter, tooMany.RetryAfter)
+}
+
+func TestRegisterOrder(t *testing.T) {
+	srv := accrualtest.NewServer()
+	defer srv.Close()
+	cl := NewHTTPClient(srv.URL, timeout)
+
+	order := &models.AccrualOrder{
+		Order: orderNum,
+		Goods: []models.Good{{Description: "Чайник Bork", Price: 7000}},
+	}
+	require.NoError(t, cl.RegisterOrder(context.Background(), order))
+	assert.Equal(t, []models.AccrualOrder{*order}, srv.RegisteredOrders())
+
+	srv.SetRegisterOrderStatus(http.StatusConflict)
+	require.ErrorIs(t, cl.RegisterOrder(context.Background(), order), prjerrors.ErrAccrualAlreadyExists)
+}
+
+func TestRegisterGoods(t *testing.T) {
+	srv := accrualtest.NewServer()
+	defer srv.Close()
+	cl := NewHTTPClient(srv.URL, timeout)
+
+	goods := &models.AccrualGoods{Match: "Bork", Reward: 10, RewardType: "%"}
+	require.NoError(t, cl.RegisterGoods(context.Background(), goods))
+	assert.Equal(t, []models.AccrualGoods{*goods}, srv.RegisteredGoods())
+
+	srv.SetRegisterGoodsStatus(http.StatusBadRequest)
+	require.ErrorIs(t, cl.RegisterGoods(context.Background(), goods), prjerrors.ErrAccrualBadRequest)
+}
@@ -0,0 +1,209 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+// probeOrder is requested by health checks, the accrual system answers 204
+// for it like for any unknown order.
+const probeOrder = "0"
+
+// Endpoint is one accrual system instance guarded by its own breaker.
+type Endpoint struct {
+	URL string
+	// Weight is share of calls sent to the endpoint first in weighted
+	// selection, it is not used in priority order.
+	Weight int
+	cl     Client
+	b      *Breaker
+}
+
+func NewEndpoint(url string, cl Client, b *Breaker) *Endpoint {
+	return &Endpoint{
+		URL: url,
+		cl:  cl,
+		b:   b,
+	}
+}
+
+func (e *Endpoint) State() BreakerState {
+	return e.b.State()
+}
+
+// FailoverClient sends every call to the first endpoint whose breaker is not
+// open and moves on to the next one when the endpoint fails, answers like 400,
+// 409 or 429 are returned as is. Endpoints are tried in priority order or,
+// when weighted, in random order drawn by endpoint weights.
+//
+// Regions do not share orders, so an order registered through the client is
+// pinned to the endpoint which accepted it and is asked there first, and 204
+// from one endpoint moves the lookup on to the next one. Pins live in memory
+// only, the 204 fall through finds orders again after restart.
+type FailoverClient struct {
+	endpoints []*Endpoint
+	weighted  bool
+	rnd       func(n int) int
+
+	mu     sync.Mutex
+	pinned map[string]*Endpoint
+}
+
+func NewFailoverClient(endpoints ...*Endpoint) *FailoverClient {
+	return &FailoverClient{
+		endpoints: endpoints,
+		rnd:       rand.Intn,
+		pinned:    make(map[string]*Endpoint),
+	}
+}
+
+// NewWeightedFailoverClient spreads calls over endpoints by their weights,
+// every weight must be positive.
+func NewWeightedFailoverClient(endpoints ...*Endpoint) *FailoverClient {
+	c := NewFailoverClient(endpoints...)
+	c.weighted = true
+	return c
+}
+
+func (c *FailoverClient) Endpoints() []*Endpoint {
+	return c.endpoints
+}
+
+// candidates lists endpoints in the order to try them, pinned endpoint
+// goes first.
+func (c *FailoverClient) candidates(pin *Endpoint) []*Endpoint {
+	list := make([]*Endpoint, 0, len(c.endpoints))
+	if pin != nil {
+		list = append(list, pin)
+	}
+	rest := make([]*Endpoint, 0, len(c.endpoints))
+	total := 0
+	for _, e := range c.endpoints {
+		if e != pin {
+			rest = append(rest, e)
+			total += e.Weight
+		}
+	}
+	if !c.weighted {
+		return append(list, rest...)
+	}
+	for len(rest) > 0 {
+		n := c.rnd(total)
+		for i, e := range rest {
+			if n < e.Weight {
+				list = append(list, e)
+				total -= e.Weight
+				rest = append(rest[:i], rest[i+1:]...)
+				break
+			}
+			n -= e.Weight
+		}
+	}
+	return list
+}
+
+func (c *FailoverClient) pin(number string) *Endpoint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pinned[number]
+}
+
+func (c *FailoverClient) setPin(number string, e *Endpoint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e == nil {
+		delete(c.pinned, number)
+		return
+	}
+	c.pinned[number] = e
+}
+
+// do calls endpoints until one answers, with lookup 204 answer moves on to
+// the next endpoint too and is returned only when every endpoint gave it.
+func (c *FailoverClient) do(ctx context.Context, pin *Endpoint, lookup bool, call func(e *Endpoint) error) error {
+	var failed, notRegistered error
+	list := c.candidates(pin)
+	for i, e := range list {
+		if e.b.Allow() != nil {
+			continue
+		}
+		err := call(e)
+		if ctx.Err() != nil {
+			return err
+		}
+		e.b.record(err)
+		switch {
+		case lookup && errors.Is(err, prjerrors.ErrAccrualNotRegistered):
+			notRegistered = err
+		case isFailure(err):
+			failed = err
+			if i < len(list)-1 {
+				slog.Warn("accrual endpoint failed, trying next", slog.String("url", e.URL), slog.String("error", err.Error()))
+			}
+		default:
+			return err
+		}
+	}
+	// order may be on the endpoint which failed just now, so failure wins,
+	// endpoints with open breaker are known to be down and are not waited for
+	if failed != nil {
+		return failed
+	}
+	if notRegistered != nil {
+		return notRegistered
+	}
+	return prjerrors.ErrAccrualCircuitOpen
+}
+
+func (c *FailoverClient) GetOrder(ctx context.Context, number string) (*models.Accrual, error) {
+	var order *models.Accrual
+	err := c.do(ctx, c.pin(number), true, func(e *Endpoint) error {
+		var err error
+		order, err = e.cl.GetOrder(ctx, number)
+		if err == nil && order.Status.Final() {
+			c.setPin(number, nil)
+		}
+		return err
+	})
+	return order, err
+}
+
+func (c *FailoverClient) RegisterOrder(ctx context.Context, order *models.AccrualOrder) error {
+	return c.do(ctx, c.pin(order.Order), false, func(e *Endpoint) error {
+		err := e.cl.RegisterOrder(ctx, order)
+		if err == nil || errors.Is(err, prjerrors.ErrAccrualAlreadyExists) {
+			c.setPin(order.Order, e)
+		}
+		return err
+	})
+}
+
+func (c *FailoverClient) RegisterGoods(ctx context.Context, goods *models.AccrualGoods) error {
+	return c.do(ctx, nil, false, func(e *Endpoint) error {
+		return e.cl.RegisterGoods(ctx, goods)
+	})
+}
+
+// Check probes every endpoint whose breaker lets calls through so standby
+// endpoints are known to be healthy before the poller fails over to them.
+func (c *FailoverClient) Check(ctx context.Context, timeout time.Duration) {
+	for _, e := range c.endpoints {
+		if e.b.Allow() != nil {
+			continue
+		}
+		probeCtx, cancel := context.WithTimeout(ctx, timeout)
+		_, err := e.cl.GetOrder(probeCtx, probeOrder)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		e.b.record(err)
+	}
+}
@@ -0,0 +1,158 @@
+package accrual
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sourcecd/gofermart/internal/accrual/accrualtest"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEndpoint(srv *accrualtest.Server) *Endpoint {
+	return NewEndpoint(srv.URL, NewHTTPClient(srv.URL, timeout), NewBreaker(1, time.Minute, 1))
+}
+
+func TestFailoverClient(t *testing.T) {
+	ctx := context.Background()
+
+	primary := accrualtest.NewServer()
+	defer primary.Close()
+	standby := accrualtest.NewServer()
+	defer standby.Close()
+	primary.SetOrderResponses(orderNum, accrualtest.Status(orderNum, models.StatusProcessing))
+	standby.SetOrderResponses(orderNum, accrualtest.Processed(orderNum, 500))
+
+	cl := NewFailoverClient(newTestEndpoint(primary), newTestEndpoint(standby))
+
+	order, err := cl.GetOrder(ctx, orderNum)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusProcessing, order.Status)
+	assert.Equal(t, 0, standby.Requests(orderNum))
+
+	// answers from a working endpoint are not failed over
+	primary.SetOrderResponses(orderNum, accrualtest.TooManyRequests("60"))
+	_, err = cl.GetOrder(ctx, orderNum)
+	require.ErrorIs(t, err, prjerrors.ErrAccrualTooManyRequests)
+	assert.Equal(t, 0, standby.Requests(orderNum))
+
+	primary.SetOrderResponses(orderNum, accrualtest.Response{StatusCode: http.StatusInternalServerError})
+	order, err = cl.GetOrder(ctx, orderNum)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusProcessed, order.Status)
+	assert.Equal(t, BreakerOpen, cl.Endpoints()[0].State())
+
+	// open primary is skipped until its breaker timeout passes
+	_, err = cl.GetOrder(ctx, orderNum)
+	require.NoError(t, err)
+	assert.Equal(t, 3, primary.Requests(orderNum))
+	assert.Equal(t, 2, standby.Requests(orderNum))
+
+	standby.SetOrderResponses(orderNum, accrualtest.Response{StatusCode: http.StatusInternalServerError})
+	_, err = cl.GetOrder(ctx, orderNum)
+	require.ErrorIs(t, err, prjerrors.ErrAccrualInternal)
+	_, err = cl.GetOrder(ctx, orderNum)
+	require.ErrorIs(t, err, prjerrors.ErrAccrualCircuitOpen)
+}
+
+func TestFailoverCheck(t *testing.T) {
+	primary := accrualtest.NewServer()
+	defer primary.Close()
+	standby := accrualtest.NewServer()
+	defer standby.Close()
+	standby.SetOrderResponses(probeOrder, accrualtest.Response{StatusCode: http.StatusInternalServerError})
+
+	cl := NewFailoverClient(newTestEndpoint(primary), newTestEndpoint(standby))
+	cl.Check(context.Background(), timeout)
+
+	assert.Equal(t, BreakerClosed, cl.Endpoints()[0].State())
+	assert.Equal(t, BreakerOpen, cl.Endpoints()[1].State())
+	assert.Equal(t, 1, primary.Requests(probeOrder))
+}
+
+func TestFailoverNotRegistered(t *testing.T) {
+	ctx := context.Background()
+
+	primary := accrualtest.NewServer()
+	defer primary.Close()
+	standby := accrualtest.NewServer()
+	defer standby.Close()
+	standby.SetOrderResponses(orderNum, accrualtest.Status(orderNum, models.StatusProcessing))
+
+	cl := NewFailoverClient(newTestEndpoint(primary), newTestEndpoint(standby))
+
+	// order registered in the other region is found there
+	order, err := cl.GetOrder(ctx, orderNum)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusProcessing, order.Status)
+	assert.Equal(t, BreakerClosed, cl.Endpoints()[0].State())
+
+	_, err = cl.GetOrder(ctx, "79927398713")
+	require.ErrorIs(t, err, prjerrors.ErrAccrualNotRegistered)
+	assert.Equal(t, 1, primary.Requests("79927398713"))
+	assert.Equal(t, 1, standby.Requests("79927398713"))
+
+	// unknown order on one endpoint and failure on another may be anywhere
+	primary.SetOrderResponses("79927398713", accrualtest.Response{StatusCode: http.StatusInternalServerError})
+	_, err = cl.GetOrder(ctx, "79927398713")
+	require.ErrorIs(t, err, prjerrors.ErrAccrualInternal)
+}
+
+func TestFailoverPinned(t *testing.T) {
+	ctx := context.Background()
+
+	primary := accrualtest.NewServer()
+	defer primary.Close()
+	standby := accrualtest.NewServer()
+	defer standby.Close()
+	primary.SetRegisterOrderStatus(http.StatusInternalServerError)
+	standby.SetOrderResponses(orderNum,
+		accrualtest.Status(orderNum, models.StatusProcessing),
+		accrualtest.Processed(orderNum, 500),
+	)
+
+	cl := NewFailoverClient(newTestEndpoint(primary), newTestEndpoint(standby))
+	require.NoError(t, cl.RegisterOrder(ctx, &models.AccrualOrder{Order: orderNum}))
+	assert.Len(t, standby.RegisteredOrders(), 1)
+
+	// order is asked where it was registered
+	order, err := cl.GetOrder(ctx, orderNum)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusProcessing, order.Status)
+	assert.Equal(t, 0, primary.Requests(orderNum))
+
+	// final answer drops the pin
+	order, err = cl.GetOrder(ctx, orderNum)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusProcessed, order.Status)
+	assert.Nil(t, cl.pin(orderNum))
+}
+
+func TestFailoverWeighted(t *testing.T) {
+	first := accrualtest.NewServer()
+	defer first.Close()
+	second := accrualtest.NewServer()
+	defer second.Close()
+
+	e1, e2 := newTestEndpoint(first), newTestEndpoint(second)
+	e1.Weight, e2.Weight = 1, 3
+	cl := NewWeightedFailoverClient(e1, e2)
+
+	cl.rnd = func(int) int { return 0 }
+	assert.Equal(t, []*Endpoint{e1, e2}, cl.candidates(nil))
+	cl.rnd = func(n int) int { return n - 1 }
+	assert.Equal(t, []*Endpoint{e2, e1}, cl.candidates(nil))
+	assert.Equal(t, []*Endpoint{e1, e2}, cl.candidates(e1))
+
+	picked := make(map[*Endpoint]int)
+	cl.rnd = rand.Intn
+	for i := 0; i < 1000; i++ {
+		picked[cl.candidates(nil)[0]]++
+	}
+	assert.Greater(t, picked[e2], picked[e1])
+}
@@ -0,0 +1,86 @@
+package accrual
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+// LoadRules reads reward rules from JSON file with an array of
+// {"match", "reward", "reward_type"} objects, empty path gives no rules.
+func LoadRules(path string) (*Rules, error) {
+	rules := NewRules()
+	if path == "" {
+		return rules, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list []models.AccrualGoods
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	for _, v := range list {
+		if err := rules.Add(v); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// LocalClient computes accruals in process with Rules instead of calling
+// the accrual system, registered orders are calculated immediately.
+type LocalClient struct {
+	mu     sync.Mutex
+	orders map[string]models.Accrual
+	rules  *Rules
+}
+
+func NewLocalClient(rules *Rules) *LocalClient {
+	return &LocalClient{
+		orders: make(map[string]models.Accrual),
+		rules:  rules,
+	}
+}
+
+func (c *LocalClient) GetOrder(ctx context.Context, number string) (*models.Accrual, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	order, ok := c.orders[number]
+	if !ok {
+		return nil, prjerrors.ErrAccrualNotRegistered
+	}
+	return &order, nil
+}
+
+func (c *LocalClient) RegisterOrder(ctx context.Context, order *models.AccrualOrder) error {
+	if order.Order == "" || len(order.Goods) == 0 {
+		return prjerrors.ErrAccrualBadRequest
+	}
+
+	res := models.Accrual{
+		Order:  order.Order,
+		Status: models.StatusInvalid,
+	}
+	if reward, matched := c.rules.Reward(order.Goods); matched {
+		res.Status = models.StatusProcessed
+		res.Accrual = &reward
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.orders[order.Order]; ok {
+		return prjerrors.ErrAccrualAlreadyExists
+	}
+	c.orders[order.Order] = res
+	return nil
+}
+
+func (c *LocalClient) RegisterGoods(ctx context.Context, goods *models.AccrualGoods) error {
+	return c.rules.Add(*goods)
+}
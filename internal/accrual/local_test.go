@@ -0,0 +1,68 @@
+package accrual
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[
+		{"match": "Bork", "reward": 10, "reward_type": "%"},
+		{"match": "Tefal", "reward": 50, "reward_type": "pt"}
+	]`), 0o600))
+
+	rules, err := LoadRules(path)
+	require.NoError(t, err)
+	assert.Len(t, rules.List(), 2)
+
+	rules, err = LoadRules("")
+	require.NoError(t, err)
+	assert.Empty(t, rules.List())
+
+	require.NoError(t, os.WriteFile(path, []byte(`[{"match": "Bork", "reward": 10, "reward_type": "x"}]`), 0o600))
+	_, err = LoadRules(path)
+	require.ErrorIs(t, err, prjerrors.ErrAccrualBadRequest)
+}
+
+func TestLocalClient(t *testing.T) {
+	ctx := context.Background()
+	cl := NewLocalClient(NewRules())
+
+	require.NoError(t, cl.RegisterGoods(ctx, &models.AccrualGoods{Match: "Bork", Reward: 10, RewardType: RewardPercent}))
+
+	_, err := cl.GetOrder(ctx, orderNum)
+	require.ErrorIs(t, err, prjerrors.ErrAccrualNotRegistered)
+
+	require.NoError(t, cl.RegisterOrder(ctx, &models.AccrualOrder{
+		Order: orderNum,
+		Goods: []models.Good{{Description: "Чайник Bork", Price: 7000}},
+	}))
+	require.NoError(t, cl.RegisterOrder(ctx, &models.AccrualOrder{
+		Order: "9278923470",
+		Goods: []models.Good{{Description: "Миксер", Price: 3000}},
+	}))
+	require.ErrorIs(t, cl.RegisterOrder(ctx, &models.AccrualOrder{
+		Order: orderNum,
+		Goods: []models.Good{{Description: "Чайник Bork", Price: 7000}},
+	}), prjerrors.ErrAccrualAlreadyExists)
+	require.ErrorIs(t, cl.RegisterOrder(ctx, &models.AccrualOrder{Order: "346436439"}), prjerrors.ErrAccrualBadRequest)
+
+	order, err := cl.GetOrder(ctx, orderNum)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusProcessed, order.Status)
+	require.NotNil(t, order.Accrual)
+	assert.Equal(t, 700.0, *order.Accrual)
+
+	order, err = cl.GetOrder(ctx, "9278923470")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusInvalid, order.Status)
+	assert.Nil(t, order.Accrual)
+}
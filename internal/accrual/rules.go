@@ -0,0 +1,84 @@
+package accrual
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+const (
+	RewardPercent = "%"
+	RewardPoints  = "pt"
+)
+
+// Rules is a set of goods reward mechanics as described by the accrual system:
+// every good whose description contains match is rewarded either by percent
+// of its price or by fixed points.
+type Rules struct {
+	mu    sync.RWMutex
+	rules []models.AccrualGoods
+}
+
+func NewRules() *Rules {
+	return &Rules{}
+}
+
+func ValidateRule(rule *models.AccrualGoods) error {
+	if rule.Match == "" || rule.Reward <= 0 {
+		return prjerrors.ErrAccrualBadRequest
+	}
+	if rule.RewardType != RewardPercent && rule.RewardType != RewardPoints {
+		return prjerrors.ErrAccrualBadRequest
+	}
+	return nil
+}
+
+func (r *Rules) Add(rule models.AccrualGoods) error {
+	if err := ValidateRule(&rule); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, v := range r.rules {
+		if v.Match == rule.Match {
+			return prjerrors.ErrAccrualAlreadyExists
+		}
+	}
+	r.rules = append(r.rules, rule)
+	return nil
+}
+
+func (r *Rules) List() []models.AccrualGoods {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]models.AccrualGoods(nil), r.rules...)
+}
+
+// Reward returns the accrual for the goods and whether any rule matched.
+func (r *Rules) Reward(goods []models.Good) (float64, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var (
+		reward  float64
+		matched bool
+	)
+	for _, g := range goods {
+		for _, rule := range r.rules {
+			if !strings.Contains(g.Description, rule.Match) {
+				continue
+			}
+			matched = true
+			switch rule.RewardType {
+			case RewardPercent:
+				reward += g.Price * rule.Reward / 100
+			case RewardPoints:
+				reward += rule.Reward
+			}
+			break
+		}
+	}
+	return reward, matched
+}
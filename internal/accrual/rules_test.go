@@ -0,0 +1,71 @@
+package accrual
+
+import (
+	"testing"
+
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRulesAdd(t *testing.T) {
+	rules := NewRules()
+
+	require.NoError(t, rules.Add(models.AccrualGoods{Match: "Bork", Reward: 10, RewardType: RewardPercent}))
+	require.ErrorIs(t, rules.Add(models.AccrualGoods{Match: "Bork", Reward: 5, RewardType: RewardPoints}), prjerrors.ErrAccrualAlreadyExists)
+	require.ErrorIs(t, rules.Add(models.AccrualGoods{Match: "", Reward: 5, RewardType: RewardPoints}), prjerrors.ErrAccrualBadRequest)
+	require.ErrorIs(t, rules.Add(models.AccrualGoods{Match: "LG", Reward: 5, RewardType: "x"}), prjerrors.ErrAccrualBadRequest)
+	require.ErrorIs(t, rules.Add(models.AccrualGoods{Match: "LG", Reward: 0, RewardType: RewardPoints}), prjerrors.ErrAccrualBadRequest)
+	assert.Len(t, rules.List(), 1)
+}
+
+func TestRulesReward(t *testing.T) {
+	rules := NewRules()
+	require.NoError(t, rules.Add(models.AccrualGoods{Match: "Bork", Reward: 10, RewardType: RewardPercent}))
+	require.NoError(t, rules.Add(models.AccrualGoods{Match: "Чайник", Reward: 15, RewardType: RewardPoints}))
+
+	testCases := []struct {
+		name       string
+		goods      []models.Good
+		expReward  float64
+		expMatched bool
+	}{
+		{
+			name:       "percent",
+			goods:      []models.Good{{Description: "Утюг Bork", Price: 7000}},
+			expReward:  700,
+			expMatched: true,
+		},
+		{
+			name:       "firstRuleWins",
+			goods:      []models.Good{{Description: "Чайник Bork", Price: 1000}},
+			expReward:  100,
+			expMatched: true,
+		},
+		{
+			name: "sum",
+			goods: []models.Good{
+				{Description: "Утюг Bork", Price: 7000},
+				{Description: "Чайник Tefal", Price: 2000},
+				{Description: "Миксер", Price: 3000},
+			},
+			expReward:  715,
+			expMatched: true,
+		},
+		{
+			name:       "noMatch",
+			goods:      []models.Good{{Description: "Миксер", Price: 3000}},
+			expReward:  0,
+			expMatched: false,
+		},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			reward, matched := rules.Reward(v.goods)
+			assert.Equal(t, v.expReward, reward)
+			assert.Equal(t, v.expMatched, matched)
+		})
+	}
+}
@@ -0,0 +1,179 @@
+// Package accrualstub is an in-memory stand-in of the accrual system
+// implementing its API from SPECIFICATION.md for local development.
+package accrualstub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sourcecd/gofermart/internal/accrual"
+	"github.com/sourcecd/gofermart/internal/logging"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/theplant/luhn"
+)
+
+const rateWindow = time.Minute
+
+type order struct {
+	goods        []models.Good
+	registeredAt time.Time
+}
+
+type Server struct {
+	mu     sync.Mutex
+	orders map[string]*order
+	rules  *accrual.Rules
+
+	// each status lasts step before moving forward
+	step time.Duration
+	// max GET /api/orders/{number} requests per minute, 0 is unlimited
+	rpm         int
+	windowStart time.Time
+	windowCount int
+
+	now func() time.Time
+}
+
+func NewServer(step time.Duration, rpm int) *Server {
+	return &Server{
+		orders: make(map[string]*order),
+		rules:  accrual.NewRules(),
+		step:   step,
+		rpm:    rpm,
+		now:    time.Now,
+	}
+}
+
+func (s *Server) Router() *chi.Mux {
+	mux := chi.NewRouter()
+	mux.Post("/api/orders", logging.WriteLogging(s.registerOrder()))
+	mux.Post("/api/goods", logging.WriteLogging(s.registerGoods()))
+	mux.Get("/api/orders/{number}", logging.WriteLogging(s.getOrder()))
+	return mux
+}
+
+// throttle reports whether request is allowed in current window.
+func (s *Server) throttle() bool {
+	if s.rpm <= 0 {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.windowStart) >= rateWindow {
+		s.windowStart = now
+		s.windowCount = 0
+	}
+	s.windowCount++
+	return s.windowCount <= s.rpm
+}
+
+// status moves the order REGISTERED -> PROCESSING -> PROCESSED/INVALID over time.
+func (s *Server) status(number string, o *order) models.Accrual {
+	res := models.Accrual{Order: number}
+	elapsed := s.now().Sub(o.registeredAt)
+	switch {
+	case elapsed < s.step:
+		res.Status = models.StatusRegistered
+	case elapsed < 2*s.step:
+		res.Status = models.StatusProcessing
+	default:
+		reward, matched := s.rules.Reward(o.goods)
+		if !matched {
+			res.Status = models.StatusInvalid
+			break
+		}
+		res.Status = models.StatusProcessed
+		res.Accrual = &reward
+	}
+	return res
+}
+
+func validOrderNumber(number string) bool {
+	num, err := strconv.Atoi(number)
+	if err != nil {
+		return false
+	}
+	return luhn.Valid(num)
+}
+
+func (s *Server) registerOrder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.AccrualOrder
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !validOrderNumber(req.Order) || len(req.Goods) == 0 {
+			http.Error(w, prjerrors.ErrAccrualBadRequest.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.orders[req.Order]; ok {
+			http.Error(w, prjerrors.ErrAccrualAlreadyExists.Error(), http.StatusConflict)
+			return
+		}
+		s.orders[req.Order] = &order{
+			goods:        req.Goods,
+			registeredAt: s.now(),
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func (s *Server) registerGoods() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.AccrualGoods
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.rules.Add(req); err != nil {
+			if errors.Is(err, prjerrors.ErrAccrualAlreadyExists) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) getOrder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.throttle() {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("Retry-After", fmt.Sprint(int(rateWindow.Seconds())))
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprintf(w, "No more than %d requests per minute allowed", s.rpm)
+			return
+		}
+
+		number := chi.URLParam(r, "number")
+		s.mu.Lock()
+		o, ok := s.orders[number]
+		s.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		enc := json.NewEncoder(w)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := enc.Encode(s.status(number, o)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
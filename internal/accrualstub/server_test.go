@@ -0,0 +1,96 @@
+package accrualstub
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sourcecd/gofermart/internal/accrual"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const step = time.Minute
+
+func TestStatusProgression(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	s := NewServer(step, 0)
+	s.now = func() time.Time { return now }
+	srv := httptest.NewServer(s.Router())
+	defer srv.Close()
+	cl := accrual.NewHTTPClient(srv.URL, time.Second)
+
+	require.NoError(t, cl.RegisterGoods(ctx, &models.AccrualGoods{Match: "Bork", Reward: 10, RewardType: accrual.RewardPercent}))
+	require.ErrorIs(t, cl.RegisterGoods(ctx, &models.AccrualGoods{Match: "Bork", Reward: 10, RewardType: accrual.RewardPercent}), prjerrors.ErrAccrualAlreadyExists)
+
+	_, err := cl.GetOrder(ctx, "12345678903")
+	require.ErrorIs(t, err, prjerrors.ErrAccrualNotRegistered)
+
+	require.NoError(t, cl.RegisterOrder(ctx, &models.AccrualOrder{
+		Order: "12345678903",
+		Goods: []models.Good{{Description: "Чайник Bork", Price: 7000}},
+	}))
+	require.NoError(t, cl.RegisterOrder(ctx, &models.AccrualOrder{
+		Order: "9278923470",
+		Goods: []models.Good{{Description: "Миксер", Price: 3000}},
+	}))
+	require.ErrorIs(t, cl.RegisterOrder(ctx, &models.AccrualOrder{
+		Order: "12345678903",
+		Goods: []models.Good{{Description: "Чайник Bork", Price: 7000}},
+	}), prjerrors.ErrAccrualAlreadyExists)
+	require.ErrorIs(t, cl.RegisterOrder(ctx, &models.AccrualOrder{
+		Order: "12345678904",
+		Goods: []models.Good{{Description: "Чайник Bork", Price: 7000}},
+	}), prjerrors.ErrAccrualBadRequest)
+
+	order, err := cl.GetOrder(ctx, "12345678903")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusRegistered, order.Status)
+
+	now = now.Add(step)
+	order, err = cl.GetOrder(ctx, "12345678903")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusProcessing, order.Status)
+
+	now = now.Add(step)
+	order, err = cl.GetOrder(ctx, "12345678903")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusProcessed, order.Status)
+	require.NotNil(t, order.Accrual)
+	assert.Equal(t, 700.0, *order.Accrual)
+
+	order, err = cl.GetOrder(ctx, "9278923470")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusInvalid, order.Status)
+	assert.Nil(t, order.Accrual)
+}
+
+func TestThrottle(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	s := NewServer(step, 2)
+	s.now = func() time.Time { return now }
+	srv := httptest.NewServer(s.Router())
+	defer srv.Close()
+	cl := accrual.NewHTTPClient(srv.URL, time.Second)
+
+	for i := 0; i < 2; i++ {
+		_, err := cl.GetOrder(ctx, "12345678903")
+		require.ErrorIs(t, err, prjerrors.ErrAccrualNotRegistered)
+	}
+
+	var tooMany *accrual.TooManyRequestsError
+	_, err := cl.GetOrder(ctx, "12345678903")
+	require.ErrorAs(t, err, &tooMany)
+	assert.Equal(t, rateWindow, tooMany.RetryAfter)
+
+	now = now.Add(rateWindow)
+	_, err = cl.GetOrder(ctx, "12345678903")
+	require.ErrorIs(t, err, prjerrors.ErrAccrualNotRegistered)
+}
@@ -1,7 +1,75 @@
 package config
 
+import "time"
+
+const (
+	AccrualModeRemote = "remote"
+	AccrualModeLocal  = "local"
+)
+
 type Config struct {
 	DatabaseDsn,
 	ServerAddr,
-	AccrualSystemAddress string
+	AccrualWebhookSecret,
+	AccrualMode,
+	AccrualRulesFile string
+
+	AccrualTimeout,
+	AccrualBreakerTimeout time.Duration
+
+	AccrualBreakerFailures,
+	AccrualBreakerSuccesses int
+
+	StuckMaxAge      time.Duration
+	StuckMaxAttempts int
+
+	// AccrualSystemAddresses are accrual system endpoints in priority order,
+	// the poller fails over to the next one when the current one is down.
+	AccrualSystemAddresses []string
+	// AccrualSystemWeights switch endpoint selection from priority order to
+	// weighted, one positive weight per address.
+	AccrualSystemWeights []int
+	AdminLogins          []string
+
+	TransferMax,
+	TransferDailyMax float64
+
+	WithdrawMax,
+	WithdrawDailyMax,
+	WithdrawMonthlyMax float64
+	// WithdrawTierLimits is "tier:max:daily:monthly" comma separated list
+	WithdrawTierLimits string
+
+	// WithdrawApprovalThreshold is sum above which withdrawals wait for
+	// admin approval
+	WithdrawApprovalThreshold float64
+
+	// Merchants is "name:key" comma separated list of merchant API keys
+	Merchants        string
+	AuthorizationTTL time.Duration
+	PaymentTokenTTL  time.Duration
+
+	// RiskRules is comma separated list of risk rules with action last,
+	// empty disables screening
+	RiskRules string
+
+	PointsExpiryMonths int
+	PointsExpiringSoon time.Duration
+
+	// Tiers is "name:threshold:multiplier" comma separated list
+	Tiers,
+	TierBasis string
+	TierWindow time.Duration
+
+	ReferrerBonus,
+	RefereeBonus float64
+	ReferralMaxRewards int
+
+	AccrualRegisterOrders bool
+}
+
+// AccrualLocal tells whether accruals are computed by the embedded rules
+// engine instead of the remote accrual system.
+func (c Config) AccrualLocal() bool {
+	return c.AccrualMode == AccrualModeLocal
 }
@@ -1,17 +1,54 @@
 package config
 
 import (
+	"errors"
 	"flag"
 	"log"
 	"net"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sourcecd/gofermart/internal/models"
 )
 
 func SetEnvironmentVariables(config *Config) {
 	a := os.Getenv("RUN_ADDRESS")
 	d := os.Getenv("DATABASE_URI")
 	r := os.Getenv("ACCRUAL_SYSTEM_ADDRESS")
+	aw := os.Getenv("ACCRUAL_SYSTEM_WEIGHTS")
+	at := os.Getenv("ACCRUAL_TIMEOUT")
+	bf := os.Getenv("ACCRUAL_BREAKER_FAILURES")
+	bt := os.Getenv("ACCRUAL_BREAKER_TIMEOUT")
+	bs := os.Getenv("ACCRUAL_BREAKER_SUCCESSES")
+	ws := os.Getenv("ACCRUAL_WEBHOOK_SECRET")
+	sa := os.Getenv("STUCK_MAX_AGE")
+	sm := os.Getenv("STUCK_MAX_ATTEMPTS")
+	al := os.Getenv("ADMIN_LOGINS")
+	am := os.Getenv("ACCRUAL_MODE")
+	ar := os.Getenv("ACCRUAL_RULES_FILE")
+	ro := os.Getenv("ACCRUAL_REGISTER_ORDERS")
+	tm := os.Getenv("TRANSFER_MAX")
+	td := os.Getenv("TRANSFER_DAILY_MAX")
+	pe := os.Getenv("POINTS_EXPIRY_MONTHS")
+	ps := os.Getenv("POINTS_EXPIRING_SOON")
+	lt := os.Getenv("LOYALTY_TIERS")
+	tb := os.Getenv("TIER_BASIS")
+	tw := os.Getenv("TIER_WINDOW")
+	rb := os.Getenv("REFERRER_BONUS")
+	eb := os.Getenv("REFEREE_BONUS")
+	rm := os.Getenv("REFERRAL_MAX_REWARDS")
+	wa := os.Getenv("WITHDRAW_APPROVAL_THRESHOLD")
+	wm := os.Getenv("WITHDRAW_MAX")
+	wd := os.Getenv("WITHDRAW_DAILY_MAX")
+	wn := os.Getenv("WITHDRAW_MONTHLY_MAX")
+	wt := os.Getenv("WITHDRAW_TIER_LIMITS")
+	mk := os.Getenv("MERCHANT_KEYS")
+	au := os.Getenv("AUTHORIZATION_TTL")
+	pt := os.Getenv("PAYMENT_TOKEN_TTL")
+	rk := os.Getenv("RISK_RULES")
 
 	if a != "" {
 		if _, _, err := net.SplitHostPort(a); err != nil {
@@ -23,16 +60,274 @@ func SetEnvironmentVariables(config *Config) {
 		config.DatabaseDsn = d
 	}
 	if r != "" {
-		if parsedURL, err := url.ParseRequestURI(r); err != nil || parsedURL.Scheme == "" || parsedURL.Host == "" {
+		config.AccrualSystemAddresses = splitList(r)
+	}
+	for _, v := range config.AccrualSystemAddresses {
+		if parsedURL, err := url.ParseRequestURI(v); err != nil || parsedURL.Scheme == "" || parsedURL.Host == "" {
 			log.Fatal("wrong accrual system address")
 		}
-		config.AccrualSystemAddress = r
 	}
+	if aw != "" {
+		weights, err := parseWeights(aw)
+		if err != nil {
+			log.Fatal(err)
+		}
+		config.AccrualSystemWeights = weights
+	}
+	if len(config.AccrualSystemWeights) > 0 && len(config.AccrualSystemWeights) != len(config.AccrualSystemAddresses) {
+		log.Fatal("accrual system weights do not match addresses")
+	}
+	if at != "" {
+		timeout, err := time.ParseDuration(at)
+		if err != nil || timeout <= 0 {
+			log.Fatal("wrong accrual system timeout")
+		}
+		config.AccrualTimeout = timeout
+	}
+	if bf != "" {
+		failures, err := strconv.Atoi(bf)
+		if err != nil || failures <= 0 {
+			log.Fatal("wrong accrual breaker failures threshold")
+		}
+		config.AccrualBreakerFailures = failures
+	}
+	if bt != "" {
+		timeout, err := time.ParseDuration(bt)
+		if err != nil || timeout <= 0 {
+			log.Fatal("wrong accrual breaker open timeout")
+		}
+		config.AccrualBreakerTimeout = timeout
+	}
+	if bs != "" {
+		successes, err := strconv.Atoi(bs)
+		if err != nil || successes <= 0 {
+			log.Fatal("wrong accrual breaker successes threshold")
+		}
+		config.AccrualBreakerSuccesses = successes
+	}
+	if ws != "" {
+		config.AccrualWebhookSecret = ws
+	}
+	if sa != "" {
+		age, err := time.ParseDuration(sa)
+		if err != nil || age < 0 {
+			log.Fatal("wrong stuck order max age")
+		}
+		config.StuckMaxAge = age
+	}
+	if sm != "" {
+		attempts, err := strconv.Atoi(sm)
+		if err != nil || attempts < 0 {
+			log.Fatal("wrong stuck order max attempts")
+		}
+		config.StuckMaxAttempts = attempts
+	}
+	if al != "" {
+		config.AdminLogins = splitList(al)
+	}
+	if am != "" {
+		config.AccrualMode = am
+	}
+	if config.AccrualMode != AccrualModeRemote && config.AccrualMode != AccrualModeLocal {
+		log.Fatal("wrong accrual mode")
+	}
+	if ar != "" {
+		config.AccrualRulesFile = ar
+	}
+	if ro != "" {
+		register, err := strconv.ParseBool(ro)
+		if err != nil {
+			log.Fatal("wrong accrual register orders flag")
+		}
+		config.AccrualRegisterOrders = register
+	}
+	if tm != "" {
+		limit, err := strconv.ParseFloat(tm, 64)
+		if err != nil || limit < 0 {
+			log.Fatal("wrong transfer max")
+		}
+		config.TransferMax = limit
+	}
+	if td != "" {
+		limit, err := strconv.ParseFloat(td, 64)
+		if err != nil || limit < 0 {
+			log.Fatal("wrong transfer daily max")
+		}
+		config.TransferDailyMax = limit
+	}
+	if pe != "" {
+		months, err := strconv.Atoi(pe)
+		if err != nil || months < 0 {
+			log.Fatal("wrong points expiry months")
+		}
+		config.PointsExpiryMonths = months
+	}
+	if ps != "" {
+		soon, err := time.ParseDuration(ps)
+		if err != nil || soon < 0 {
+			log.Fatal("wrong points expiring soon period")
+		}
+		config.PointsExpiringSoon = soon
+	}
+	if lt != "" {
+		config.Tiers = lt
+	}
+	if tb != "" {
+		config.TierBasis = tb
+	}
+	if config.TierBasis != models.TierBasisAccrued && config.TierBasis != models.TierBasisSpent {
+		log.Fatal("wrong tier basis")
+	}
+	if tw != "" {
+		window, err := time.ParseDuration(tw)
+		if err != nil || window <= 0 {
+			log.Fatal("wrong tier window")
+		}
+		config.TierWindow = window
+	}
+	if rb != "" {
+		bonus, err := strconv.ParseFloat(rb, 64)
+		if err != nil || bonus < 0 {
+			log.Fatal("wrong referrer bonus")
+		}
+		config.ReferrerBonus = bonus
+	}
+	if eb != "" {
+		bonus, err := strconv.ParseFloat(eb, 64)
+		if err != nil || bonus < 0 {
+			log.Fatal("wrong referee bonus")
+		}
+		config.RefereeBonus = bonus
+	}
+	if rm != "" {
+		limit, err := strconv.Atoi(rm)
+		if err != nil || limit < 0 {
+			log.Fatal("wrong referral max rewards")
+		}
+		config.ReferralMaxRewards = limit
+	}
+	if wa != "" {
+		threshold, err := strconv.ParseFloat(wa, 64)
+		if err != nil || threshold < 0 {
+			log.Fatal("wrong withdraw approval threshold")
+		}
+		config.WithdrawApprovalThreshold = threshold
+	}
+	if wm != "" {
+		limit, err := strconv.ParseFloat(wm, 64)
+		if err != nil || limit < 0 {
+			log.Fatal("wrong withdraw max")
+		}
+		config.WithdrawMax = limit
+	}
+	if wd != "" {
+		limit, err := strconv.ParseFloat(wd, 64)
+		if err != nil || limit < 0 {
+			log.Fatal("wrong withdraw daily max")
+		}
+		config.WithdrawDailyMax = limit
+	}
+	if wn != "" {
+		limit, err := strconv.ParseFloat(wn, 64)
+		if err != nil || limit < 0 {
+			log.Fatal("wrong withdraw monthly max")
+		}
+		config.WithdrawMonthlyMax = limit
+	}
+	if wt != "" {
+		config.WithdrawTierLimits = wt
+	}
+	if mk != "" {
+		config.Merchants = mk
+	}
+	if au != "" {
+		ttl, err := time.ParseDuration(au)
+		if err != nil || ttl <= 0 {
+			log.Fatal("wrong authorization ttl")
+		}
+		config.AuthorizationTTL = ttl
+	}
+	if pt != "" {
+		ttl, err := time.ParseDuration(pt)
+		if err != nil || ttl <= 0 {
+			log.Fatal("wrong payment token ttl")
+		}
+		config.PaymentTokenTTL = ttl
+	}
+	if rk != "" {
+		config.RiskRules = rk
+	}
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func parseWeights(s string) ([]int, error) {
+	var weights []int
+	for _, v := range splitList(s) {
+		weight, err := strconv.Atoi(v)
+		if err != nil || weight <= 0 {
+			return nil, errors.New("wrong accrual system weight")
+		}
+		weights = append(weights, weight)
+	}
+	return weights, nil
 }
 
 func SetCmdlineFlags(config *Config) {
 	flag.StringVar(&config.ServerAddr, "a", "localhost:8080", "Server bind addres and port")
 	flag.StringVar(&config.DatabaseDsn, "d", "host=localhost database=gofermart sslmode=disable", "pg db connect address")
-	flag.StringVar(&config.AccrualSystemAddress, "r", "", "accrual server")
+	flag.Func("r", "comma separated accrual servers in priority order", func(s string) error {
+		config.AccrualSystemAddresses = splitList(s)
+		return nil
+	})
+	flag.Func("accrual-weights", "comma separated accrual server weights, enables weighted selection instead of priority order", func(s string) error {
+		weights, err := parseWeights(s)
+		if err != nil {
+			return err
+		}
+		config.AccrualSystemWeights = weights
+		return nil
+	})
+	flag.DurationVar(&config.AccrualTimeout, "accrual-timeout", 5*time.Second, "accrual server request timeout")
+	flag.IntVar(&config.AccrualBreakerFailures, "accrual-breaker-failures", 5, "accrual server failures in a row to open circuit breaker")
+	flag.DurationVar(&config.AccrualBreakerTimeout, "accrual-breaker-timeout", 30*time.Second, "accrual server circuit breaker open state duration")
+	flag.IntVar(&config.AccrualBreakerSuccesses, "accrual-breaker-successes", 1, "accrual server successes in half-open state to close circuit breaker")
+	flag.StringVar(&config.AccrualWebhookSecret, "accrual-webhook-secret", "", "accrual callback HMAC secret, empty disables callback")
+	flag.DurationVar(&config.StuckMaxAge, "stuck-max-age", 0, "max order polling age before dead letter, 0 disables")
+	flag.IntVar(&config.StuckMaxAttempts, "stuck-max-attempts", 0, "max order polling attempts before dead letter, 0 disables")
+	flag.StringVar(&config.AccrualMode, "accrual-mode", AccrualModeRemote, "accrual mode: remote accrual system or local rules engine")
+	flag.StringVar(&config.AccrualRulesFile, "accrual-rules", "", "local accrual mode JSON rules file")
+	flag.BoolVar(&config.AccrualRegisterOrders, "accrual-register", false, "register orders with receipts in accrual system")
+	flag.Float64Var(&config.TransferMax, "transfer-max", 0, "max points transfer sum, 0 disables")
+	flag.Float64Var(&config.TransferDailyMax, "transfer-daily-max", 0, "max points sent by user in 24 hours, 0 disables")
+	flag.StringVar(&config.Merchants, "merchants", "", "merchant API keys as name:key comma separated list")
+	flag.DurationVar(&config.AuthorizationTTL, "authorization-ttl", 30*time.Minute, "how long merchant authorizations hold points")
+	flag.DurationVar(&config.PaymentTokenTTL, "payment-token-ttl", 10*time.Minute, "how long user payment tokens for merchants are valid")
+	flag.Float64Var(&config.WithdrawMax, "withdraw-max", 0, "max single withdrawal sum, 0 disables")
+	flag.Float64Var(&config.WithdrawDailyMax, "withdraw-daily-max", 0, "max points withdrawn by user in 24 hours, 0 disables")
+	flag.Float64Var(&config.WithdrawMonthlyMax, "withdraw-monthly-max", 0, "max points withdrawn by user in 30 days, 0 disables")
+	flag.StringVar(&config.WithdrawTierLimits, "withdraw-tier-limits", "", "withdrawal limits by loyalty tier as tier:max:daily:monthly comma separated list")
+	flag.Float64Var(&config.WithdrawApprovalThreshold, "withdraw-approval-threshold", 0, "withdraw sum above which admin approval is needed, 0 disables")
+	flag.StringVar(&config.RiskRules, "risk-rules", "", "risk rules as invalid_orders:streak:action,fast_withdrawal:window:share:action, action is flag or block")
+	flag.IntVar(&config.PointsExpiryMonths, "points-expiry-months", 0, "months accrued points live, 0 disables expiry")
+	flag.DurationVar(&config.PointsExpiringSoon, "points-expiring-soon", 30*24*time.Hour, "period before expiry points are reported as expiring soon")
+	flag.StringVar(&config.Tiers, "tiers", "", "loyalty tiers as name:threshold:multiplier comma separated list, empty disables")
+	flag.StringVar(&config.TierBasis, "tier-basis", models.TierBasisAccrued, "loyalty tier rolling total: accrued or spent points")
+	flag.DurationVar(&config.TierWindow, "tier-window", 365*24*time.Hour, "loyalty tier rolling total period")
+	flag.Float64Var(&config.ReferrerBonus, "referrer-bonus", 0, "points for inviting user once friend first order is processed")
+	flag.Float64Var(&config.RefereeBonus, "referee-bonus", 0, "points for invited user once first order is processed")
+	flag.IntVar(&config.ReferralMaxRewards, "referral-max-rewards", 10, "max rewarded referrals per inviting user, 0 disables the limit")
+	flag.Func("admins", "comma separated admin logins", func(s string) error {
+		config.AdminLogins = splitList(s)
+		return nil
+	})
 	flag.Parse()
 }
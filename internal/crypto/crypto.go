@@ -1,11 +1,18 @@
 package crypto
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base32"
 	"encoding/hex"
 )
 
+const (
+	referralCodeBytes = 5
+	voucherCodeBytes  = 10
+)
+
 func GenerateRandomKey() (string, error) {
 	key := make([]byte, 32)
 	if _, err := rand.Read(key); err != nil {
@@ -15,9 +22,44 @@ func GenerateRandomKey() (string, error) {
 	return encodedKey, nil
 }
 
+// GenerateReferralCode gives 8 chars upper case code easy to type.
+func GenerateReferralCode() (string, error) {
+	code := make([]byte, referralCodeBytes)
+	if _, err := rand.Read(code); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.EncodeToString(code), nil
+}
+
+// GenerateVoucherCode gives 16 chars upper case code, long enough not to
+// be guessed.
+func GenerateVoucherCode() (string, error) {
+	code := make([]byte, voucherCodeBytes)
+	if _, err := rand.Read(code); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.EncodeToString(code), nil
+}
+
 func GeneratePasswordHash(password string) string {
 	h := sha256.New()
 	h.Write([]byte(password))
 	dst := h.Sum(nil)
 	return hex.EncodeToString(dst)
 }
+
+func GenerateHMAC(data []byte, key string) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func CheckHMAC(data []byte, key, sign string) bool {
+	expected, err := hex.DecodeString(sign)
+	if err != nil {
+		return false
+	}
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(data)
+	return hmac.Equal(h.Sum(nil), expected)
+}
@@ -9,9 +9,41 @@ import (
 const (
 	password = "myMegaPass"
 	hashPass = "af89968d2591ce2f7f38d934c9abcc982461e0158be34a360b02f2e328d7a4b3"
+
+	hmacKey  = "webhookSecret"
+	hmacData = `{"order":"12345678903","status":"PROCESSED","accrual":500}`
 )
 
 func TestGeneratePasswordHash(t *testing.T) {
 	res := GeneratePasswordHash(password)
 	assert.Equal(t, hashPass, res)
 }
+
+func TestHMAC(t *testing.T) {
+	sign := GenerateHMAC([]byte(hmacData), hmacKey)
+	assert.Len(t, sign, 64)
+	assert.True(t, CheckHMAC([]byte(hmacData), hmacKey, sign))
+	assert.False(t, CheckHMAC([]byte(hmacData), "otherKey", sign))
+	assert.False(t, CheckHMAC([]byte(hmacData+" "), hmacKey, sign))
+	assert.False(t, CheckHMAC([]byte(hmacData), hmacKey, "not hex"))
+}
+
+func TestGenerateReferralCode(t *testing.T) {
+	code, err := GenerateReferralCode()
+	assert.NoError(t, err)
+	assert.Regexp(t, "^[A-Z2-7]{8}$", code)
+
+	other, err := GenerateReferralCode()
+	assert.NoError(t, err)
+	assert.NotEqual(t, code, other)
+}
+
+func TestGenerateVoucherCode(t *testing.T) {
+	code, err := GenerateVoucherCode()
+	assert.NoError(t, err)
+	assert.Regexp(t, "^[A-Z2-7]{16}$", code)
+
+	other, err := GenerateVoucherCode()
+	assert.NoError(t, err)
+	assert.NotEqual(t, code, other)
+}
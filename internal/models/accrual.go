@@ -1,7 +1,37 @@
 package models
 
 type Accrual struct {
-	Order   string   `json:"order"`
-	Status  string   `json:"status"`
-	Accrual *float64 `json:"accrual,omitempty"`
+	Order   string      `json:"order"`
+	Status  OrderStatus `json:"status"`
+	Accrual *float64    `json:"accrual,omitempty"`
+}
+
+type Good struct {
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+}
+
+type AccrualOrder struct {
+	Order string `json:"order"`
+	Goods []Good `json:"goods"`
+}
+
+type AccrualGoods struct {
+	Match      string  `json:"match"`
+	Reward     float64 `json:"reward"`
+	RewardType string  `json:"reward_type"`
+}
+
+const (
+	// RewardRulePending is recorded before the rule is sent to accrual
+	RewardRulePending = "PENDING"
+	RewardRuleActive  = "ACTIVE"
+	RewardRuleFailed  = "FAILED"
+)
+
+type RewardRule struct {
+	ID int64 `json:"-"`
+	AccrualGoods
+	Author    string `json:"author"`
+	CreatedAt string `json:"created_at"`
 }
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+const (
+	AuthorizationAuthorized = "AUTHORIZED"
+	AuthorizationCaptured   = "CAPTURED"
+	AuthorizationVoided     = "VOIDED"
+	AuthorizationExpired    = "EXPIRED"
+	AuthorizationRefunded   = "REFUNDED"
+)
+
+// Authorization is merchant hold on user points, Amount is reserved until
+// capture, void or expiry. Captured is withdrawn with Order, Refunded is
+// returned back from it. Token is the user payment token the merchant
+// got from the user, it is never given back.
+type Authorization struct {
+	ID        int64   `json:"id"`
+	Merchant  string  `json:"-"`
+	Token     string  `json:"token,omitempty"`
+	Login     string  `json:"login"`
+	Amount    float64 `json:"amount"`
+	Captured  float64 `json:"captured"`
+	Refunded  float64 `json:"refunded"`
+	Order     string  `json:"order,omitempty"`
+	Status    string  `json:"status"`
+	CreatedAt string  `json:"created_at"`
+	ExpiresAt string  `json:"expires_at"`
+}
+
+// PaymentToken is user consent to one merchant authorization issued from
+// the user session, Merchant and MaxAmount narrow it when set.
+type PaymentToken struct {
+	Token     string  `json:"token"`
+	Merchant  string  `json:"merchant,omitempty"`
+	MaxAmount float64 `json:"max_amount,omitempty"`
+	ExpiresAt string  `json:"expires_at"`
+}
+
+// AuthorizationOp is capture, void or refund of merchant authorization,
+// Amount and Order are not used by void.
+type AuthorizationOp struct {
+	Merchant string  `json:"-"`
+	ID       int64   `json:"-"`
+	Amount   float64 `json:"amount"`
+	Order    string  `json:"order,omitempty"`
+}
+
+// ParseMerchants parses "name:key" comma separated list into key to
+// merchant name map.
+func ParseMerchants(s string) (map[string]string, error) {
+	merchants := make(map[string]string)
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		name, key, ok := strings.Cut(v, ":")
+		if !ok || name == "" || key == "" {
+			return nil, prjerrors.ErrValidateMerchants
+		}
+		if _, ok := merchants[key]; ok {
+			return nil, prjerrors.ErrValidateMerchants
+		}
+		merchants[key] = name
+	}
+	return merchants, nil
+}
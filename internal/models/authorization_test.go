@@ -0,0 +1,24 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMerchants(t *testing.T) {
+	merchants, err := ParseMerchants("shop:k1, cafe:k2")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"k1": "shop", "k2": "cafe"}, merchants)
+
+	merchants, err = ParseMerchants("")
+	require.NoError(t, err)
+	assert.Empty(t, merchants)
+
+	for _, v := range []string{"shop", "shop:", ":k1", "shop:k1,cafe:k1"} {
+		_, err := ParseMerchants(v)
+		require.ErrorIs(t, err, prjerrors.ErrValidateMerchants, v)
+	}
+}
@@ -1,6 +1,77 @@
 package models
 
+import "time"
+
 type Balance struct {
-	Current   float64 `json:"current"`
-	Withdrawn float64 `json:"withdrawn"`
+	Current   float64         `json:"current"`
+	Withdrawn float64         `json:"withdrawn"`
+	Pending   *PendingAccrual `json:"pending,omitempty"`
+
+	// Held is reserved by withdrawals waiting for approval and merchant
+	// authorizations, not part of Current
+	Held float64 `json:"held,omitempty"`
+
+	ExpiringSoon *ExpiringPoints `json:"expiring_soon,omitempty"`
+}
+
+// ExpiringPoints are points to expire soon, ExpiresAt is the earliest one.
+type ExpiringPoints struct {
+	Amount    float64 `json:"amount"`
+	ExpiresAt string  `json:"expires_at"`
+}
+
+// PendingAccrual describes orders not finished by the accrual system yet,
+// Amount is accrual expected by the local rules engine before tier and
+// campaign bonuses, it is set only in local accrual mode.
+type PendingAccrual struct {
+	Count  int64    `json:"count"`
+	Amount *float64 `json:"amount,omitempty"`
+}
+
+const (
+	LedgerAccrual     = "accrual"
+	LedgerWithdrawal  = "withdrawal"
+	LedgerCorrection  = "correction"
+	LedgerTransferIn  = "transfer_in"
+	LedgerTransferOut = "transfer_out"
+	LedgerExpiry      = "expiry"
+	LedgerTierBonus   = "tier_bonus"
+	LedgerCampaign    = "campaign_bonus"
+	LedgerReferral    = "referral_bonus"
+	LedgerVoucher     = "voucher"
+	// rejected withdrawal sum returned from hold
+	LedgerRelease = "withdrawal_release"
+	// merchant authorization hold, its release and refund of captured sum
+	LedgerAuthorization = "authorization"
+	LedgerAuthRelease   = "authorization_release"
+	LedgerRefund        = "refund"
+
+	// statement lines around the period entries
+	LedgerOpening = "opening"
+	LedgerClosing = "closing"
+)
+
+// BalanceEntry is one balance change with the balance after it.
+type BalanceEntry struct {
+	Kind         string  `json:"kind"`
+	Order        string  `json:"order,omitempty"`
+	Counterparty string  `json:"counterparty,omitempty"`
+	Amount       float64 `json:"amount"`
+	Balance      float64 `json:"balance"`
+	CreatedAt    string  `json:"created_at"`
+}
+
+type BalanceAsOf struct {
+	Date    string  `json:"date"`
+	Current float64 `json:"current"`
+}
+
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+type Period struct {
+	From time.Time
+	To   time.Time
 }
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+const (
+	// CampaignBonusFixed credits Bonus points per eligible order.
+	CampaignBonusFixed = "fixed"
+	// CampaignBonusMultiplier credits order accrual times Bonus minus the
+	// accrual itself, so 2 is "double points".
+	CampaignBonusMultiplier = "multiplier"
+)
+
+// Campaign credits separate bonus for orders processed within
+// [StartsAt, EndsAt) that pass every eligibility rule set.
+type Campaign struct {
+	ID       int64     `json:"id"`
+	Name     string    `json:"name"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+
+	// eligibility rules, zero values are not checked
+	FirstOrder bool    `json:"first_order,omitempty"`
+	Tier       string  `json:"tier,omitempty"`
+	MinAccrual float64 `json:"min_accrual,omitempty"`
+
+	BonusType string  `json:"bonus_type"`
+	Bonus     float64 `json:"bonus"`
+
+	Author    string `json:"author"`
+	CreatedAt string `json:"created_at"`
+}
+
+func (c *Campaign) Validate() error {
+	if c.Name == "" || c.StartsAt.IsZero() || !c.EndsAt.After(c.StartsAt) || c.MinAccrual < 0 {
+		return prjerrors.ErrValidateCampaign
+	}
+	switch c.BonusType {
+	case CampaignBonusFixed:
+		if c.Bonus <= 0 {
+			return prjerrors.ErrValidateCampaign
+		}
+	case CampaignBonusMultiplier:
+		if c.Bonus <= 1 {
+			return prjerrors.ErrValidateCampaign
+		}
+	default:
+		return prjerrors.ErrValidateCampaign
+	}
+	return nil
+}
+
+// Eligible checks rules that do not need the user order history.
+func (c *Campaign) Eligible(tier string, accrual float64) bool {
+	if c.Tier != "" && c.Tier != tier {
+		return false
+	}
+	return accrual >= c.MinAccrual
+}
+
+// BonusFor gives bonus points for the order accrual rounded to cents.
+func (c *Campaign) BonusFor(accrual float64) float64 {
+	bonus := c.Bonus
+	if c.BonusType == CampaignBonusMultiplier {
+		bonus = accrual * (c.Bonus - 1)
+	}
+	return math.Round(bonus*100) / 100
+}
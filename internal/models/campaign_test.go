@@ -0,0 +1,55 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCampaignValidate(t *testing.T) {
+	starts := time.Date(2024, 7, 13, 0, 0, 0, 0, time.UTC)
+	valid := Campaign{
+		Name:      "weekend",
+		StartsAt:  starts,
+		EndsAt:    starts.Add(48 * time.Hour),
+		BonusType: CampaignBonusMultiplier,
+		Bonus:     2,
+	}
+	require.NoError(t, valid.Validate())
+
+	testCases := []struct {
+		name   string
+		modify func(c *Campaign)
+	}{
+		{name: "empty_name", modify: func(c *Campaign) { c.Name = "" }},
+		{name: "no_start", modify: func(c *Campaign) { c.StartsAt = time.Time{} }},
+		{name: "ends_before_start", modify: func(c *Campaign) { c.EndsAt = starts }},
+		{name: "negative_min_accrual", modify: func(c *Campaign) { c.MinAccrual = -1 }},
+		{name: "unknown_bonus_type", modify: func(c *Campaign) { c.BonusType = "percent" }},
+		{name: "multiplier_not_above_one", modify: func(c *Campaign) { c.Bonus = 1 }},
+		{name: "fixed_not_positive", modify: func(c *Campaign) { c.BonusType, c.Bonus = CampaignBonusFixed, 0 }},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid
+			tt.modify(&c)
+			require.ErrorIs(t, c.Validate(), prjerrors.ErrValidateCampaign)
+		})
+	}
+}
+
+func TestCampaignBonus(t *testing.T) {
+	double := Campaign{BonusType: CampaignBonusMultiplier, Bonus: 2}
+	assert.Equal(t, 125.5, double.BonusFor(125.5))
+
+	first := Campaign{BonusType: CampaignBonusFixed, Bonus: 500, FirstOrder: true}
+	assert.Equal(t, 500.0, first.BonusFor(10))
+
+	gold := Campaign{Tier: "gold", MinAccrual: 100}
+	assert.True(t, gold.Eligible("gold", 100))
+	assert.False(t, gold.Eligible("silver", 100))
+	assert.False(t, gold.Eligible("gold", 99.99))
+}
@@ -0,0 +1,18 @@
+package models
+
+type Health struct {
+	Status   string        `json:"status"`
+	Database string        `json:"database"`
+	Accrual  AccrualHealth `json:"accrual"`
+}
+
+type AccrualHealth struct {
+	Mode      string                  `json:"mode"`
+	Breaker   string                  `json:"breaker"`
+	Endpoints []AccrualEndpointHealth `json:"endpoints,omitempty"`
+}
+
+type AccrualEndpointHealth struct {
+	URL     string `json:"url"`
+	Breaker string `json:"breaker"`
+}
@@ -1,8 +1,41 @@
 package models
 
 type Order struct {
-	Number     string  `json:"number"`
-	Status     string  `json:"status"`
-	Accrual    float64 `json:"accrual,omitempty"`
-	UploadedAt string  `json:"uploaded_at"`
+	Number     string      `json:"number"`
+	Status     OrderStatus `json:"status"`
+	Accrual    float64     `json:"accrual,omitempty"`
+	UploadedAt string      `json:"uploaded_at"`
+}
+
+type OrderStatusChange struct {
+	Status    OrderStatus `json:"status"`
+	ChangedAt string      `json:"changed_at"`
+}
+
+type PollAttempt struct {
+	Number int64
+	Error  string
+}
+
+type DeadLetterOrder struct {
+	Number         string `json:"number"`
+	Login          string `json:"login"`
+	Status         string `json:"status"`
+	UploadedAt     string `json:"uploaded_at"`
+	Attempts       int64  `json:"attempts"`
+	LastError      string `json:"last_error,omitempty"`
+	DeadLetteredAt string `json:"dead_lettered_at"`
+}
+
+// OrderOverride is a support correction of order status and accrual, the
+// previous values and applied balance correction are filled by storage.
+type OrderOverride struct {
+	Number      string      `json:"number"`
+	Status      OrderStatus `json:"status"`
+	Accrual     *float64    `json:"accrual,omitempty"`
+	Reason      string      `json:"reason"`
+	Author      string      `json:"author"`
+	PrevStatus  OrderStatus `json:"prev_status"`
+	PrevAccrual *float64    `json:"prev_accrual,omitempty"`
+	Correction  float64     `json:"correction"`
 }
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+const (
+	RiskAllow = "ALLOW"
+	RiskFlag  = "FLAG"
+	RiskBlock = "BLOCK"
+
+	RiskEventOrder      = "order"
+	RiskEventWithdrawal = "withdrawal"
+
+	RiskConfirmed = "CONFIRMED"
+	RiskDismissed = "DISMISSED"
+)
+
+// RiskEvent is order registration or withdrawal screened by risk rules,
+// Rule is the rule that gave Action.
+type RiskEvent struct {
+	ID         int64   `json:"id"`
+	UserID     int64   `json:"-"`
+	Login      string  `json:"login"`
+	Kind       string  `json:"kind"`
+	Order      string  `json:"order"`
+	Sum        float64 `json:"sum,omitempty"`
+	Valid      bool    `json:"valid"`
+	Action     string  `json:"action"`
+	Rule       string  `json:"rule,omitempty"`
+	CreatedAt  string  `json:"created_at"`
+	Resolution string  `json:"resolution,omitempty"`
+	ReviewedBy string  `json:"reviewed_by,omitempty"`
+	ReviewedAt string  `json:"reviewed_at,omitempty"`
+	Note       string  `json:"note,omitempty"`
+}
+
+// RiskProfile is user history risk rules look at.
+type RiskProfile struct {
+	// InvalidStreak is Luhn invalid orders since the last allowed valid one
+	InvalidStreak int
+	// FirstAccrualAt is zero when nothing was accrued yet
+	FirstAccrualAt time.Time
+	Current        float64
+}
+
+// RiskReview resolves flagged or blocked event, Author is the admin login.
+type RiskReview struct {
+	ID         int64
+	Resolution string
+	Author     string
+	Note       string
+}
@@ -0,0 +1,39 @@
+package models
+
+type OrderStatus string
+
+const (
+	StatusNew        OrderStatus = "NEW"
+	StatusRegistered OrderStatus = "REGISTERED"
+	StatusProcessing OrderStatus = "PROCESSING"
+	StatusInvalid    OrderStatus = "INVALID"
+	StatusProcessed  OrderStatus = "PROCESSED"
+)
+
+// orderTransitions lists allowed forward moves, INVALID and PROCESSED are final.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	StatusNew:        {StatusRegistered, StatusProcessing, StatusInvalid, StatusProcessed},
+	StatusRegistered: {StatusProcessing, StatusInvalid, StatusProcessed},
+	StatusProcessing: {StatusInvalid, StatusProcessed},
+}
+
+func (s OrderStatus) Valid() bool {
+	switch s {
+	case StatusNew, StatusRegistered, StatusProcessing, StatusInvalid, StatusProcessed:
+		return true
+	}
+	return false
+}
+
+func (s OrderStatus) Final() bool {
+	return s == StatusInvalid || s == StatusProcessed
+}
+
+func (s OrderStatus) CanTransition(to OrderStatus) bool {
+	for _, v := range orderTransitions[s] {
+		if v == to {
+			return true
+		}
+	}
+	return false
+}
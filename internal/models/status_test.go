@@ -0,0 +1,39 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderStatusTransition(t *testing.T) {
+	testCases := []struct {
+		from, to OrderStatus
+		expOK    bool
+	}{
+		{from: StatusNew, to: StatusRegistered, expOK: true},
+		{from: StatusNew, to: StatusProcessed, expOK: true},
+		{from: StatusRegistered, to: StatusProcessing, expOK: true},
+		{from: StatusProcessing, to: StatusProcessed, expOK: true},
+		{from: StatusProcessing, to: StatusInvalid, expOK: true},
+		{from: StatusProcessing, to: StatusRegistered, expOK: false},
+		{from: StatusProcessing, to: StatusProcessing, expOK: false},
+		{from: StatusProcessed, to: StatusProcessing, expOK: false},
+		{from: StatusProcessed, to: StatusProcessed, expOK: false},
+		{from: StatusInvalid, to: StatusProcessed, expOK: false},
+		{from: StatusNew, to: "DONE", expOK: false},
+	}
+
+	for _, v := range testCases {
+		t.Run(string(v.from)+"->"+string(v.to), func(t *testing.T) {
+			assert.Equal(t, v.expOK, v.from.CanTransition(v.to))
+		})
+	}
+}
+
+func TestOrderStatusValid(t *testing.T) {
+	assert.True(t, StatusRegistered.Valid())
+	assert.False(t, OrderStatus("DONE").Valid())
+	assert.True(t, StatusInvalid.Final())
+	assert.False(t, StatusProcessing.Final())
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+const (
+	TierBasisAccrued = "accrued"
+	TierBasisSpent   = "spent"
+)
+
+// Tier is reached when user rolling total is at least Threshold, accruals
+// of the user are multiplied by Multiplier.
+type Tier struct {
+	Name       string
+	Threshold  float64
+	Multiplier float64
+}
+
+// TierPolicy defines tiers by rolling accrued or spent total for Window.
+type TierPolicy struct {
+	Tiers  []Tier
+	Window time.Duration
+	Basis  string
+}
+
+// ParseTiers parses "name:threshold:multiplier" comma separated list,
+// result is sorted by threshold.
+func ParseTiers(s string) ([]Tier, error) {
+	var tiers []Tier
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		parts := strings.Split(v, ":")
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, prjerrors.ErrValidateTiers
+		}
+		threshold, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || threshold < 0 {
+			return nil, prjerrors.ErrValidateTiers
+		}
+		multiplier, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil || multiplier < 1 {
+			return nil, prjerrors.ErrValidateTiers
+		}
+		tiers = append(tiers, Tier{Name: parts[0], Threshold: threshold, Multiplier: multiplier})
+	}
+	slices.SortFunc(tiers, func(a, b Tier) int {
+		switch {
+		case a.Threshold < b.Threshold:
+			return -1
+		case a.Threshold > b.Threshold:
+			return 1
+		}
+		return 0
+	})
+	for i := 1; i < len(tiers); i++ {
+		if tiers[i].Threshold == tiers[i-1].Threshold {
+			return nil, prjerrors.ErrValidateTiers
+		}
+	}
+	return tiers, nil
+}
+
+// Tier gives tier reached with total and the next one, both nil when
+// there is no such tier.
+func (p *TierPolicy) Tier(total float64) (current, next *Tier) {
+	for i := range p.Tiers {
+		if total < p.Tiers[i].Threshold {
+			return current, &p.Tiers[i]
+		}
+		current = &p.Tiers[i]
+	}
+	return current, nil
+}
+
+// UserTier is the applied user tier with progress to the next one.
+type UserTier struct {
+	Tier          string  `json:"tier,omitempty"`
+	Multiplier    float64 `json:"multiplier"`
+	Basis         string  `json:"basis"`
+	Total         float64 `json:"total"`
+	NextTier      string  `json:"next_tier,omitempty"`
+	NextThreshold float64 `json:"next_threshold,omitempty"`
+	Remaining     float64 `json:"remaining,omitempty"`
+	UpdatedAt     string  `json:"updated_at,omitempty"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTiers(t *testing.T) {
+	tiers, err := ParseTiers("gold:5000:1.25, bronze:0:1,silver:1000:1.1")
+	require.NoError(t, err)
+	assert.Equal(t, []Tier{
+		{Name: "bronze", Threshold: 0, Multiplier: 1},
+		{Name: "silver", Threshold: 1000, Multiplier: 1.1},
+		{Name: "gold", Threshold: 5000, Multiplier: 1.25},
+	}, tiers)
+
+	tiers, err = ParseTiers("")
+	require.NoError(t, err)
+	assert.Empty(t, tiers)
+
+	for _, v := range []string{"gold", "gold:x:1", "gold:100:0.5", ":100:1", "a:100:1,b:100:2"} {
+		_, err := ParseTiers(v)
+		require.ErrorIs(t, err, prjerrors.ErrValidateTiers, v)
+	}
+}
+
+func TestTierPolicy(t *testing.T) {
+	tiers, err := ParseTiers("silver:1000:1.1,gold:5000:1.25")
+	require.NoError(t, err)
+	p := &TierPolicy{Tiers: tiers}
+
+	current, next := p.Tier(500)
+	assert.Nil(t, current)
+	assert.Equal(t, "silver", next.Name)
+
+	current, next = p.Tier(1000)
+	assert.Equal(t, "silver", current.Name)
+	assert.Equal(t, "gold", next.Name)
+
+	current, next = p.Tier(10000)
+	assert.Equal(t, "gold", current.Name)
+	assert.Nil(t, next)
+}
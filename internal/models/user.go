@@ -3,4 +3,25 @@ package models
 type User struct {
 	Login    string `json:"login" valid:"required"`
 	Password string `json:"password" valid:"required"`
+	// ReferralCode of the inviting user, optional on registration
+	ReferralCode string `json:"referral_code,omitempty"`
+	// SessionUser is the user already logged in on registering client
+	SessionUser int64 `json:"-"`
+}
+
+// Referral is the user invite code with invited friends stats.
+type Referral struct {
+	Code     string  `json:"code"`
+	Invited  int64   `json:"invited"`
+	Rewarded int64   `json:"rewarded"`
+	Earned   float64 `json:"earned"`
+}
+
+// ReferralPolicy are bonuses credited to both parties once the referee first
+// order is processed, MaxRewards limits rewarded referrals per referrer and
+// zero disables the limit.
+type ReferralPolicy struct {
+	ReferrerBonus float64
+	RefereeBonus  float64
+	MaxRewards    int
 }
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+// MaxVoucherBatch is max codes generated by one batch.
+const MaxVoucherBatch = 1000
+
+// VoucherBatch is Count voucher codes worth Points each, every code can be
+// redeemed MaxRedemptions times by different users until ExpiresAt, nil
+// ExpiresAt never expires.
+type VoucherBatch struct {
+	ID             int64      `json:"id"`
+	Count          int        `json:"count"`
+	Points         float64    `json:"points"`
+	MaxRedemptions int        `json:"max_redemptions"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	Author         string     `json:"author"`
+	CreatedAt      string     `json:"created_at"`
+	Codes          []string   `json:"codes"`
+}
+
+// Validate checks batch and makes zero MaxRedemptions single use.
+func (b *VoucherBatch) Validate(now time.Time) error {
+	if b.MaxRedemptions == 0 {
+		b.MaxRedemptions = 1
+	}
+	if b.Count <= 0 || b.Count > MaxVoucherBatch || b.Points <= 0 || b.MaxRedemptions < 0 {
+		return prjerrors.ErrValidateVoucher
+	}
+	if b.ExpiresAt != nil && !b.ExpiresAt.After(now) {
+		return prjerrors.ErrValidateVoucher
+	}
+	return nil
+}
+
+// Voucher is a code of the batch with its redemptions count.
+type Voucher struct {
+	Code           string  `json:"code"`
+	BatchID        int64   `json:"batch_id"`
+	Points         float64 `json:"points"`
+	MaxRedemptions int     `json:"max_redemptions"`
+	Redeemed       int     `json:"redeemed"`
+	ExpiresAt      string  `json:"expires_at,omitempty"`
+	CreatedAt      string  `json:"created_at"`
+}
+
+// VoucherRedemption is user request to redeem Code, Points and Current are
+// credited points and the resulting balance.
+type VoucherRedemption struct {
+	Code    string  `json:"code"`
+	Points  float64 `json:"points"`
+	Current float64 `json:"current"`
+}
+
+// NormalizeVoucherCode makes typed code comparable with issued one, case,
+// spaces and dashes are ignored.
+func NormalizeVoucherCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(code)))
+}
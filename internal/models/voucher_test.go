@@ -0,0 +1,36 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVoucherBatchValidate(t *testing.T) {
+	now := time.Now()
+	tomorrow := now.Add(24 * time.Hour)
+	yesterday := now.Add(-24 * time.Hour)
+
+	b := &VoucherBatch{Count: 10, Points: 500}
+	assert.NoError(t, b.Validate(now))
+	assert.Equal(t, 1, b.MaxRedemptions)
+
+	assert.NoError(t, (&VoucherBatch{Count: 1, Points: 500, MaxRedemptions: 100, ExpiresAt: &tomorrow}).Validate(now))
+
+	for _, v := range []*VoucherBatch{
+		{Count: 0, Points: 500},
+		{Count: MaxVoucherBatch + 1, Points: 500},
+		{Count: 1, Points: 0},
+		{Count: 1, Points: 500, MaxRedemptions: -1},
+		{Count: 1, Points: 500, ExpiresAt: &yesterday},
+	} {
+		assert.ErrorIs(t, v.Validate(now), prjerrors.ErrValidateVoucher)
+	}
+}
+
+func TestNormalizeVoucherCode(t *testing.T) {
+	assert.Equal(t, "ABCDEFGH23456777", NormalizeVoucherCode(" abcd-efgh 2345-6777 "))
+	assert.Empty(t, NormalizeVoucherCode(" - "))
+}
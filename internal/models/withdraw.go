@@ -1,12 +1,146 @@
 package models
 
+import (
+	"strconv"
+	"strings"
+
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+const (
+	WithdrawalPendingApproval = "PENDING_APPROVAL"
+	WithdrawalApproved        = "APPROVED"
+	WithdrawalRejected        = "REJECTED"
+)
+
 type Withdraw struct {
 	Order string  `json:"order"`
 	Sum   float64 `json:"sum"`
+	// Status is set to PENDING_APPROVAL when the sum is put on hold
+	Status string `json:"-"`
 }
 
 type Withdrawals struct {
 	Order       string  `json:"order"`
 	Sum         float64 `json:"sum"`
 	ProcessedAt string  `json:"processed_at"`
+	// Status is set only for withdrawals that needed approval
+	Status string `json:"status,omitempty"`
+	// Reversed is sum returned back by refunds and reversals
+	Reversed float64 `json:"reversed,omitempty"`
+}
+
+// Reversal returns Amount of withdrawal back to the user, zero Amount
+// reverses all that is left. Merchant is set when initiated by merchant,
+// the withdrawal must be captured by its authorization then.
+type Reversal struct {
+	Order     string  `json:"order"`
+	Amount    float64 `json:"amount"`
+	Reason    string  `json:"reason,omitempty"`
+	Author    string  `json:"author,omitempty"`
+	Merchant  string  `json:"merchant,omitempty"`
+	Reversed  float64 `json:"reversed"`
+	Remaining float64 `json:"remaining"`
+}
+
+// WithdrawalApproval is a large withdrawal with its sum on hold until an
+// admin decision.
+type WithdrawalApproval struct {
+	Order       string  `json:"order"`
+	Login       string  `json:"login"`
+	Sum         float64 `json:"sum"`
+	Status      string  `json:"status"`
+	RequestedAt string  `json:"requested_at"`
+	DecidedBy   string  `json:"decided_by,omitempty"`
+	DecidedAt   string  `json:"decided_at,omitempty"`
+	Reason      string  `json:"reason,omitempty"`
+}
+
+// WithdrawalDecision approves or rejects pending withdrawal, Author is the
+// admin login.
+type WithdrawalDecision struct {
+	Number  int64
+	Approve bool
+	Author  string
+	Reason  string
+}
+
+type Transfer struct {
+	To  string  `json:"to"`
+	Sum float64 `json:"sum"`
+}
+
+// TransferLimits are max single transfer sum and max sum sent in the last
+// 24 hours, zero disables a limit.
+type TransferLimits struct {
+	Max      float64
+	DailyMax float64
+}
+
+// WithdrawalLimits are max single withdrawal sum and max sums withdrawn in
+// the last 24 hours and 30 days, zero disables a limit.
+type WithdrawalLimits struct {
+	Max        float64 `json:"max"`
+	DailyMax   float64 `json:"daily_max"`
+	MonthlyMax float64 `json:"monthly_max"`
+}
+
+// UserWithdrawalLimits override limits of the user tier and global ones
+// as a whole, Author is the admin login.
+type UserWithdrawalLimits struct {
+	Login string `json:"login"`
+	WithdrawalLimits
+	Author    string `json:"author"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// WithdrawalLimitPolicy gives limits by user tier, Global applies to users
+// whose tier has no limits of its own.
+type WithdrawalLimitPolicy struct {
+	Global WithdrawalLimits
+	Tiers  map[string]WithdrawalLimits
+}
+
+// For gives limits of the tier, empty tier gives global limits.
+func (p *WithdrawalLimitPolicy) For(tier string) WithdrawalLimits {
+	if limits, ok := p.Tiers[tier]; ok {
+		return limits
+	}
+	return p.Global
+}
+
+// ParseWithdrawalTierLimits parses "tier:max:daily:monthly" comma separated
+// list.
+func ParseWithdrawalTierLimits(s string) (map[string]WithdrawalLimits, error) {
+	tiers := make(map[string]WithdrawalLimits)
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		parts := strings.Split(v, ":")
+		if len(parts) != 4 || parts[0] == "" {
+			return nil, prjerrors.ErrValidateWithdrawalLimits
+		}
+		if _, ok := tiers[parts[0]]; ok {
+			return nil, prjerrors.ErrValidateWithdrawalLimits
+		}
+		var values [3]float64
+		for i, p := range parts[1:] {
+			value, err := strconv.ParseFloat(p, 64)
+			if err != nil || value < 0 {
+				return nil, prjerrors.ErrValidateWithdrawalLimits
+			}
+			values[i] = value
+		}
+		tiers[parts[0]] = WithdrawalLimits{Max: values[0], DailyMax: values[1], MonthlyMax: values[2]}
+	}
+	return tiers, nil
+}
+
+// Validate checks limits set through admin API.
+func (l WithdrawalLimits) Validate() error {
+	if l.Max < 0 || l.DailyMax < 0 || l.MonthlyMax < 0 {
+		return prjerrors.ErrValidateWithdrawalLimits
+	}
+	return nil
 }
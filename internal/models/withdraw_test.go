@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWithdrawalTierLimits(t *testing.T) {
+	tiers, err := ParseWithdrawalTierLimits("gold:5000:20000:100000, silver:1000:0:10000")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]WithdrawalLimits{
+		"gold":   {Max: 5000, DailyMax: 20000, MonthlyMax: 100000},
+		"silver": {Max: 1000, DailyMax: 0, MonthlyMax: 10000},
+	}, tiers)
+
+	tiers, err = ParseWithdrawalTierLimits("")
+	require.NoError(t, err)
+	assert.Empty(t, tiers)
+
+	for _, v := range []string{"gold", "gold:1:2", ":1:2:3", "gold:x:2:3", "gold:1:-2:3", "gold:1:2:3,gold:4:5:6"} {
+		_, err := ParseWithdrawalTierLimits(v)
+		require.ErrorIs(t, err, prjerrors.ErrValidateWithdrawalLimits, v)
+	}
+}
+
+func TestWithdrawalLimitPolicy(t *testing.T) {
+	p := &WithdrawalLimitPolicy{
+		Global: WithdrawalLimits{Max: 100},
+		Tiers:  map[string]WithdrawalLimits{"gold": {Max: 1000, DailyMax: 5000}},
+	}
+	assert.Equal(t, WithdrawalLimits{Max: 100}, p.For(""))
+	assert.Equal(t, WithdrawalLimits{Max: 100}, p.For("silver"))
+	assert.Equal(t, WithdrawalLimits{Max: 1000, DailyMax: 5000}, p.For("gold"))
+
+	assert.NoError(t, WithdrawalLimits{Max: 1}.Validate())
+	assert.ErrorIs(t, WithdrawalLimits{MonthlyMax: -1}.Validate(), prjerrors.ErrValidateWithdrawalLimits)
+}
@@ -9,8 +9,46 @@ var (
 	ErrOtherOrderAlreadyExists = errors.New("order already exists another user")
 	ErrEmptyData               = errors.New("no content")
 	ErrNotEnough               = errors.New("not enought money")
+	ErrOrderNotFound           = errors.New("order not found")
+	ErrTransferLimit           = errors.New("transfer limit exceeded")
+	ErrSelfTransfer            = errors.New("transfer to yourself")
+	ErrCampaignNotFound        = errors.New("campaign not found")
+	ErrReferralCode            = errors.New("unknown referral code")
+	ErrSelfReferral            = errors.New("referral to yourself")
+	ErrWithdrawalNotPending    = errors.New("withdrawal is not pending approval")
+	ErrAuthorizationNotFound   = errors.New("authorization not found")
+	ErrAuthorizationState      = errors.New("authorization state does not allow the operation")
+	ErrAuthorizationAmount     = errors.New("amount exceeds authorization")
+	ErrPaymentToken            = errors.New("payment token is unknown, used, expired or does not allow the authorization")
+	ErrReversalAmount          = errors.New("amount exceeds withdrawal not reversed yet")
+	ErrWithdrawalNotFinal      = errors.New("withdrawal is not approved")
+	ErrWithdrawalLimit         = errors.New("withdrawal limit exceeded")
+	ErrWithdrawalLimitsNotSet  = errors.New("user withdrawal limits not set")
+	ErrRiskBlocked             = errors.New("operation blocked by risk rules")
+	ErrRiskEventNotFound       = errors.New("risk event not found")
+	ErrRiskEventReviewed       = errors.New("risk event already reviewed")
+	ErrVoucherNotFound         = errors.New("voucher not found")
+	ErrVoucherExpired          = errors.New("voucher expired")
+	ErrVoucherRedeemed         = errors.New("voucher already redeemed")
 
-	ErrAuthCredsNotFound = errors.New("auth creds not found")
-	ErrReqJSONParse      = errors.New("request json parse failed")
-	ErrValidateLogPass   = errors.New("validate login or password false (maybe empty)")
+	ErrAuthCredsNotFound        = errors.New("auth creds not found")
+	ErrReqJSONParse             = errors.New("request json parse failed")
+	ErrValidateLogPass          = errors.New("validate login or password false (maybe empty)")
+	ErrValidateReceipt          = errors.New("validate receipt goods false (maybe empty)")
+	ErrValidateOverride         = errors.New("validate override false (status, accrual or empty reason)")
+	ErrValidateTiers            = errors.New("validate tiers false (name:threshold:multiplier expected)")
+	ErrValidateCampaign         = errors.New("validate campaign false (name, time window or bonus)")
+	ErrValidateMerchants        = errors.New("validate merchants false (name:key expected)")
+	ErrValidateWithdrawalLimits = errors.New("validate withdrawal limits false (tier:max:daily:monthly expected)")
+	ErrValidateRiskRules        = errors.New("validate risk rules false (name:params:action expected)")
+	ErrValidateVoucher          = errors.New("validate voucher batch false (count, points, redemptions or expiry)")
+	ErrNotAdmin                 = errors.New("user is not admin")
+
+	ErrAccrualNotRegistered    = errors.New("order not registered in accrual system")
+	ErrAccrualTooManyRequests  = errors.New("accrual system too many requests")
+	ErrAccrualBadRequest       = errors.New("accrual system bad request")
+	ErrAccrualAlreadyExists    = errors.New("accrual system already registered")
+	ErrAccrualInternal         = errors.New("accrual system internal error")
+	ErrAccrualUnexpectedStatus = errors.New("accrual system unexpected status")
+	ErrAccrualCircuitOpen      = errors.New("accrual system circuit breaker is open")
 )
@@ -18,14 +18,42 @@ type (
 		skippedErrors error
 	}
 
-	UserFunc        func(ctx context.Context, reg *models.User) (int64, error)
-	CreateOrderFunc func(ctx context.Context, userid, orderid int64) error
-	ListOrdersFunc  func(ctx context.Context, userid int64, orderList *[]models.Order) error
-	GetBalanceFunc  func(ctx context.Context, userid int64, balance *models.Balance) error
-	WithdrawFunc    func(ctx context.Context, userid int64, withdraw *models.Withdraw) error
-	WithdrawalsFunc func(ctx context.Context, userid int64, withdrawals *[]models.Withdrawals) error
+	UserFunc         func(ctx context.Context, reg *models.User) (int64, error)
+	CreateOrderFunc  func(ctx context.Context, userid, orderid int64) error
+	ReceiptFunc      func(ctx context.Context, userid int64, receipt *models.AccrualOrder) error
+	ListOrdersFunc   func(ctx context.Context, userid int64, orderList *[]models.Order) error
+	HistoryFunc      func(ctx context.Context, userid, orderid int64, history *[]models.OrderStatusChange) error
+	GetBalanceFunc   func(ctx context.Context, userid int64, balance *models.Balance) error
+	WithdrawFunc     func(ctx context.Context, userid int64, withdraw *models.Withdraw) error
+	WithdrawalsFunc  func(ctx context.Context, userid int64, withdrawals *[]models.Withdrawals) error
+	AccrualSaveFunc  func(ctx context.Context, accrual []models.Accrual) error
+	LedgerFunc       func(ctx context.Context, userid int64, page models.Page, entries *[]models.BalanceEntry) error
+	AsOfFunc         func(ctx context.Context, userid int64, date time.Time, balance *models.BalanceAsOf) error
+	TierFunc         func(ctx context.Context, userid int64, policy *models.TierPolicy, tier *models.UserTier) error
+	TransferFunc     func(ctx context.Context, userid int64, transfer *models.Transfer, limits models.TransferLimits) error
+	ReferralFunc     func(ctx context.Context, userid int64, referral *models.Referral) error
+	RiskProfileFunc  func(ctx context.Context, userid int64, profile *models.RiskProfile) error
+	RiskEventFunc    func(ctx context.Context, event *models.RiskEvent) error
+	VoucherFunc      func(ctx context.Context, userid int64, redemption *models.VoucherRedemption) error
+	PaymentTokenFunc func(ctx context.Context, userid int64, token *models.PaymentToken) error
 )
 
+// Do runs f until it succeeds or fails with a final error, f gets context
+// with retry timeout. Store calls without typed wrapper go through it.
+func (retry *Retry) Do(ctx context.Context, f func(ctx context.Context) error) error {
+	bf := baseretry.WithMaxRetries(retry.maxRetries, baseretry.NewFibonacci(retry.fiboDuration))
+
+	ctx, cancel := context.WithTimeout(ctx, retry.timeout)
+	defer cancel()
+	return baseretry.Do(ctx, bf, func(ctx context.Context) error {
+		err := f(ctx)
+		if errors.Is(retry.skippedErrors, err) {
+			return err
+		}
+		return baseretry.RetryableError(err)
+	})
+}
+
 func (retry *Retry) UserFuncRetry(f UserFunc) UserFunc {
 	bf := baseretry.WithMaxRetries(retry.maxRetries, baseretry.NewFibonacci(retry.fiboDuration))
 
@@ -62,6 +90,23 @@ func (retry *Retry) CreateOrderFuncRetry(f CreateOrderFunc) CreateOrderFunc {
 	}
 }
 
+func (retry *Retry) CreateOrderReceiptFuncRetry(f ReceiptFunc) ReceiptFunc {
+	bf := baseretry.WithMaxRetries(retry.maxRetries, baseretry.NewFibonacci(retry.fiboDuration))
+
+	return func(ctx context.Context, userid int64, receipt *models.AccrualOrder) error {
+		ctx, cancel := context.WithTimeout(ctx, retry.timeout)
+		defer cancel()
+		err := baseretry.Do(ctx, bf, func(ctx context.Context) error {
+			err := f(ctx, userid, receipt)
+			if errors.Is(retry.skippedErrors, err) {
+				return err
+			}
+			return baseretry.RetryableError(err)
+		})
+		return err
+	}
+}
+
 func (retry *Retry) ListOrdersFuncRetry(f ListOrdersFunc) ListOrdersFunc {
 	bf := baseretry.WithMaxRetries(retry.maxRetries, baseretry.NewFibonacci(retry.fiboDuration))
 
@@ -79,6 +124,23 @@ func (retry *Retry) ListOrdersFuncRetry(f ListOrdersFunc) ListOrdersFunc {
 	}
 }
 
+func (retry *Retry) HistoryFuncRetry(f HistoryFunc) HistoryFunc {
+	bf := baseretry.WithMaxRetries(retry.maxRetries, baseretry.NewFibonacci(retry.fiboDuration))
+
+	return func(ctx context.Context, userid, orderid int64, history *[]models.OrderStatusChange) error {
+		ctx, cancel := context.WithTimeout(ctx, retry.timeout)
+		defer cancel()
+		err := baseretry.Do(ctx, bf, func(ctx context.Context) error {
+			err := f(ctx, userid, orderid, history)
+			if errors.Is(retry.skippedErrors, err) {
+				return err
+			}
+			return baseretry.RetryableError(err)
+		})
+		return err
+	}
+}
+
 func (retry *Retry) GetBalanceFuncRetry(f GetBalanceFunc) GetBalanceFunc {
 	bf := baseretry.WithMaxRetries(retry.maxRetries, baseretry.NewFibonacci(retry.fiboDuration))
 
@@ -130,6 +192,176 @@ func (retry *Retry) WithdrawalsFuncRetry(f WithdrawalsFunc) WithdrawalsFunc {
 	}
 }
 
+func (retry *Retry) LedgerFuncRetry(f LedgerFunc) LedgerFunc {
+	bf := baseretry.WithMaxRetries(retry.maxRetries, baseretry.NewFibonacci(retry.fiboDuration))
+
+	return func(ctx context.Context, userid int64, page models.Page, entries *[]models.BalanceEntry) error {
+		ctx, cancel := context.WithTimeout(ctx, retry.timeout)
+		defer cancel()
+		err := baseretry.Do(ctx, bf, func(ctx context.Context) error {
+			err := f(ctx, userid, page, entries)
+			if errors.Is(retry.skippedErrors, err) {
+				return err
+			}
+			return baseretry.RetryableError(err)
+		})
+		return err
+	}
+}
+
+func (retry *Retry) AsOfFuncRetry(f AsOfFunc) AsOfFunc {
+	bf := baseretry.WithMaxRetries(retry.maxRetries, baseretry.NewFibonacci(retry.fiboDuration))
+
+	return func(ctx context.Context, userid int64, date time.Time, balance *models.BalanceAsOf) error {
+		ctx, cancel := context.WithTimeout(ctx, retry.timeout)
+		defer cancel()
+		err := baseretry.Do(ctx, bf, func(ctx context.Context) error {
+			err := f(ctx, userid, date, balance)
+			if errors.Is(retry.skippedErrors, err) {
+				return err
+			}
+			return baseretry.RetryableError(err)
+		})
+		return err
+	}
+}
+
+func (retry *Retry) TransferFuncRetry(f TransferFunc) TransferFunc {
+	bf := baseretry.WithMaxRetries(retry.maxRetries, baseretry.NewFibonacci(retry.fiboDuration))
+
+	return func(ctx context.Context, userid int64, transfer *models.Transfer, limits models.TransferLimits) error {
+		ctx, cancel := context.WithTimeout(ctx, retry.timeout)
+		defer cancel()
+		err := baseretry.Do(ctx, bf, func(ctx context.Context) error {
+			err := f(ctx, userid, transfer, limits)
+			if errors.Is(retry.skippedErrors, err) {
+				return err
+			}
+			return baseretry.RetryableError(err)
+		})
+		return err
+	}
+}
+
+func (retry *Retry) TierFuncRetry(f TierFunc) TierFunc {
+	bf := baseretry.WithMaxRetries(retry.maxRetries, baseretry.NewFibonacci(retry.fiboDuration))
+
+	return func(ctx context.Context, userid int64, policy *models.TierPolicy, tier *models.UserTier) error {
+		ctx, cancel := context.WithTimeout(ctx, retry.timeout)
+		defer cancel()
+		err := baseretry.Do(ctx, bf, func(ctx context.Context) error {
+			err := f(ctx, userid, policy, tier)
+			if errors.Is(retry.skippedErrors, err) {
+				return err
+			}
+			return baseretry.RetryableError(err)
+		})
+		return err
+	}
+}
+
+func (retry *Retry) ReferralFuncRetry(f ReferralFunc) ReferralFunc {
+	bf := baseretry.WithMaxRetries(retry.maxRetries, baseretry.NewFibonacci(retry.fiboDuration))
+
+	return func(ctx context.Context, userid int64, referral *models.Referral) error {
+		ctx, cancel := context.WithTimeout(ctx, retry.timeout)
+		defer cancel()
+		err := baseretry.Do(ctx, bf, func(ctx context.Context) error {
+			err := f(ctx, userid, referral)
+			if errors.Is(retry.skippedErrors, err) {
+				return err
+			}
+			return baseretry.RetryableError(err)
+		})
+		return err
+	}
+}
+
+func (retry *Retry) RiskProfileFuncRetry(f RiskProfileFunc) RiskProfileFunc {
+	bf := baseretry.WithMaxRetries(retry.maxRetries, baseretry.NewFibonacci(retry.fiboDuration))
+
+	return func(ctx context.Context, userid int64, profile *models.RiskProfile) error {
+		ctx, cancel := context.WithTimeout(ctx, retry.timeout)
+		defer cancel()
+		err := baseretry.Do(ctx, bf, func(ctx context.Context) error {
+			err := f(ctx, userid, profile)
+			if errors.Is(retry.skippedErrors, err) {
+				return err
+			}
+			return baseretry.RetryableError(err)
+		})
+		return err
+	}
+}
+
+func (retry *Retry) RiskEventFuncRetry(f RiskEventFunc) RiskEventFunc {
+	bf := baseretry.WithMaxRetries(retry.maxRetries, baseretry.NewFibonacci(retry.fiboDuration))
+
+	return func(ctx context.Context, event *models.RiskEvent) error {
+		ctx, cancel := context.WithTimeout(ctx, retry.timeout)
+		defer cancel()
+		err := baseretry.Do(ctx, bf, func(ctx context.Context) error {
+			err := f(ctx, event)
+			if errors.Is(retry.skippedErrors, err) {
+				return err
+			}
+			return baseretry.RetryableError(err)
+		})
+		return err
+	}
+}
+
+func (retry *Retry) VoucherFuncRetry(f VoucherFunc) VoucherFunc {
+	bf := baseretry.WithMaxRetries(retry.maxRetries, baseretry.NewFibonacci(retry.fiboDuration))
+
+	return func(ctx context.Context, userid int64, redemption *models.VoucherRedemption) error {
+		ctx, cancel := context.WithTimeout(ctx, retry.timeout)
+		defer cancel()
+		err := baseretry.Do(ctx, bf, func(ctx context.Context) error {
+			err := f(ctx, userid, redemption)
+			if errors.Is(retry.skippedErrors, err) {
+				return err
+			}
+			return baseretry.RetryableError(err)
+		})
+		return err
+	}
+}
+
+func (retry *Retry) PaymentTokenFuncRetry(f PaymentTokenFunc) PaymentTokenFunc {
+	bf := baseretry.WithMaxRetries(retry.maxRetries, baseretry.NewFibonacci(retry.fiboDuration))
+
+	return func(ctx context.Context, userid int64, token *models.PaymentToken) error {
+		ctx, cancel := context.WithTimeout(ctx, retry.timeout)
+		defer cancel()
+		err := baseretry.Do(ctx, bf, func(ctx context.Context) error {
+			err := f(ctx, userid, token)
+			if errors.Is(retry.skippedErrors, err) {
+				return err
+			}
+			return baseretry.RetryableError(err)
+		})
+		return err
+	}
+}
+
+func (retry *Retry) AccrualSaveFuncRetry(f AccrualSaveFunc) AccrualSaveFunc {
+	bf := baseretry.WithMaxRetries(retry.maxRetries, baseretry.NewFibonacci(retry.fiboDuration))
+
+	return func(ctx context.Context, accrual []models.Accrual) error {
+		ctx, cancel := context.WithTimeout(ctx, retry.timeout)
+		defer cancel()
+		err := baseretry.Do(ctx, bf, func(ctx context.Context) error {
+			err := f(ctx, accrual)
+			if errors.Is(retry.skippedErrors, err) {
+				return err
+			}
+			return baseretry.RetryableError(err)
+		})
+		return err
+	}
+}
+
 func (retry *Retry) SetParams(fibotime, timeout time.Duration, maxretries uint64) {
 	retry.fiboDuration = fibotime
 	retry.maxRetries = maxretries
@@ -148,6 +380,26 @@ func NewRetry() *Retry {
 			prjerrors.ErrOtherOrderAlreadyExists,
 			prjerrors.ErrEmptyData,
 			prjerrors.ErrNotEnough,
+			prjerrors.ErrOrderNotFound,
+			prjerrors.ErrTransferLimit,
+			prjerrors.ErrSelfTransfer,
+			prjerrors.ErrReferralCode,
+			prjerrors.ErrSelfReferral,
+			prjerrors.ErrWithdrawalNotPending,
+			prjerrors.ErrWithdrawalLimit,
+			prjerrors.ErrVoucherNotFound,
+			prjerrors.ErrVoucherExpired,
+			prjerrors.ErrVoucherRedeemed,
+			prjerrors.ErrCampaignNotFound,
+			prjerrors.ErrAuthorizationNotFound,
+			prjerrors.ErrAuthorizationState,
+			prjerrors.ErrAuthorizationAmount,
+			prjerrors.ErrPaymentToken,
+			prjerrors.ErrReversalAmount,
+			prjerrors.ErrWithdrawalNotFinal,
+			prjerrors.ErrWithdrawalLimitsNotSet,
+			prjerrors.ErrRiskEventNotFound,
+			prjerrors.ErrRiskEventReviewed,
 		),
 	}
 }
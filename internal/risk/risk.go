@@ -0,0 +1,153 @@
+package risk
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+const (
+	RuleInvalidOrders  = "invalid_orders"
+	RuleFastWithdrawal = "fast_withdrawal"
+)
+
+// Rule is a check of user event against the user history.
+type Rule interface {
+	Name() string
+	// Evaluate gives models.RiskAllow when the event does not match.
+	Evaluate(event *models.RiskEvent, profile *models.RiskProfile, now time.Time) string
+}
+
+// Engine evaluates events with all its rules, the most severe action wins.
+type Engine struct {
+	rules []Rule
+}
+
+func NewEngine(rules ...Rule) *Engine {
+	return &Engine{rules: rules}
+}
+
+func severity(action string) int {
+	switch action {
+	case models.RiskFlag:
+		return 1
+	case models.RiskBlock:
+		return 2
+	}
+	return 0
+}
+
+// Evaluate sets event action and the rule that gave it, the first rule
+// wins among rules of the same severity.
+func (e *Engine) Evaluate(event *models.RiskEvent, profile *models.RiskProfile, now time.Time) {
+	event.Action = models.RiskAllow
+	event.Rule = ""
+	for _, rule := range e.rules {
+		if action := rule.Evaluate(event, profile, now); severity(action) > severity(event.Action) {
+			event.Action = action
+			event.Rule = rule.Name()
+		}
+	}
+}
+
+// InvalidOrders matches order submissions once Streak Luhn invalid ones
+// were sent in a row, a valid number found that way matches too.
+type InvalidOrders struct {
+	Streak int
+	Action string
+}
+
+func (r *InvalidOrders) Name() string {
+	return RuleInvalidOrders
+}
+
+func (r *InvalidOrders) Evaluate(event *models.RiskEvent, profile *models.RiskProfile, now time.Time) string {
+	if event.Kind != models.RiskEventOrder {
+		return models.RiskAllow
+	}
+	streak := profile.InvalidStreak
+	if !event.Valid {
+		streak++
+	}
+	if streak >= r.Streak {
+		return r.Action
+	}
+	return models.RiskAllow
+}
+
+// FastWithdrawal matches withdrawals of at least Share of current balance
+// within Window after the user first accrual.
+type FastWithdrawal struct {
+	Window time.Duration
+	Share  float64
+	Action string
+}
+
+func (r *FastWithdrawal) Name() string {
+	return RuleFastWithdrawal
+}
+
+func (r *FastWithdrawal) Evaluate(event *models.RiskEvent, profile *models.RiskProfile, now time.Time) string {
+	if event.Kind != models.RiskEventWithdrawal || profile.FirstAccrualAt.IsZero() || profile.Current <= 0 {
+		return models.RiskAllow
+	}
+	if now.Sub(profile.FirstAccrualAt) < r.Window && event.Sum >= r.Share*profile.Current {
+		return r.Action
+	}
+	return models.RiskAllow
+}
+
+func parseAction(s string) (string, error) {
+	switch action := strings.ToUpper(s); action {
+	case models.RiskFlag, models.RiskBlock:
+		return action, nil
+	}
+	return "", prjerrors.ErrValidateRiskRules
+}
+
+// ParseRules parses comma separated list of rules with action last:
+// "invalid_orders:streak:action" and "fast_withdrawal:window:share:action",
+// action is flag or block.
+func ParseRules(s string) ([]Rule, error) {
+	var rules []Rule
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		parts := strings.Split(v, ":")
+		action, err := parseAction(parts[len(parts)-1])
+		if err != nil {
+			return nil, err
+		}
+		switch parts[0] {
+		case RuleInvalidOrders:
+			if len(parts) != 3 {
+				return nil, prjerrors.ErrValidateRiskRules
+			}
+			streak, err := strconv.Atoi(parts[1])
+			if err != nil || streak <= 0 {
+				return nil, prjerrors.ErrValidateRiskRules
+			}
+			rules = append(rules, &InvalidOrders{Streak: streak, Action: action})
+		case RuleFastWithdrawal:
+			if len(parts) != 4 {
+				return nil, prjerrors.ErrValidateRiskRules
+			}
+			window, err := time.ParseDuration(parts[1])
+			if err != nil || window <= 0 {
+				return nil, prjerrors.ErrValidateRiskRules
+			}
+			share, err := strconv.ParseFloat(parts[2], 64)
+			if err != nil || share <= 0 || share > 1 {
+				return nil, prjerrors.ErrValidateRiskRules
+			}
+			rules = append(rules, &FastWithdrawal{Window: window, Share: share, Action: action})
+		default:
+			return nil, prjerrors.ErrValidateRiskRules
+		}
+	}
+	return rules, nil
+}
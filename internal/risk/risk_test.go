@@ -0,0 +1,86 @@
+package risk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("invalid_orders:5:block, fast_withdrawal:15m:0.9:FLAG")
+	require.NoError(t, err)
+	assert.Equal(t, []Rule{
+		&InvalidOrders{Streak: 5, Action: models.RiskBlock},
+		&FastWithdrawal{Window: 15 * time.Minute, Share: 0.9, Action: models.RiskFlag},
+	}, rules)
+
+	rules, err = ParseRules("")
+	require.NoError(t, err)
+	assert.Empty(t, rules)
+
+	for _, v := range []string{"invalid_orders:5", "invalid_orders:0:flag", "invalid_orders:5:allow", "unknown:flag",
+		"fast_withdrawal:15m:flag", "fast_withdrawal:x:0.9:flag", "fast_withdrawal:15m:2:flag"} {
+		_, err := ParseRules(v)
+		require.ErrorIs(t, err, prjerrors.ErrValidateRiskRules, v)
+	}
+}
+
+func TestInvalidOrders(t *testing.T) {
+	rule := &InvalidOrders{Streak: 3, Action: models.RiskBlock}
+	order := func(valid bool) *models.RiskEvent {
+		return &models.RiskEvent{Kind: models.RiskEventOrder, Valid: valid}
+	}
+
+	assert.Equal(t, models.RiskAllow, rule.Evaluate(order(false), &models.RiskProfile{InvalidStreak: 1}, time.Now()))
+	assert.Equal(t, models.RiskBlock, rule.Evaluate(order(false), &models.RiskProfile{InvalidStreak: 2}, time.Now()))
+	// valid number found by guessing
+	assert.Equal(t, models.RiskBlock, rule.Evaluate(order(true), &models.RiskProfile{InvalidStreak: 3}, time.Now()))
+	assert.Equal(t, models.RiskAllow, rule.Evaluate(order(true), &models.RiskProfile{InvalidStreak: 2}, time.Now()))
+	assert.Equal(t, models.RiskAllow, rule.Evaluate(&models.RiskEvent{Kind: models.RiskEventWithdrawal},
+		&models.RiskProfile{InvalidStreak: 10}, time.Now()))
+}
+
+func TestFastWithdrawal(t *testing.T) {
+	now := time.Now()
+	rule := &FastWithdrawal{Window: 15 * time.Minute, Share: 0.9, Action: models.RiskFlag}
+	withdrawal := &models.RiskEvent{Kind: models.RiskEventWithdrawal, Sum: 95, Valid: true}
+
+	assert.Equal(t, models.RiskFlag, rule.Evaluate(withdrawal,
+		&models.RiskProfile{FirstAccrualAt: now.Add(-5 * time.Minute), Current: 100}, now))
+	assert.Equal(t, models.RiskAllow, rule.Evaluate(withdrawal,
+		&models.RiskProfile{FirstAccrualAt: now.Add(-time.Hour), Current: 100}, now))
+	assert.Equal(t, models.RiskAllow, rule.Evaluate(withdrawal,
+		&models.RiskProfile{FirstAccrualAt: now.Add(-5 * time.Minute), Current: 1000}, now))
+	assert.Equal(t, models.RiskAllow, rule.Evaluate(withdrawal, &models.RiskProfile{Current: 100}, now))
+}
+
+func TestEngine(t *testing.T) {
+	now := time.Now()
+	e := NewEngine(
+		&FastWithdrawal{Window: time.Hour, Share: 0.5, Action: models.RiskFlag},
+		&InvalidOrders{Streak: 3, Action: models.RiskFlag},
+		&InvalidOrders{Streak: 5, Action: models.RiskBlock},
+	)
+
+	event := &models.RiskEvent{Kind: models.RiskEventOrder, Valid: true}
+	e.Evaluate(event, &models.RiskProfile{}, now)
+	assert.Equal(t, models.RiskAllow, event.Action)
+	assert.Empty(t, event.Rule)
+
+	event = &models.RiskEvent{Kind: models.RiskEventOrder}
+	e.Evaluate(event, &models.RiskProfile{InvalidStreak: 3}, now)
+	assert.Equal(t, models.RiskFlag, event.Action)
+	assert.Equal(t, RuleInvalidOrders, event.Rule)
+
+	e.Evaluate(event, &models.RiskProfile{InvalidStreak: 4}, now)
+	assert.Equal(t, models.RiskBlock, event.Action)
+
+	event = &models.RiskEvent{Kind: models.RiskEventWithdrawal, Sum: 60, Valid: true}
+	e.Evaluate(event, &models.RiskProfile{FirstAccrualAt: now.Add(-time.Minute), Current: 100}, now)
+	assert.Equal(t, models.RiskFlag, event.Action)
+	assert.Equal(t, RuleFastWithdrawal, event.Rule)
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sourcecd/gofermart/internal/accrual"
+	"github.com/sourcecd/gofermart/internal/crypto"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/sourcecd/gofermart/internal/storage"
+)
+
+func sleepCtx(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
+
+// accrualRegisterOrder registers the order with stored receipt, it
+// recovers registrations failed or lost (local mode restart) on upload.
+func accrualRegisterOrder(ctx context.Context, db storage.Store, cl accrual.Client, number int64) error {
+	goods, err := db.OrderGoods(ctx, number)
+	if err != nil {
+		return err
+	}
+	if len(goods) == 0 {
+		return nil
+	}
+	err = cl.RegisterOrder(ctx, &models.AccrualOrder{
+		Order: fmt.Sprint(number),
+		Goods: goods,
+	})
+	if err != nil && !errors.Is(err, prjerrors.ErrAccrualAlreadyExists) {
+		return err
+	}
+	return nil
+}
+
+func accrualSystemPoll(ctx context.Context, db storage.Store, cl accrual.Client, register bool) error {
+	var orders []int64
+	var listParsedOrders []models.Accrual
+	var attempts []models.PollAttempt
+	var retryAfter time.Duration
+
+	if err := db.AccrualSystemPoll(ctx, &orders); err != nil {
+		return err
+	}
+
+poll:
+	for _, v := range orders {
+		parsedOrder, err := cl.GetOrder(ctx, fmt.Sprint(v))
+		if err != nil {
+			var tooMany *accrual.TooManyRequestsError
+			switch {
+			case errors.As(err, &tooMany):
+				// save what we already have and wait before the next cycle
+				slog.Warn(err.Error())
+				retryAfter = tooMany.RetryAfter
+				break poll
+			case errors.Is(err, prjerrors.ErrAccrualCircuitOpen):
+				// accrual system is down, skip the whole cycle
+				break poll
+			case errors.Is(err, prjerrors.ErrAccrualNotRegistered):
+				if register {
+					if err := accrualRegisterOrder(ctx, db, cl, v); err != nil {
+						slog.Error(err.Error())
+					}
+				}
+			default:
+				slog.Error(err.Error())
+			}
+			attempts = append(attempts, models.PollAttempt{Number: v, Error: err.Error()})
+			continue
+		}
+		attempts = append(attempts, models.PollAttempt{Number: v})
+		listParsedOrders = append(listParsedOrders, *parsedOrder)
+	}
+	if len(listParsedOrders) > 0 {
+		if err := db.AccrualSystemSave(ctx, listParsedOrders); err != nil {
+			return err
+		}
+	}
+	if len(attempts) > 0 {
+		if err := db.AccrualPollAttempts(ctx, attempts); err != nil {
+			return err
+		}
+	}
+	sleepCtx(ctx, retryAfter)
+	return nil
+}
+
+func loadRewardRules(ctx context.Context, db storage.Store, rules *accrual.Rules) error {
+	var list []models.RewardRule
+	if err := db.ListRewardRules(ctx, &list); err != nil {
+		if errors.Is(err, prjerrors.ErrEmptyData) {
+			return nil
+		}
+		return err
+	}
+	for _, v := range list {
+		if err := rules.Add(v.AccrualGoods); err != nil && !errors.Is(err, prjerrors.ErrAccrualAlreadyExists) {
+			return err
+		}
+	}
+	return nil
+}
+
+// accrualDeadLetter moves orders stuck in polling to dead letter.
+func accrualDeadLetter(ctx context.Context, db storage.Store, maxAge time.Duration, maxAttempts int) error {
+	if maxAge <= 0 && maxAttempts <= 0 {
+		return nil
+	}
+	count, err := db.AccrualDeadLetter(ctx, maxAge, maxAttempts)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		slog.Warn("orders moved to dead letter", slog.Int64("count", count))
+	}
+	return nil
+}
+
+// accrualCallbackParse accepts one accrual result object or an array of them.
+func accrualCallbackParse(body []byte) ([]models.Accrual, error) {
+	var accruals []models.Accrual
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		if err := json.Unmarshal(body, &accruals); err != nil {
+			return nil, prjerrors.ErrReqJSONParse
+		}
+	} else {
+		var one models.Accrual
+		if err := json.Unmarshal(body, &one); err != nil {
+			return nil, prjerrors.ErrReqJSONParse
+		}
+		accruals = append(accruals, one)
+	}
+	for _, v := range accruals {
+		if _, err := strconv.Atoi(v.Order); err != nil {
+			return nil, prjerrors.ErrReqJSONParse
+		}
+		if !v.Status.Valid() {
+			return nil, prjerrors.ErrReqJSONParse
+		}
+	}
+	return accruals, nil
+}
+
+// accrualCallback is pushed by the accrual system, the body is signed
+// with HMAC-SHA256 of the shared secret in X-Signature header.
+func (h *handlers) accrualCallback() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.webhookSecret == "" {
+			http.Error(w, "404 page not found", http.StatusNotFound)
+			return
+		}
+		if err := checkContentType(r, "application/json"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !crypto.CheckHMAC(body, h.webhookSecret, r.Header.Get("X-Signature")) {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		accruals, err := accrualCallbackParse(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := h.retry.AccrualSaveFuncRetry(h.db.AccrualSystemSave)(h.ctx, accruals); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("\n"))
+	}
+}
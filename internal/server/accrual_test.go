@@ -0,0 +1,230 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sourcecd/gofermart/internal/accrual"
+	"github.com/sourcecd/gofermart/internal/accrual/accrualtest"
+	"github.com/sourcecd/gofermart/internal/crypto"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/sourcecd/gofermart/internal/retry"
+	"github.com/sourcecd/gofermart/internal/storage/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccrualSystemPoll(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	srv := accrualtest.NewServer()
+	defer srv.Close()
+	srv.SetOrderResponses("12345678903", accrualtest.Processed("12345678903", 500))
+	srv.SetOrderResponses("9278923470", accrualtest.Status("9278923470", models.StatusProcessing))
+	srv.SetOrderResponses("346436439", accrualtest.Response{StatusCode: http.StatusInternalServerError})
+
+	var ordersPtr *[]int64
+	db.EXPECT().AccrualSystemPoll(gomock.Any(), gomock.AssignableToTypeOf(ordersPtr)).DoAndReturn(
+		func(ctx context.Context, orders *[]int64) error {
+			*orders = append(*orders, 12345678903, 9278923470, 346436439, 79927398713)
+			return nil
+		})
+	db.EXPECT().AccrualSystemSave(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, accruals []models.Accrual) error {
+			require.Len(t, accruals, 2)
+			assert.Equal(t, models.StatusProcessed, accruals[0].Status)
+			assert.Equal(t, 500.0, *accruals[0].Accrual)
+			assert.Equal(t, models.StatusProcessing, accruals[1].Status)
+			return nil
+		})
+	db.EXPECT().AccrualPollAttempts(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, attempts []models.PollAttempt) error {
+			require.Len(t, attempts, 4)
+			assert.Equal(t, models.PollAttempt{Number: 12345678903}, attempts[0])
+			assert.Equal(t, models.PollAttempt{Number: 9278923470}, attempts[1])
+			assert.Equal(t, int64(346436439), attempts[2].Number)
+			assert.Equal(t, prjerrors.ErrAccrualInternal.Error(), attempts[2].Error)
+			assert.Equal(t, prjerrors.ErrAccrualNotRegistered.Error(), attempts[3].Error)
+			return nil
+		})
+
+	err := accrualSystemPoll(context.Background(), db, accrual.NewHTTPClient(srv.URL, time.Second), false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, srv.Requests("79927398713"))
+}
+
+func TestAccrualSystemPollTooManyRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	srv := accrualtest.NewServer()
+	defer srv.Close()
+	srv.SetOrderResponses("12345678903", accrualtest.Processed("12345678903", 500))
+	srv.SetOrderResponses("9278923470", accrualtest.TooManyRequests("0"))
+
+	var ordersPtr *[]int64
+	db.EXPECT().AccrualSystemPoll(gomock.Any(), gomock.AssignableToTypeOf(ordersPtr)).DoAndReturn(
+		func(ctx context.Context, orders *[]int64) error {
+			*orders = append(*orders, 12345678903, 9278923470, 346436439)
+			return nil
+		})
+	db.EXPECT().AccrualSystemSave(gomock.Any(), gomock.Len(1)).Return(nil)
+	db.EXPECT().AccrualPollAttempts(gomock.Any(), []models.PollAttempt{{Number: 12345678903}}).Return(nil)
+
+	err := accrualSystemPoll(context.Background(), db, accrual.NewHTTPClient(srv.URL, time.Second), false)
+	require.NoError(t, err)
+	// poll cycle is stopped after 429
+	assert.Equal(t, 0, srv.Requests("346436439"))
+}
+
+func TestAccrualSystemPollCircuitOpen(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	srv := accrualtest.NewServer()
+	defer srv.Close()
+	srv.SetOrderResponses("12345678903", accrualtest.Response{StatusCode: http.StatusInternalServerError})
+
+	var ordersPtr *[]int64
+	db.EXPECT().AccrualSystemPoll(gomock.Any(), gomock.AssignableToTypeOf(ordersPtr)).DoAndReturn(
+		func(ctx context.Context, orders *[]int64) error {
+			*orders = append(*orders, 12345678903, 9278923470, 346436439)
+			return nil
+		})
+	db.EXPECT().AccrualPollAttempts(gomock.Any(), []models.PollAttempt{
+		{Number: 12345678903, Error: prjerrors.ErrAccrualInternal.Error()},
+	}).Return(nil)
+
+	cl := accrual.NewFailoverClient(accrual.NewEndpoint(srv.URL, accrual.NewHTTPClient(srv.URL, time.Second), accrual.NewBreaker(1, time.Minute, 1)))
+	err := accrualSystemPoll(context.Background(), db, cl, false)
+	require.NoError(t, err)
+	assert.Equal(t, 0, srv.Requests("9278923470"))
+	assert.Equal(t, 0, srv.Requests("346436439"))
+}
+
+func TestAccrualCallback(t *testing.T) {
+	const webhookSecret = "eeZ3ieph6iew"
+
+	accrualVal := 500.0
+	testCases := []struct {
+		name       string
+		body       string
+		sign       string
+		expCode    int
+		expAccrual []models.Accrual
+	}{
+		{
+			name:    "one",
+			body:    `{"order": "12345678903", "status": "PROCESSED", "accrual": 500}`,
+			expCode: http.StatusOK,
+			expAccrual: []models.Accrual{
+				{Order: "12345678903", Status: "PROCESSED", Accrual: &accrualVal},
+			},
+		},
+		{
+			name:    "many",
+			body:    `[{"order": "12345678903", "status": "PROCESSED", "accrual": 500}, {"order": "9278923470", "status": "INVALID"}]`,
+			expCode: http.StatusOK,
+			expAccrual: []models.Accrual{
+				{Order: "12345678903", Status: "PROCESSED", Accrual: &accrualVal},
+				{Order: "9278923470", Status: "INVALID"},
+			},
+		},
+		{
+			name:    "wrongSign",
+			body:    `{"order": "12345678903", "status": "PROCESSED", "accrual": 500}`,
+			sign:    crypto.GenerateHMAC([]byte("other"), webhookSecret),
+			expCode: http.StatusUnauthorized,
+		},
+		{
+			name:    "unknownStatus",
+			body:    `{"order": "12345678903", "status": "DONE"}`,
+			expCode: http.StatusBadRequest,
+		},
+		{
+			name:    "wrongNumber",
+			body:    `{"order": "abc", "status": "PROCESSED"}`,
+			expCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db := mock.NewMockStore(ctrl)
+
+			h := &handlers{
+				ctx:           context.Background(),
+				seckey:        seckey,
+				db:            db,
+				retry:         retry.NewRetry(),
+				webhookSecret: webhookSecret,
+			}
+			if v.expAccrual != nil {
+				db.EXPECT().AccrualSystemSave(gomock.Any(), v.expAccrual).Return(nil)
+			}
+
+			sign := v.sign
+			if sign == "" {
+				sign = crypto.GenerateHMAC([]byte(v.body), webhookSecret)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(v.body))
+			r.Header.Add("Content-Type", "application/json")
+			r.Header.Add("X-Signature", sign)
+			w := httptest.NewRecorder()
+
+			h.accrualCallback()(w, r)
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, v.expCode, res.StatusCode)
+		})
+	}
+}
+
+func TestAccrualDeadLetter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	// disabled limits do not touch storage
+	require.NoError(t, accrualDeadLetter(context.Background(), db, 0, 0))
+
+	db.EXPECT().AccrualDeadLetter(gomock.Any(), time.Hour, 10).Return(int64(2), nil)
+	require.NoError(t, accrualDeadLetter(context.Background(), db, time.Hour, 10))
+}
+
+func TestAccrualSystemPollRegister(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	srv := accrualtest.NewServer()
+	defer srv.Close()
+
+	goods := []models.Good{{Description: "Чайник Bork", Price: 7000}}
+	var ordersPtr *[]int64
+	db.EXPECT().AccrualSystemPoll(gomock.Any(), gomock.AssignableToTypeOf(ordersPtr)).DoAndReturn(
+		func(ctx context.Context, orders *[]int64) error {
+			*orders = append(*orders, 12345678903, 9278923470)
+			return nil
+		})
+	db.EXPECT().OrderGoods(gomock.Any(), int64(12345678903)).Return(goods, nil)
+	db.EXPECT().OrderGoods(gomock.Any(), int64(9278923470)).Return(nil, nil)
+	db.EXPECT().AccrualPollAttempts(gomock.Any(), gomock.Len(2)).Return(nil)
+
+	err := accrualSystemPoll(context.Background(), db, accrual.NewHTTPClient(srv.URL, time.Second), true)
+	require.NoError(t, err)
+	// only orders with receipt are registered
+	assert.Equal(t, []models.AccrualOrder{{Order: "12345678903", Goods: goods}}, srv.RegisteredOrders())
+}
@@ -0,0 +1,309 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sourcecd/gofermart/internal/accrual"
+	"github.com/sourcecd/gofermart/internal/auth"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+// errAdminLookup marks storage failures while resolving admin login,
+// they are server errors and not an authentication failure.
+var errAdminLookup = errors.New("admin lookup failed")
+
+// checkAdmin authenticates request user and checks it is in admin logins,
+// admin login is returned for audit.
+func (h *handlers) checkAdmin(ctx context.Context, r *http.Request) (string, error) {
+	gettoken, err := checkRequestCreds(r)
+	if err != nil {
+		return "", err
+	}
+	userid, err := auth.ParseJWT(gettoken, h.seckey)
+	if err != nil {
+		return "", err
+	}
+	var login string
+	if err := h.retry.Do(ctx, func(ctx context.Context) (err error) {
+		login, err = h.db.GetUserLogin(ctx, userid)
+		return err
+	}); err != nil {
+		if errors.Is(err, prjerrors.ErrNotExists) {
+			return "", err
+		}
+		return "", fmt.Errorf("%w: %w", errAdminLookup, err)
+	}
+	if !slices.Contains(h.admins, login) {
+		return "", prjerrors.ErrNotAdmin
+	}
+	return login, nil
+}
+
+func adminError(w http.ResponseWriter, err error) {
+	if errors.Is(err, prjerrors.ErrNotAdmin) {
+		http.Error(w, "403 Forbidden", http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, errAdminLookup) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+}
+
+func (h *handlers) deadLetters() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, err := h.checkAdmin(h.ctx, r); err != nil {
+			adminError(w, err)
+			return
+		}
+
+		var orders []models.DeadLetterOrder
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.ListDeadLetters(ctx, &orders) }); err != nil {
+			if errors.Is(err, prjerrors.ErrEmptyData) {
+				http.Error(w, err.Error(), http.StatusNoContent)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, orders)
+	}
+}
+
+func (h *handlers) requeueDeadLetter() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, err := h.checkAdmin(h.ctx, r); err != nil {
+			adminError(w, err)
+			return
+		}
+
+		num, err := orderNumberParam(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.RequeueDeadLetter(ctx, num) }); err != nil {
+			if errors.Is(err, prjerrors.ErrOrderNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("\n"))
+	}
+}
+
+func accrualError(w http.ResponseWriter, err error) {
+	var tooMany *accrual.TooManyRequestsError
+	switch {
+	case errors.As(err, &tooMany):
+		w.Header().Set("Retry-After", strconv.Itoa(int(tooMany.RetryAfter.Seconds())))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, prjerrors.ErrAccrualCircuitOpen):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, prjerrors.ErrAccrualNotRegistered):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, prjerrors.ErrAccrualBadRequest):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, prjerrors.ErrAccrualAlreadyExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	}
+}
+
+// createRewardRule forwards reward mechanic to the accrual system and
+// records it with author for audit.
+func (h *handlers) createRewardRule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := checkContentType(r, "application/json"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(h.ctx, h.retry.GetTimeoutCtx())
+		defer cancel()
+
+		author, err := h.checkAdmin(ctx, r)
+		if err != nil {
+			adminError(w, err)
+			return
+		}
+
+		rule := &models.RewardRule{Author: author}
+		if err := json.NewDecoder(r.Body).Decode(&rule.AccrualGoods); err != nil {
+			http.Error(w, prjerrors.ErrReqJSONParse.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := accrual.ValidateRule(&rule.AccrualGoods); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// audit record goes first, a rule is never live without its author
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.CreateRewardRule(ctx, rule) }); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := h.accrual.RegisterGoods(ctx, &rule.AccrualGoods); err != nil {
+			if err := h.retry.Do(h.ctx, func(ctx context.Context) error {
+				return h.db.SetRewardRuleStatus(ctx, rule.ID, models.RewardRuleFailed)
+			}); err != nil {
+				slog.Error("reward rule stays pending", slog.Int64("id", rule.ID), slog.String("error", err.Error()))
+			}
+			accrualError(w, err)
+			return
+		}
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error {
+			return h.db.SetRewardRuleStatus(ctx, rule.ID, models.RewardRuleActive)
+		}); err != nil {
+			slog.Error("reward rule is live in accrual but not confirmed", slog.Int64("id", rule.ID),
+				slog.String("match", rule.Match), slog.String("author", rule.Author), slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("\n"))
+	}
+}
+
+func (h *handlers) rewardRules() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, err := h.checkAdmin(h.ctx, r); err != nil {
+			adminError(w, err)
+			return
+		}
+
+		var rules []models.RewardRule
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.ListRewardRules(ctx, &rules) }); err != nil {
+			if errors.Is(err, prjerrors.ErrEmptyData) {
+				http.Error(w, err.Error(), http.StatusNoContent)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, rules)
+	}
+}
+
+// repollOrder asks the accrual system for the order right away and saves
+// the answer the same way the poller does.
+func (h *handlers) repollOrder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(h.ctx, h.retry.GetTimeoutCtx())
+		defer cancel()
+
+		if _, err := h.checkAdmin(ctx, r); err != nil {
+			adminError(w, err)
+			return
+		}
+
+		num, err := orderNumberParam(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		order, err := h.accrual.GetOrder(ctx, fmt.Sprint(num))
+		if errors.Is(err, prjerrors.ErrAccrualNotRegistered) && h.accrualRegister {
+			if err := accrualRegisterOrder(ctx, h.db, h.accrual, num); err != nil {
+				accrualError(w, err)
+				return
+			}
+			order, err = h.accrual.GetOrder(ctx, fmt.Sprint(num))
+		}
+		if err != nil {
+			accrualError(w, err)
+			return
+		}
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.AccrualSystemSave(ctx, []models.Accrual{*order}) }); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, order)
+	}
+}
+
+func overrideValidate(override *models.OrderOverride) error {
+	if !override.Status.Valid() || strings.TrimSpace(override.Reason) == "" {
+		return prjerrors.ErrValidateOverride
+	}
+	// only processed orders carry accrual
+	if (override.Status == models.StatusProcessed) != (override.Accrual != nil) {
+		return prjerrors.ErrValidateOverride
+	}
+	if override.Accrual != nil && *override.Accrual < 0 {
+		return prjerrors.ErrValidateOverride
+	}
+	return nil
+}
+
+// overrideOrder sets order status and accrual by support decision, balance
+// is corrected by the accrual difference and the override is logged.
+func (h *handlers) overrideOrder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := checkContentType(r, "application/json"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		author, err := h.checkAdmin(h.ctx, r)
+		if err != nil {
+			adminError(w, err)
+			return
+		}
+
+		num, err := orderNumberParam(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		override := &models.OrderOverride{}
+		if err := json.NewDecoder(r.Body).Decode(override); err != nil {
+			http.Error(w, prjerrors.ErrReqJSONParse.Error(), http.StatusBadRequest)
+			return
+		}
+		override.Number = fmt.Sprint(num)
+		override.Author = author
+		if err := overrideValidate(override); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.OverrideOrder(ctx, override) }); err != nil {
+			switch {
+			case errors.Is(err, prjerrors.ErrOrderNotFound):
+				http.Error(w, err.Error(), http.StatusNotFound)
+			case errors.Is(err, prjerrors.ErrNotEnough):
+				http.Error(w, err.Error(), http.StatusConflict)
+			default:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		slog.Info("order overridden",
+			slog.String("order", override.Number), slog.String("author", author), slog.String("reason", override.Reason),
+			slog.String("from", string(override.PrevStatus)), slog.String("to", string(override.Status)),
+			slog.Float64("correction", override.Correction))
+
+		writeJSON(w, http.StatusOK, override)
+	}
+}
@@ -0,0 +1,391 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/sourcecd/gofermart/internal/accrual"
+	"github.com/sourcecd/gofermart/internal/accrual/accrualtest"
+	"github.com/sourcecd/gofermart/internal/auth"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/sourcecd/gofermart/internal/retry"
+	"github.com/sourcecd/gofermart/internal/storage/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const adminLogin = "admin"
+
+func withURLParams(r *http.Request, params map[string]string) *http.Request {
+	rctx := chi.NewRouteContext()
+	for k, v := range params {
+		rctx.URLParams.Add(k, v)
+	}
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
+
+func withUserToken(t *testing.T, r *http.Request, userid int64) *http.Request {
+	token, err := auth.GenerateJWT(userid, seckey)
+	require.NoError(t, err)
+	r.AddCookie(&http.Cookie{
+		Name:  "Bearer",
+		Value: token,
+	})
+	return r
+}
+
+func TestCheckAdmin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	// storage failure is retried, keep it quick
+	rt := retry.NewRetry()
+	rt.SetParams(time.Millisecond, time.Second, 2)
+	h := &handlers{
+		ctx:    context.Background(),
+		seckey: seckey,
+		db:     db,
+		retry:  rt,
+		admins: []string{adminLogin},
+	}
+
+	db.EXPECT().GetUserLogin(gomock.Any(), userID).Return(adminLogin, nil)
+	db.EXPECT().GetUserLogin(gomock.Any(), userID+1).Return(login, nil)
+	db.EXPECT().GetUserLogin(gomock.Any(), userID+2).Return("", prjerrors.ErrNotExists)
+	db.EXPECT().GetUserLogin(gomock.Any(), userID+3).Return("", errors.New("conn refused")).Times(3)
+
+	author, err := h.checkAdmin(context.Background(), withUserToken(t, httptest.NewRequest(http.MethodGet, "/", nil), userID))
+	require.NoError(t, err)
+	assert.Equal(t, adminLogin, author)
+
+	_, err = h.checkAdmin(context.Background(), withUserToken(t, httptest.NewRequest(http.MethodGet, "/", nil), userID+1))
+	require.ErrorIs(t, err, prjerrors.ErrNotAdmin)
+
+	_, err = h.checkAdmin(context.Background(), httptest.NewRequest(http.MethodGet, "/", nil))
+	require.ErrorIs(t, err, prjerrors.ErrAuthCredsNotFound)
+
+	_, err = h.checkAdmin(context.Background(), withUserToken(t, httptest.NewRequest(http.MethodGet, "/", nil), userID+2))
+	require.ErrorIs(t, err, prjerrors.ErrNotExists)
+	w := httptest.NewRecorder()
+	adminError(w, err)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	_, err = h.checkAdmin(context.Background(), withUserToken(t, httptest.NewRequest(http.MethodGet, "/", nil), userID+3))
+	require.ErrorIs(t, err, errAdminLookup)
+	w = httptest.NewRecorder()
+	adminError(w, err)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestDeadLetters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	h := &handlers{
+		ctx:    context.Background(),
+		seckey: seckey,
+		db:     db,
+		retry:  retry.NewRetry(),
+		admins: []string{adminLogin},
+	}
+
+	var (
+		ordersPtr *[]models.DeadLetterOrder
+		testTime  = time.Now().Format(time.RFC3339)
+	)
+	db.EXPECT().GetUserLogin(gomock.Any(), userID).Return(adminLogin, nil)
+	db.EXPECT().ListDeadLetters(gomock.Any(), gomock.AssignableToTypeOf(ordersPtr)).DoAndReturn(
+		func(ctx context.Context, orders *[]models.DeadLetterOrder) error {
+			*orders = append(*orders, models.DeadLetterOrder{
+				Number:         "12345678903",
+				Login:          login,
+				Status:         "PROCESSING",
+				UploadedAt:     testTime,
+				Attempts:       10,
+				LastError:      "accrual system internal error",
+				DeadLetteredAt: testTime,
+			})
+			return nil
+		})
+
+	w := httptest.NewRecorder()
+	h.deadLetters()(w, withUserToken(t, httptest.NewRequest(http.MethodGet, "/", nil), userID))
+
+	res := w.Result()
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.JSONEq(t, `[{
+		"number": "12345678903",
+		"login": "test",
+		"status": "PROCESSING",
+		"uploaded_at": "`+testTime+`",
+		"attempts": 10,
+		"last_error": "accrual system internal error",
+		"dead_lettered_at": "`+testTime+`"
+	}]`, string(b))
+}
+
+func TestRequeueDeadLetter(t *testing.T) {
+	testCases := []struct {
+		name    string
+		number  string
+		login   string
+		dbErr   error
+		expCode int
+	}{
+		{name: "ok", number: "12345678903", login: adminLogin, expCode: http.StatusOK},
+		{name: "notFound", number: "12345678903", login: adminLogin, dbErr: prjerrors.ErrOrderNotFound, expCode: http.StatusNotFound},
+		{name: "badNumber", number: "abc", login: adminLogin, expCode: http.StatusBadRequest},
+		{name: "notAdmin", number: "12345678903", login: login, expCode: http.StatusForbidden},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db := mock.NewMockStore(ctrl)
+
+			h := &handlers{
+				ctx:    context.Background(),
+				seckey: seckey,
+				db:     db,
+				retry:  retry.NewRetry(),
+				admins: []string{adminLogin},
+			}
+
+			db.EXPECT().GetUserLogin(gomock.Any(), userID).Return(v.login, nil)
+			if v.login == adminLogin && v.number == "12345678903" {
+				db.EXPECT().RequeueDeadLetter(gomock.Any(), int64(12345678903)).Return(v.dbErr)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			r = withURLParams(withUserToken(t, r, userID), map[string]string{"number": v.number})
+			w := httptest.NewRecorder()
+			h.requeueDeadLetter()(w, r)
+
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, v.expCode, res.StatusCode)
+		})
+	}
+}
+
+func TestCreateRewardRule(t *testing.T) {
+	testCases := []struct {
+		name        string
+		body        string
+		goodsStatus int
+		expCode     int
+		expStatus   string
+	}{
+		{name: "ok", body: `{"match": "Bork", "reward": 10, "reward_type": "%"}`, goodsStatus: http.StatusOK, expCode: http.StatusOK, expStatus: models.RewardRuleActive},
+		{name: "wrongType", body: `{"match": "Bork", "reward": 10, "reward_type": "x"}`, goodsStatus: http.StatusOK, expCode: http.StatusBadRequest},
+		{name: "conflict", body: `{"match": "Bork", "reward": 10, "reward_type": "%"}`, goodsStatus: http.StatusConflict, expCode: http.StatusConflict, expStatus: models.RewardRuleFailed},
+		{name: "accrualDown", body: `{"match": "Bork", "reward": 10, "reward_type": "%"}`, goodsStatus: http.StatusInternalServerError, expCode: http.StatusBadGateway, expStatus: models.RewardRuleFailed},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db := mock.NewMockStore(ctrl)
+
+			srv := accrualtest.NewServer()
+			defer srv.Close()
+			srv.SetRegisterGoodsStatus(v.goodsStatus)
+
+			h := &handlers{
+				ctx:     context.Background(),
+				seckey:  seckey,
+				db:      db,
+				retry:   retry.NewRetry(),
+				accrual: accrual.NewHTTPClient(srv.URL, time.Second),
+				admins:  []string{adminLogin},
+			}
+
+			db.EXPECT().GetUserLogin(gomock.Any(), userID).Return(adminLogin, nil)
+			if v.expStatus != "" {
+				create := db.EXPECT().CreateRewardRule(gomock.Any(), &models.RewardRule{
+					AccrualGoods: models.AccrualGoods{Match: "Bork", Reward: 10, RewardType: "%"},
+					Author:       adminLogin,
+				}).DoAndReturn(func(ctx context.Context, rule *models.RewardRule) error {
+					rule.ID = 7
+					return nil
+				})
+				db.EXPECT().SetRewardRuleStatus(gomock.Any(), int64(7), v.expStatus).Return(nil).After(create)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(v.body))
+			r.Header.Add("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			h.createRewardRule()(w, withUserToken(t, r, userID))
+
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, v.expCode, res.StatusCode)
+			if v.expStatus == models.RewardRuleActive {
+				assert.Len(t, srv.RegisteredGoods(), 1)
+			}
+		})
+	}
+}
+
+func TestLoadRewardRules(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	var rulesPtr *[]models.RewardRule
+	db.EXPECT().ListRewardRules(gomock.Any(), gomock.AssignableToTypeOf(rulesPtr)).DoAndReturn(
+		func(ctx context.Context, rules *[]models.RewardRule) error {
+			*rules = append(*rules,
+				models.RewardRule{AccrualGoods: models.AccrualGoods{Match: "Bork", Reward: 10, RewardType: "%"}},
+				models.RewardRule{AccrualGoods: models.AccrualGoods{Match: "Tefal", Reward: 5, RewardType: "pt"}},
+			)
+			return nil
+		})
+
+	rules := accrual.NewRules()
+	require.NoError(t, rules.Add(models.AccrualGoods{Match: "Bork", Reward: 10, RewardType: "%"}))
+	require.NoError(t, loadRewardRules(context.Background(), db, rules))
+	assert.Len(t, rules.List(), 2)
+}
+
+func TestRepollOrder(t *testing.T) {
+	const number = "12345678903"
+	testCases := []struct {
+		name     string
+		response accrualtest.Response
+		expCode  int
+		expSave  bool
+	}{
+		{name: "processed", response: accrualtest.Processed(number, 500), expCode: http.StatusOK, expSave: true},
+		{name: "notRegistered", response: accrualtest.Response{StatusCode: http.StatusNoContent}, expCode: http.StatusNotFound},
+		{name: "tooManyRequests", response: accrualtest.TooManyRequests("60"), expCode: http.StatusTooManyRequests},
+		{name: "accrualDown", response: accrualtest.Response{StatusCode: http.StatusInternalServerError}, expCode: http.StatusBadGateway},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db := mock.NewMockStore(ctrl)
+
+			srv := accrualtest.NewServer()
+			defer srv.Close()
+			srv.SetOrderResponses(number, v.response)
+
+			h := &handlers{
+				ctx:     context.Background(),
+				seckey:  seckey,
+				db:      db,
+				retry:   retry.NewRetry(),
+				accrual: accrual.NewHTTPClient(srv.URL, time.Second),
+				admins:  []string{adminLogin},
+			}
+
+			db.EXPECT().GetUserLogin(gomock.Any(), userID).Return(adminLogin, nil)
+			if v.expSave {
+				accrualSum := 500.0
+				db.EXPECT().AccrualSystemSave(gomock.Any(), []models.Accrual{
+					{Order: number, Status: models.StatusProcessed, Accrual: &accrualSum},
+				}).Return(nil)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			r = withURLParams(withUserToken(t, r, userID), map[string]string{"number": number})
+			w := httptest.NewRecorder()
+			h.repollOrder()(w, r)
+
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, v.expCode, res.StatusCode)
+			assert.Equal(t, 1, srv.Requests(number))
+		})
+	}
+}
+
+func TestOverrideOrder(t *testing.T) {
+	testCases := []struct {
+		name    string
+		body    string
+		dbErr   error
+		expCode int
+		expBody string
+	}{
+		{
+			name:    "ok",
+			body:    `{"status": "PROCESSED", "accrual": 300, "reason": "customer complaint"}`,
+			expCode: http.StatusOK,
+			expBody: `{"number": "12345678903", "status": "PROCESSED", "accrual": 300, "reason": "customer complaint",
+				"author": "admin", "prev_status": "PROCESSED", "prev_accrual": 500, "correction": -200}`,
+		},
+		{name: "noReason", body: `{"status": "PROCESSED", "accrual": 300, "reason": " "}`, expCode: http.StatusBadRequest},
+		{name: "noAccrual", body: `{"status": "PROCESSED", "reason": "customer complaint"}`, expCode: http.StatusBadRequest},
+		{name: "invalidWithAccrual", body: `{"status": "INVALID", "accrual": 300, "reason": "fraud"}`, expCode: http.StatusBadRequest},
+		{name: "unknownStatus", body: `{"status": "DONE", "reason": "fraud"}`, expCode: http.StatusBadRequest},
+		{name: "notFound", body: `{"status": "INVALID", "reason": "fraud"}`, dbErr: prjerrors.ErrOrderNotFound, expCode: http.StatusNotFound},
+		{name: "notEnough", body: `{"status": "INVALID", "reason": "fraud"}`, dbErr: prjerrors.ErrNotEnough, expCode: http.StatusConflict},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db := mock.NewMockStore(ctrl)
+
+			h := &handlers{
+				ctx:    context.Background(),
+				seckey: seckey,
+				db:     db,
+				retry:  retry.NewRetry(),
+				admins: []string{adminLogin},
+			}
+
+			db.EXPECT().GetUserLogin(gomock.Any(), userID).Return(adminLogin, nil)
+			if v.expCode != http.StatusBadRequest {
+				var overridePtr *models.OrderOverride
+				db.EXPECT().OverrideOrder(gomock.Any(), gomock.AssignableToTypeOf(overridePtr)).DoAndReturn(
+					func(ctx context.Context, override *models.OrderOverride) error {
+						assert.Equal(t, adminLogin, override.Author)
+						prev := 500.0
+						override.PrevStatus = models.StatusProcessed
+						override.PrevAccrual = &prev
+						override.Correction = -prev
+						if override.Accrual != nil {
+							override.Correction += *override.Accrual
+						}
+						return v.dbErr
+					})
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(v.body))
+			r.Header.Add("Content-Type", "application/json")
+			r = withURLParams(withUserToken(t, r, userID), map[string]string{"number": "12345678903"})
+			w := httptest.NewRecorder()
+			h.overrideOrder()(w, r)
+
+			res := w.Result()
+			b, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			assert.Equal(t, v.expCode, res.StatusCode)
+			if v.expBody != "" {
+				assert.JSONEq(t, v.expBody, string(b))
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+// withdrawalApprovals lists withdrawals waiting for approval, or decided
+// ones with status query param.
+func (h *handlers) withdrawalApprovals() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, err := h.checkAdmin(h.ctx, r); err != nil {
+			adminError(w, err)
+			return
+		}
+		status := r.URL.Query().Get("status")
+		switch status {
+		case "":
+			status = models.WithdrawalPendingApproval
+		case models.WithdrawalPendingApproval, models.WithdrawalApproved, models.WithdrawalRejected:
+		default:
+			http.Error(w, "wrong status", http.StatusBadRequest)
+			return
+		}
+
+		var approvals []models.WithdrawalApproval
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.ListWithdrawalApprovals(ctx, status, &approvals) }); err != nil {
+			if errors.Is(err, prjerrors.ErrEmptyData) {
+				http.Error(w, err.Error(), http.StatusNoContent)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, approvals)
+	}
+}
+
+// decideWithdrawal approves or rejects pending withdrawal, reject body may
+// carry {"reason": "..."}.
+func (h *handlers) decideWithdrawal(approve bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		author, err := h.checkAdmin(h.ctx, r)
+		if err != nil {
+			adminError(w, err)
+			return
+		}
+		num, err := orderNumberParam(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var body struct {
+			Reason string `json:"reason"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, prjerrors.ErrReqJSONParse.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		decision := &models.WithdrawalDecision{Number: num, Approve: approve, Author: author, Reason: body.Reason}
+
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.DecideWithdrawal(ctx, decision) }); err != nil {
+			switch {
+			case errors.Is(err, prjerrors.ErrOrderNotFound):
+				http.Error(w, err.Error(), http.StatusNotFound)
+			case errors.Is(err, prjerrors.ErrWithdrawalNotPending):
+				http.Error(w, err.Error(), http.StatusConflict)
+			default:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("\n"))
+	}
+}
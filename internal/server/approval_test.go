@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/sourcecd/gofermart/internal/retry"
+	"github.com/sourcecd/gofermart/internal/storage/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithdrawPendingApproval(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	h := &handlers{
+		ctx:    context.Background(),
+		seckey: seckey,
+		db:     db,
+		retry:  retry.NewRetry(),
+	}
+
+	var withdrawPtr *models.Withdraw
+	db.EXPECT().Withdraw(gomock.Any(), userID, gomock.AssignableToTypeOf(withdrawPtr)).DoAndReturn(
+		func(ctx context.Context, userid int64, withdraw *models.Withdraw) error {
+			withdraw.Status = models.WithdrawalPendingApproval
+			return nil
+		})
+
+	// status can not be set by the client
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"order": "12345678903", "sum": 50000, "Status": "APPROVED"}`))
+	r.Header.Add("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.withdraw()(w, withUserToken(t, r, userID))
+
+	res := w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+}
+
+func TestDecideWithdrawal(t *testing.T) {
+	const number = "12345678903"
+	testCases := []struct {
+		name      string
+		approve   bool
+		body      string
+		expReason string
+		dbErr     error
+		expCode   int
+	}{
+		{name: "approve", approve: true, expCode: http.StatusOK},
+		{name: "reject", body: `{"reason": "suspicious"}`, expReason: "suspicious", expCode: http.StatusOK},
+		{name: "already_decided", approve: true, dbErr: prjerrors.ErrWithdrawalNotPending, expCode: http.StatusConflict},
+		{name: "not_found", dbErr: prjerrors.ErrOrderNotFound, expCode: http.StatusNotFound},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db := mock.NewMockStore(ctrl)
+
+			h := &handlers{
+				ctx:    context.Background(),
+				seckey: seckey,
+				db:     db,
+				retry:  retry.NewRetry(),
+				admins: []string{adminLogin},
+			}
+
+			db.EXPECT().GetUserLogin(gomock.Any(), userID).Return(adminLogin, nil)
+			db.EXPECT().DecideWithdrawal(gomock.Any(), &models.WithdrawalDecision{
+				Number:  12345678903,
+				Approve: v.approve,
+				Author:  adminLogin,
+				Reason:  v.expReason,
+			}).Return(v.dbErr)
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(v.body))
+			w := httptest.NewRecorder()
+			h.decideWithdrawal(v.approve)(w, withURLParams(withUserToken(t, r, userID), map[string]string{"number": number}))
+
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, v.expCode, res.StatusCode)
+		})
+	}
+}
+
+func TestWithdrawalApprovals(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	h := &handlers{
+		ctx:    context.Background(),
+		seckey: seckey,
+		db:     db,
+		retry:  retry.NewRetry(),
+		admins: []string{adminLogin},
+	}
+
+	db.EXPECT().GetUserLogin(gomock.Any(), userID).Return(adminLogin, nil).Times(2)
+	var approvalsPtr *[]models.WithdrawalApproval
+	db.EXPECT().ListWithdrawalApprovals(gomock.Any(), models.WithdrawalPendingApproval, gomock.AssignableToTypeOf(approvalsPtr)).DoAndReturn(
+		func(ctx context.Context, status string, approvals *[]models.WithdrawalApproval) error {
+			*approvals = append(*approvals, models.WithdrawalApproval{
+				Order:       "12345678903",
+				Login:       login,
+				Sum:         50000,
+				Status:      status,
+				RequestedAt: "2024-07-13T10:00:00Z",
+			})
+			return nil
+		})
+
+	w := httptest.NewRecorder()
+	h.withdrawalApprovals()(w, withUserToken(t, httptest.NewRequest(http.MethodGet, "/", nil), userID))
+	res := w.Result()
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.JSONEq(t, `[{"order": "12345678903", "login": "test", "sum": 50000, "status": "PENDING_APPROVAL",
+		"requested_at": "2024-07-13T10:00:00Z"}]`, string(b))
+
+	w = httptest.NewRecorder()
+	h.withdrawalApprovals()(w, withUserToken(t, httptest.NewRequest(http.MethodGet, "/?status=DONE", nil), userID))
+	res = w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
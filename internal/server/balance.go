@@ -0,0 +1,215 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sourcecd/gofermart/internal/auth"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/sourcecd/gofermart/internal/storage"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+func pageParams(r *http.Request) (models.Page, error) {
+	page := models.Page{Limit: defaultPageLimit}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxPageLimit {
+			return page, errors.New("wrong limit")
+		}
+		page.Limit = limit
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return page, errors.New("wrong offset")
+		}
+		page.Offset = offset
+	}
+	return page, nil
+}
+
+// dateParam parses RFC3339 date query param, now when absent.
+func dateParam(r *http.Request) (time.Time, error) {
+	v := r.URL.Query().Get("date")
+	if v == "" {
+		return time.Now(), nil
+	}
+	date, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, errors.New("wrong date, RFC3339 expected")
+	}
+	return date, nil
+}
+
+func (h *handlers) balanceHistory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		gettoken, err := checkRequestCreds(r)
+		if err != nil {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		userid, err := auth.ParseJWT(gettoken, h.seckey)
+		if err != nil {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		page, err := pageParams(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var entries []models.BalanceEntry
+		if err := h.retry.LedgerFuncRetry(h.db.BalanceHistory)(h.ctx, userid, page, &entries); err != nil {
+			if errors.Is(err, prjerrors.ErrEmptyData) {
+				http.Error(w, err.Error(), http.StatusNoContent)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, entries)
+	}
+}
+
+func (h *handlers) balanceAsOf() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		gettoken, err := checkRequestCreds(r)
+		if err != nil {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		userid, err := auth.ParseJWT(gettoken, h.seckey)
+		if err != nil {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		date, err := dateParam(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var balance models.BalanceAsOf
+		if err := h.retry.AsOfFuncRetry(h.db.BalanceAsOf)(h.ctx, userid, date, &balance); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, balance)
+	}
+}
+
+// userBalanceAsOf is balance as of date for any user by login, for support.
+func (h *handlers) userBalanceAsOf() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, err := h.checkAdmin(h.ctx, r); err != nil {
+			adminError(w, err)
+			return
+		}
+		date, err := dateParam(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var userid int64
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) (err error) {
+			userid, err = h.db.GetUserID(ctx, chi.URLParam(r, "login"))
+			return err
+		}); err != nil {
+			if errors.Is(err, prjerrors.ErrNotExists) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var balance models.BalanceAsOf
+		if err := h.retry.AsOfFuncRetry(h.db.BalanceAsOf)(h.ctx, userid, date, &balance); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, balance)
+	}
+}
+
+func (h *handlers) transfer() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := checkContentType(r, "application/json"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		gettoken, err := checkRequestCreds(r)
+		if err != nil {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		userid, err := auth.ParseJWT(gettoken, h.seckey)
+		if err != nil {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var transfer models.Transfer
+		dec := json.NewDecoder(r.Body)
+		if err := dec.Decode(&transfer); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if transfer.To == "" {
+			http.Error(w, "empty recipient", http.StatusUnprocessableEntity)
+			return
+		}
+		if transfer.Sum <= 0 {
+			http.Error(w, "wrong transfer sum", http.StatusUnprocessableEntity)
+			return
+		}
+
+		if err := h.retry.TransferFuncRetry(h.db.Transfer)(h.ctx, userid, &transfer, h.transferLimits); err != nil {
+			switch {
+			case errors.Is(err, prjerrors.ErrNotEnough):
+				http.Error(w, err.Error(), http.StatusPaymentRequired)
+			case errors.Is(err, prjerrors.ErrNotExists):
+				http.Error(w, "recipient not found", http.StatusNotFound)
+			case errors.Is(err, prjerrors.ErrSelfTransfer):
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			case errors.Is(err, prjerrors.ErrTransferLimit):
+				http.Error(w, err.Error(), http.StatusForbidden)
+			default:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("\n"))
+	}
+}
+
+// pointsExpiry writes off expired accrual lots.
+func pointsExpiry(ctx context.Context, db storage.Store) error {
+	count, err := db.ExpirePoints(ctx)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		slog.Info("points expired", slog.Int64("users", count))
+	}
+	return nil
+}
@@ -0,0 +1,357 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/sourcecd/gofermart/internal/retry"
+	"github.com/sourcecd/gofermart/internal/storage/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBalanceHistory(t *testing.T) {
+	testCases := []struct {
+		name    string
+		query   string
+		page    models.Page
+		dbErr   error
+		expCode int
+	}{
+		{name: "default", query: "", page: models.Page{Limit: defaultPageLimit}, expCode: http.StatusOK},
+		{name: "page", query: "?limit=2&offset=4", page: models.Page{Limit: 2, Offset: 4}, expCode: http.StatusOK},
+		{name: "empty", query: "?offset=10", page: models.Page{Limit: defaultPageLimit, Offset: 10}, dbErr: prjerrors.ErrEmptyData, expCode: http.StatusNoContent},
+		{name: "wrongLimit", query: "?limit=5000", expCode: http.StatusBadRequest},
+		{name: "wrongOffset", query: "?offset=-1", expCode: http.StatusBadRequest},
+	}
+
+	testTime := time.Now().Format(time.RFC3339)
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db := mock.NewMockStore(ctrl)
+
+			h := &handlers{
+				ctx:    context.Background(),
+				seckey: seckey,
+				db:     db,
+				retry:  retry.NewRetry(),
+			}
+
+			var entriesPtr *[]models.BalanceEntry
+			if v.expCode != http.StatusBadRequest {
+				db.EXPECT().BalanceHistory(gomock.Any(), userID, v.page, gomock.AssignableToTypeOf(entriesPtr)).DoAndReturn(
+					func(ctx context.Context, userid int64, page models.Page, entries *[]models.BalanceEntry) error {
+						if v.dbErr != nil {
+							return v.dbErr
+						}
+						*entries = append(*entries,
+							models.BalanceEntry{Kind: models.LedgerAccrual, Order: "12345678903", Amount: 500, Balance: 500, CreatedAt: testTime},
+							models.BalanceEntry{Kind: models.LedgerWithdrawal, Order: "2377225624", Amount: -200, Balance: 300, CreatedAt: testTime},
+						)
+						return nil
+					})
+			}
+
+			w := httptest.NewRecorder()
+			h.balanceHistory()(w, withUserToken(t, httptest.NewRequest(http.MethodGet, "/"+v.query, nil), userID))
+
+			res := w.Result()
+			b, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			assert.Equal(t, v.expCode, res.StatusCode)
+			if v.expCode == http.StatusOK {
+				assert.JSONEq(t, `[
+					{"kind": "accrual", "order": "12345678903", "amount": 500, "balance": 500, "created_at": "`+testTime+`"},
+					{"kind": "withdrawal", "order": "2377225624", "amount": -200, "balance": 300, "created_at": "`+testTime+`"}
+				]`, string(b))
+			}
+		})
+	}
+}
+
+func TestBalanceAsOf(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	h := &handlers{
+		ctx:    context.Background(),
+		seckey: seckey,
+		db:     db,
+		retry:  retry.NewRetry(),
+		admins: []string{adminLogin},
+	}
+
+	const date = "2024-07-01T12:00:00Z"
+	parsed, err := time.Parse(time.RFC3339, date)
+	require.NoError(t, err)
+
+	var balancePtr *models.BalanceAsOf
+	db.EXPECT().BalanceAsOf(gomock.Any(), userID, parsed, gomock.AssignableToTypeOf(balancePtr)).DoAndReturn(
+		func(ctx context.Context, userid int64, date time.Time, balance *models.BalanceAsOf) error {
+			balance.Date = date.Format(time.RFC3339)
+			balance.Current = 300
+			return nil
+		}).Times(2)
+
+	w := httptest.NewRecorder()
+	h.balanceAsOf()(w, withUserToken(t, httptest.NewRequest(http.MethodGet, "/?date="+date, nil), userID))
+	res := w.Result()
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.JSONEq(t, `{"date": "`+date+`", "current": 300}`, string(b))
+
+	w = httptest.NewRecorder()
+	h.balanceAsOf()(w, withUserToken(t, httptest.NewRequest(http.MethodGet, "/?date=yesterday", nil), userID))
+	res = w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	// support asks for the same user by login
+	db.EXPECT().GetUserLogin(gomock.Any(), userID).Return(adminLogin, nil).Times(2)
+	db.EXPECT().GetUserID(gomock.Any(), login).Return(userID, nil)
+	db.EXPECT().GetUserID(gomock.Any(), "nobody").Return(int64(-1), prjerrors.ErrNotExists)
+
+	r := httptest.NewRequest(http.MethodGet, "/?date="+date, nil)
+	w = httptest.NewRecorder()
+	h.userBalanceAsOf()(w, withURLParams(withUserToken(t, r, userID), map[string]string{"login": login}))
+	res = w.Result()
+	b, err = io.ReadAll(res.Body)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.JSONEq(t, `{"date": "`+date+`", "current": 300}`, string(b))
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	w = httptest.NewRecorder()
+	h.userBalanceAsOf()(w, withURLParams(withUserToken(t, r, userID), map[string]string{"login": "nobody"}))
+	res = w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestStatement(t *testing.T) {
+	testCases := []struct {
+		name    string
+		query   string
+		expCode int
+		expType string
+		expBody string
+	}{
+		{
+			name:    "json",
+			query:   "?from=2024-06-01&to=2024-06-30",
+			expCode: http.StatusOK,
+			expType: "application/json",
+			expBody: `{
+				"opening": {"kind": "opening", "amount": 0, "balance": 100, "created_at": "2024-06-01T00:00:00Z"},
+				"entries": [
+					{"kind": "accrual", "order": "12345678903", "amount": 500, "balance": 600, "created_at": "2024-06-10T00:00:00Z"},
+					{"kind": "withdrawal", "order": "2377225624", "amount": -200, "balance": 400, "created_at": "2024-06-20T00:00:00Z"}
+				],
+				"closing": {"kind": "closing", "amount": 0, "balance": 400, "created_at": "2024-07-01T00:00:00Z"}
+			}`,
+		},
+		{
+			name:    "csv",
+			query:   "?from=2024-06-01&to=2024-06-30&format=csv",
+			expCode: http.StatusOK,
+			expType: "text/csv",
+			expBody: "date,kind,order,counterparty,amount,balance\n" +
+				"2024-06-01T00:00:00Z,opening,,,0,100\n" +
+				"2024-06-10T00:00:00Z,accrual,12345678903,,500,600\n" +
+				"2024-06-20T00:00:00Z,withdrawal,2377225624,,-200,400\n" +
+				"2024-07-01T00:00:00Z,closing,,,0,400\n",
+		},
+		{name: "wrongFormat", query: "?from=2024-06-01&to=2024-07-01&format=xml", expCode: http.StatusBadRequest},
+		{name: "wrongPeriod", query: "?from=2024-07-01&to=2024-06-01", expCode: http.StatusBadRequest},
+		{name: "noPeriod", query: "", expCode: http.StatusBadRequest},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db := mock.NewMockStore(ctrl)
+
+			h := &handlers{
+				ctx:    context.Background(),
+				seckey: seckey,
+				db:     db,
+				retry:  retry.NewRetry(),
+			}
+
+			if v.expCode == http.StatusOK {
+				period := models.Period{
+					From: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+					To:   time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+				}
+				db.EXPECT().Statement(gomock.Any(), userID, period, gomock.Any()).DoAndReturn(
+					func(ctx context.Context, userid int64, period models.Period, line func(line *models.BalanceEntry) error) error {
+						for _, l := range []models.BalanceEntry{
+							{Kind: models.LedgerOpening, Balance: 100, CreatedAt: "2024-06-01T00:00:00Z"},
+							{Kind: models.LedgerAccrual, Order: "12345678903", Amount: 500, Balance: 600, CreatedAt: "2024-06-10T00:00:00Z"},
+							{Kind: models.LedgerWithdrawal, Order: "2377225624", Amount: -200, Balance: 400, CreatedAt: "2024-06-20T00:00:00Z"},
+							{Kind: models.LedgerClosing, Balance: 400, CreatedAt: "2024-07-01T00:00:00Z"},
+						} {
+							if err := line(&l); err != nil {
+								return err
+							}
+						}
+						return nil
+					})
+			}
+
+			w := httptest.NewRecorder()
+			h.statement()(w, withUserToken(t, httptest.NewRequest(http.MethodGet, "/"+v.query, nil), userID))
+
+			res := w.Result()
+			b, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			assert.Equal(t, v.expCode, res.StatusCode)
+			if v.expCode != http.StatusOK {
+				return
+			}
+			assert.Equal(t, v.expType, res.Header.Get("Content-Type"))
+			if v.expType == "text/csv" {
+				assert.Equal(t, v.expBody, string(b))
+				return
+			}
+			assert.JSONEq(t, v.expBody, string(b))
+		})
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	testCases := []struct {
+		name    string
+		body    string
+		dbErr   error
+		expCode int
+	}{
+		{name: "ok", body: `{"to": "family", "sum": 100}`, expCode: http.StatusOK},
+		{name: "notEnough", body: `{"to": "family", "sum": 100}`, dbErr: prjerrors.ErrNotEnough, expCode: http.StatusPaymentRequired},
+		{name: "noRecipient", body: `{"to": "nobody", "sum": 100}`, dbErr: prjerrors.ErrNotExists, expCode: http.StatusNotFound},
+		{name: "self", body: `{"to": "test", "sum": 100}`, dbErr: prjerrors.ErrSelfTransfer, expCode: http.StatusUnprocessableEntity},
+		{name: "limit", body: `{"to": "family", "sum": 100}`, dbErr: prjerrors.ErrTransferLimit, expCode: http.StatusForbidden},
+		{name: "wrongSum", body: `{"to": "family", "sum": -1}`, expCode: http.StatusUnprocessableEntity},
+		{name: "emptyRecipient", body: `{"sum": 100}`, expCode: http.StatusUnprocessableEntity},
+	}
+
+	limits := models.TransferLimits{Max: 1000, DailyMax: 5000}
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db := mock.NewMockStore(ctrl)
+
+			h := &handlers{
+				ctx:    context.Background(),
+				seckey: seckey,
+				db:     db,
+				retry:  retry.NewRetry(),
+
+				transferLimits: limits,
+			}
+
+			if v.expCode != http.StatusUnprocessableEntity || v.dbErr != nil {
+				var transferPtr *models.Transfer
+				db.EXPECT().Transfer(gomock.Any(), userID, gomock.AssignableToTypeOf(transferPtr), limits).Return(v.dbErr)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(v.body))
+			r.Header.Add("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			h.transfer()(w, withUserToken(t, r, userID))
+
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, v.expCode, res.StatusCode)
+		})
+	}
+}
+
+func TestGetBalanceExpiringSoon(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	h := &handlers{
+		ctx:    context.Background(),
+		seckey: seckey,
+		db:     db,
+		retry:  retry.NewRetry(),
+	}
+
+	var balancePtr *models.Balance
+	db.EXPECT().GetBalance(gomock.Any(), userID, gomock.AssignableToTypeOf(balancePtr)).DoAndReturn(
+		func(ctx context.Context, userid int64, balance *models.Balance) error {
+			balance.Current = 500
+			balance.ExpiringSoon = &models.ExpiringPoints{Amount: 200, ExpiresAt: "2024-08-01T00:00:00Z"}
+			return nil
+		})
+
+	w := httptest.NewRecorder()
+	h.getBalance()(w, withUserToken(t, httptest.NewRequest(http.MethodGet, "/", nil), userID))
+
+	res := w.Result()
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.JSONEq(t, `{"current": 500, "withdrawn": 0, "expiring_soon": {"amount": 200, "expires_at": "2024-08-01T00:00:00Z"}}`, string(b))
+}
+
+func TestPointsExpiry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	db.EXPECT().ExpirePoints(gomock.Any()).Return(int64(2), nil)
+	require.NoError(t, pointsExpiry(context.Background(), db))
+
+	db.EXPECT().ExpirePoints(gomock.Any()).Return(int64(0), errors.New("db down"))
+	require.Error(t, pointsExpiry(context.Background(), db))
+}
+
+func TestPeriodParams(t *testing.T) {
+	testCases := []struct {
+		name  string
+		query string
+		exp   models.Period
+	}{
+		{
+			name:  "dateOnlyTo",
+			query: "?from=2024-06-01&to=2024-06-01",
+			exp:   models.Period{From: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:  "timeTo",
+			query: "?from=2024-06-01&to=2024-06-01T12:00:00Z",
+			exp:   models.Period{From: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)},
+		},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			period, err := periodParams(httptest.NewRequest(http.MethodGet, "/"+v.query, nil))
+			require.NoError(t, err)
+			assert.True(t, v.exp.From.Equal(period.From))
+			assert.True(t, v.exp.To.Equal(period.To))
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+func campaignIDParam(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errors.New("wrong campaign id")
+	}
+	return id, nil
+}
+
+func campaignParse(r *http.Request) (*models.Campaign, error) {
+	var campaign models.Campaign
+	if err := json.NewDecoder(r.Body).Decode(&campaign); err != nil {
+		return nil, prjerrors.ErrReqJSONParse
+	}
+	if err := campaign.Validate(); err != nil {
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+func campaignError(w http.ResponseWriter, err error) {
+	if errors.Is(err, prjerrors.ErrCampaignNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func (h *handlers) createCampaign() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := checkContentType(r, "application/json"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		author, err := h.checkAdmin(h.ctx, r)
+		if err != nil {
+			adminError(w, err)
+			return
+		}
+
+		campaign, err := campaignParse(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		campaign.Author = author
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.CreateCampaign(ctx, campaign) }); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusCreated, campaign)
+	}
+}
+
+func (h *handlers) campaigns() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, err := h.checkAdmin(h.ctx, r); err != nil {
+			adminError(w, err)
+			return
+		}
+
+		var campaigns []models.Campaign
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.ListCampaigns(ctx, &campaigns) }); err != nil {
+			if errors.Is(err, prjerrors.ErrEmptyData) {
+				http.Error(w, err.Error(), http.StatusNoContent)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, campaigns)
+	}
+}
+
+func (h *handlers) campaign() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, err := h.checkAdmin(h.ctx, r); err != nil {
+			adminError(w, err)
+			return
+		}
+		id, err := campaignIDParam(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var campaign models.Campaign
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.GetCampaign(ctx, id, &campaign) }); err != nil {
+			campaignError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, campaign)
+	}
+}
+
+// updateCampaign replaces campaign rules, author and creation time stay.
+func (h *handlers) updateCampaign() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := checkContentType(r, "application/json"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if _, err := h.checkAdmin(h.ctx, r); err != nil {
+			adminError(w, err)
+			return
+		}
+		id, err := campaignIDParam(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		campaign, err := campaignParse(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		campaign.ID = id
+
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.UpdateCampaign(ctx, campaign) }); err != nil {
+			campaignError(w, err)
+			return
+		}
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.GetCampaign(ctx, id, campaign) }); err != nil {
+			campaignError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, campaign)
+	}
+}
+
+func (h *handlers) deleteCampaign() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, err := h.checkAdmin(h.ctx, r); err != nil {
+			adminError(w, err)
+			return
+		}
+		id, err := campaignIDParam(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.DeleteCampaign(ctx, id) }); err != nil {
+			campaignError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("\n"))
+	}
+}
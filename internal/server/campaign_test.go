@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/sourcecd/gofermart/internal/retry"
+	"github.com/sourcecd/gofermart/internal/storage/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateCampaign(t *testing.T) {
+	testCases := []struct {
+		name      string
+		body      string
+		expCode   int
+		expRecord bool
+	}{
+		{
+			name:      "double_points",
+			body:      `{"name": "weekend", "starts_at": "2024-07-13T00:00:00Z", "ends_at": "2024-07-15T00:00:00Z", "bonus_type": "multiplier", "bonus": 2}`,
+			expCode:   http.StatusCreated,
+			expRecord: true,
+		},
+		{
+			name:    "wrong_window",
+			body:    `{"name": "weekend", "starts_at": "2024-07-15T00:00:00Z", "ends_at": "2024-07-13T00:00:00Z", "bonus_type": "multiplier", "bonus": 2}`,
+			expCode: http.StatusBadRequest,
+		},
+		{
+			name:    "wrong_bonus_type",
+			body:    `{"name": "first", "starts_at": "2024-07-13T00:00:00Z", "ends_at": "2024-07-15T00:00:00Z", "bonus_type": "gift", "bonus": 500}`,
+			expCode: http.StatusBadRequest,
+		},
+		{
+			name:    "wrong_json",
+			body:    `{"name": `,
+			expCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db := mock.NewMockStore(ctrl)
+
+			h := &handlers{
+				ctx:    context.Background(),
+				seckey: seckey,
+				db:     db,
+				retry:  retry.NewRetry(),
+				admins: []string{adminLogin},
+			}
+
+			db.EXPECT().GetUserLogin(gomock.Any(), userID).Return(adminLogin, nil)
+			if v.expRecord {
+				var campaignPtr *models.Campaign
+				db.EXPECT().CreateCampaign(gomock.Any(), gomock.AssignableToTypeOf(campaignPtr)).DoAndReturn(
+					func(ctx context.Context, campaign *models.Campaign) error {
+						assert.Equal(t, adminLogin, campaign.Author)
+						assert.Equal(t, time.Date(2024, 7, 13, 0, 0, 0, 0, time.UTC), campaign.StartsAt)
+						campaign.ID = 1
+						return nil
+					})
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(v.body))
+			r.Header.Add("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			h.createCampaign()(w, withUserToken(t, r, userID))
+
+			res := w.Result()
+			b, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			assert.Equal(t, v.expCode, res.StatusCode)
+			if v.expRecord {
+				assert.Contains(t, string(b), `"id": 1`)
+			}
+		})
+	}
+}
+
+func TestCampaignNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	h := &handlers{
+		ctx:    context.Background(),
+		seckey: seckey,
+		db:     db,
+		retry:  retry.NewRetry(),
+		admins: []string{adminLogin},
+	}
+
+	db.EXPECT().GetUserLogin(gomock.Any(), userID).Return(adminLogin, nil).Times(4)
+	db.EXPECT().GetCampaign(gomock.Any(), int64(7), gomock.Any()).Return(prjerrors.ErrCampaignNotFound)
+	db.EXPECT().UpdateCampaign(gomock.Any(), gomock.Any()).Return(prjerrors.ErrCampaignNotFound)
+	db.EXPECT().DeleteCampaign(gomock.Any(), int64(7)).Return(prjerrors.ErrCampaignNotFound)
+
+	w := httptest.NewRecorder()
+	h.campaign()(w, withURLParams(withUserToken(t, httptest.NewRequest(http.MethodGet, "/", nil), userID), map[string]string{"id": "7"}))
+	res := w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	body := `{"name": "first", "starts_at": "2024-07-13T00:00:00Z", "ends_at": "2024-08-13T00:00:00Z", "first_order": true, "bonus_type": "fixed", "bonus": 500}`
+	r := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body))
+	r.Header.Add("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	h.updateCampaign()(w, withURLParams(withUserToken(t, r, userID), map[string]string{"id": "7"}))
+	res = w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	w = httptest.NewRecorder()
+	h.deleteCampaign()(w, withURLParams(withUserToken(t, httptest.NewRequest(http.MethodDelete, "/", nil), userID), map[string]string{"id": "7"}))
+	res = w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	w = httptest.NewRecorder()
+	h.deleteCampaign()(w, withURLParams(withUserToken(t, httptest.NewRequest(http.MethodDelete, "/", nil), userID), map[string]string{"id": "x"}))
+	res = w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,8 +12,12 @@ import (
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/sourcecd/gofermart/internal/accrual"
+	"github.com/sourcecd/gofermart/internal/accrual/accrualtest"
 	"github.com/sourcecd/gofermart/internal/auth"
+	"github.com/sourcecd/gofermart/internal/config"
 	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
 	"github.com/sourcecd/gofermart/internal/retry"
 	"github.com/sourcecd/gofermart/internal/storage/mock"
 	"github.com/stretchr/testify/assert"
@@ -232,6 +237,27 @@ func TestGetBalance(t *testing.T) {
 	assert.JSONEq(t, jsonExpRes, string(b))
 }
 
+func TestGetBalanceError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	rt := retry.NewRetry()
+	rt.SetParams(time.Millisecond, time.Second, 0)
+	h := &handlers{
+		ctx:    context.Background(),
+		seckey: seckey,
+		db:     db,
+		retry:  rt,
+	}
+
+	db.EXPECT().GetBalance(gomock.Any(), userID, gomock.Any()).Return(errors.New("conn refused"))
+
+	w := httptest.NewRecorder()
+	h.getBalance()(w, withUserToken(t, httptest.NewRequest(http.MethodGet, "/", nil), userID))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
 func TestWithdraw(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -309,3 +335,321 @@ func TestWithdrawals(t *testing.T) {
 	defer res.Body.Close()
 	assert.JSONEq(t, jsonAndRes, string(b))
 }
+
+func TestGetBalancePending(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	var (
+		balancePtr *models.Balance
+		ordersPtr  *[]models.AccrualOrder
+	)
+
+	rules := accrual.NewRules()
+	require.NoError(t, rules.Add(models.AccrualGoods{Match: "Bork", Reward: 10, RewardType: accrual.RewardPercent}))
+	h := &handlers{
+		ctx:    context.Background(),
+		seckey: seckey,
+		db:     db,
+		retry:  retry.NewRetry(),
+	}
+
+	db.EXPECT().GetBalance(gomock.Any(), userID, gomock.AssignableToTypeOf(balancePtr)).DoAndReturn(
+		func(ctx context.Context, userid int64, balance *models.Balance) error {
+			balance.Current = 10.5
+			balance.Withdrawn = 42.5
+			balance.Pending = &models.PendingAccrual{Count: 2}
+			return nil
+		}).Times(3)
+
+	testCases := []struct {
+		name   string
+		rules  *accrual.Rules
+		orders []models.AccrualOrder
+		expRes string
+	}{
+		{
+			name:   "remote",
+			expRes: `{"current": 10.5, "withdrawn": 42.5, "pending": {"count": 2}}`,
+		},
+		{
+			name:  "local",
+			rules: rules,
+			orders: []models.AccrualOrder{
+				{Order: "12345678903", Goods: []models.Good{{Description: "Bork kettle", Price: 3000}}},
+				{Order: "79927398713", Goods: []models.Good{{Description: "LG TV", Price: 50000}}},
+			},
+			expRes: `{"current": 10.5, "withdrawn": 42.5, "pending": {"count": 2, "amount": 300}}`,
+		},
+		{
+			name:  "no receipt",
+			rules: rules,
+			orders: []models.AccrualOrder{
+				{Order: "12345678903", Goods: []models.Good{{Description: "Bork kettle", Price: 3000}}},
+				{Order: "79927398713"},
+			},
+			expRes: `{"current": 10.5, "withdrawn": 42.5, "pending": {"count": 2}}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h.rules = tc.rules
+			if tc.rules != nil {
+				db.EXPECT().PendingOrders(gomock.Any(), userID, gomock.AssignableToTypeOf(ordersPtr)).DoAndReturn(
+					func(ctx context.Context, userid int64, orders *[]models.AccrualOrder) error {
+						*orders = tc.orders
+						return nil
+					})
+			}
+
+			w := httptest.NewRecorder()
+			h.getBalance()(w, withUserToken(t, httptest.NewRequest(http.MethodGet, "/", nil), userID))
+
+			res := w.Result()
+			b, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			assert.JSONEq(t, tc.expRes, string(b))
+		})
+	}
+}
+
+func TestHealth(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	primary := accrual.NewBreaker(1, time.Minute, 1)
+	standby := accrual.NewBreaker(1, time.Minute, 1)
+	h := &handlers{
+		ctx:    context.Background(),
+		seckey: seckey,
+		db:     db,
+		retry:  retry.NewRetry(),
+		endpoints: []*accrual.Endpoint{
+			accrual.NewEndpoint("http://primary", nil, primary),
+			accrual.NewEndpoint("http://standby", nil, standby),
+		},
+
+		accrualMode: "remote",
+	}
+
+	db.EXPECT().Ping(gomock.Any()).Return(nil).Times(3)
+
+	testCases := []struct {
+		name     string
+		fail     *accrual.Breaker
+		expected string
+	}{
+		{
+			name: "ok",
+			expected: `{"status": "ok", "database": "ok", "accrual": {"mode": "remote", "breaker": "closed", "endpoints": [
+				{"url": "http://primary", "breaker": "closed"}, {"url": "http://standby", "breaker": "closed"}]}}`,
+		},
+		{
+			name: "primaryOpen",
+			fail: primary,
+			expected: `{"status": "degraded", "database": "ok", "accrual": {"mode": "remote", "breaker": "closed", "endpoints": [
+				{"url": "http://primary", "breaker": "open"}, {"url": "http://standby", "breaker": "closed"}]}}`,
+		},
+		{
+			name: "allOpen",
+			fail: standby,
+			expected: `{"status": "degraded", "database": "ok", "accrual": {"mode": "remote", "breaker": "open", "endpoints": [
+				{"url": "http://primary", "breaker": "open"}, {"url": "http://standby", "breaker": "open"}]}}`,
+		},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			if v.fail != nil {
+				v.fail.Failure()
+			}
+			w := httptest.NewRecorder()
+			h.health()(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			res := w.Result()
+			b, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			assert.JSONEq(t, v.expected, string(b))
+		})
+	}
+}
+
+func TestHealthWiring(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	primary := accrualtest.NewServer()
+	defer primary.Close()
+	primary.SetOrderResponses("12345678903", accrualtest.Response{StatusCode: http.StatusInternalServerError})
+	standby := accrualtest.NewServer()
+	defer standby.Close()
+	standby.SetOrderResponses("12345678903", accrualtest.Processed("12345678903", 500))
+
+	cfg := config.Config{
+		AccrualMode:             config.AccrualModeRemote,
+		AccrualSystemAddresses:  []string{primary.URL, standby.URL},
+		AccrualTimeout:          time.Second,
+		AccrualBreakerFailures:  1,
+		AccrualBreakerTimeout:   time.Minute,
+		AccrualBreakerSuccesses: 1,
+	}
+	h, failover, err := newHandlers(context.Background(), cfg, db, seckey, retry.NewRetry())
+	require.NoError(t, err)
+	require.NotNil(t, failover)
+
+	db.EXPECT().Ping(gomock.Any()).Return(nil).Times(2)
+
+	w := httptest.NewRecorder()
+	h.health()(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	res := w.Result()
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.JSONEq(t, fmt.Sprintf(`{"status": "ok", "database": "ok", "accrual": {"mode": "remote", "breaker": "closed", "endpoints": [
+		{"url": %q, "breaker": "closed"}, {"url": %q, "breaker": "closed"}]}}`, primary.URL, standby.URL), string(b))
+
+	// primary failure opens its breaker and the call goes to standby
+	order, err := h.accrual.GetOrder(context.Background(), "12345678903")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusProcessed, order.Status)
+
+	w = httptest.NewRecorder()
+	h.health()(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	res = w.Result()
+	b, err = io.ReadAll(res.Body)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.JSONEq(t, fmt.Sprintf(`{"status": "degraded", "database": "ok", "accrual": {"mode": "remote", "breaker": "closed", "endpoints": [
+		{"url": %q, "breaker": "open"}, {"url": %q, "breaker": "closed"}]}}`, primary.URL, standby.URL), string(b))
+}
+
+func TestOrderHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	var (
+		historyPtr *[]models.OrderStatusChange
+		testTime   = time.Now().Format(time.RFC3339)
+	)
+
+	h := &handlers{
+		ctx:    context.Background(),
+		seckey: seckey,
+		db:     db,
+		retry:  retry.NewRetry(),
+	}
+
+	db.EXPECT().OrderStatusHistory(gomock.Any(), userID, int64(12345678903), gomock.AssignableToTypeOf(historyPtr)).DoAndReturn(
+		func(ctx context.Context, userid, orderid int64, history *[]models.OrderStatusChange) error {
+			*history = append(*history,
+				models.OrderStatusChange{Status: models.StatusNew, ChangedAt: testTime},
+				models.OrderStatusChange{Status: models.StatusProcessed, ChangedAt: testTime},
+			)
+			return nil
+		})
+	db.EXPECT().OrderStatusHistory(gomock.Any(), userID, int64(9278923470), gomock.Any()).Return(prjerrors.ErrOrderNotFound)
+
+	r := withURLParams(withUserToken(t, httptest.NewRequest(http.MethodGet, "/", nil), userID), map[string]string{"number": "12345678903"})
+	w := httptest.NewRecorder()
+
+	//target check handler
+	h.orderHistory()(w, r)
+
+	res := w.Result()
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.JSONEq(t, fmt.Sprintf(`[
+		{"status": "NEW", "changed_at": "%s"},
+		{"status": "PROCESSED", "changed_at": "%s"}
+	]`, testTime, testTime), string(b))
+
+	r = withURLParams(withUserToken(t, httptest.NewRequest(http.MethodGet, "/", nil), userID), map[string]string{"number": "9278923470"})
+	w = httptest.NewRecorder()
+	h.orderHistory()(w, r)
+	res = w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestOrderRegisterReceipt(t *testing.T) {
+	testCases := []struct {
+		name        string
+		body        string
+		expCode     int
+		expRegister bool
+	}{
+		{
+			name:        "ok",
+			body:        `{"order": "12345678903", "goods": [{"description": "Чайник Bork", "price": 7000}]}`,
+			expCode:     http.StatusAccepted,
+			expRegister: true,
+		},
+		{
+			name:    "noGoods",
+			body:    `{"order": "12345678903", "goods": []}`,
+			expCode: http.StatusBadRequest,
+		},
+		{
+			name:    "wrongPrice",
+			body:    `{"order": "12345678903", "goods": [{"description": "Чайник Bork", "price": 0}]}`,
+			expCode: http.StatusBadRequest,
+		},
+		{
+			name:    "wrongLuhn",
+			body:    `{"order": "12345678904", "goods": [{"description": "Чайник Bork", "price": 7000}]}`,
+			expCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db := mock.NewMockStore(ctrl)
+
+			srv := accrualtest.NewServer()
+			defer srv.Close()
+
+			h := &handlers{
+				ctx:             context.Background(),
+				seckey:          seckey,
+				db:              db,
+				retry:           retry.NewRetry(),
+				accrual:         accrual.NewHTTPClient(srv.URL, time.Second),
+				accrualRegister: true,
+			}
+
+			receipt := &models.AccrualOrder{
+				Order: "12345678903",
+				Goods: []models.Good{{Description: "Чайник Bork", Price: 7000}},
+			}
+			if v.expRegister {
+				db.EXPECT().CreateOrderReceipt(gomock.Any(), userID, receipt).Return(nil)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(v.body))
+			r.Header.Add("Content-Type", "application/json")
+			r = withUserToken(t, r, userID)
+			w := httptest.NewRecorder()
+
+			//target test handler
+			h.orderRegister()(w, r)
+
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, v.expCode, res.StatusCode)
+			if v.expRegister {
+				assert.Equal(t, []models.AccrualOrder{*receipt}, srv.RegisteredOrders())
+			} else {
+				assert.Empty(t, srv.RegisteredOrders())
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+func withdrawalLimitsError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, prjerrors.ErrWithdrawalLimitsNotSet):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, prjerrors.ErrNotExists):
+		http.Error(w, "user not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *handlers) userWithdrawalLimits() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, err := h.checkAdmin(h.ctx, r); err != nil {
+			adminError(w, err)
+			return
+		}
+
+		var limits models.UserWithdrawalLimits
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error {
+			return h.db.GetUserWithdrawalLimits(ctx, chi.URLParam(r, "login"), &limits)
+		}); err != nil {
+			withdrawalLimitsError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, &limits)
+	}
+}
+
+// setUserWithdrawalLimits replaces tier and global limits of the user, body
+// is {"max": 1000, "daily_max": 3000, "monthly_max": 0}, zero disables a limit.
+func (h *handlers) setUserWithdrawalLimits() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := checkContentType(r, "application/json"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		author, err := h.checkAdmin(h.ctx, r)
+		if err != nil {
+			adminError(w, err)
+			return
+		}
+
+		var body models.WithdrawalLimits
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, prjerrors.ErrReqJSONParse.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := body.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		limits := &models.UserWithdrawalLimits{
+			Login:            chi.URLParam(r, "login"),
+			WithdrawalLimits: body,
+			Author:           author,
+		}
+
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.SetUserWithdrawalLimits(ctx, limits) }); err != nil {
+			withdrawalLimitsError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, limits)
+	}
+}
+
+func (h *handlers) deleteUserWithdrawalLimits() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, err := h.checkAdmin(h.ctx, r); err != nil {
+			adminError(w, err)
+			return
+		}
+
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.DeleteUserWithdrawalLimits(ctx, chi.URLParam(r, "login")) }); err != nil {
+			withdrawalLimitsError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("\n"))
+	}
+}
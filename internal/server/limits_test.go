@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/sourcecd/gofermart/internal/retry"
+	"github.com/sourcecd/gofermart/internal/storage/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithdrawLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	h := &handlers{
+		ctx:    context.Background(),
+		seckey: seckey,
+		db:     db,
+		retry:  retry.NewRetry(),
+	}
+
+	// limit error is not retried
+	db.EXPECT().Withdraw(gomock.Any(), userID, gomock.Any()).Return(prjerrors.ErrWithdrawalLimit).Times(1)
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"order": "12345678903", "sum": 5000}`))
+	r.Header.Add("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.withdraw()(w, withUserToken(t, r, userID))
+
+	res := w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+}
+
+func TestSetUserWithdrawalLimits(t *testing.T) {
+	testCases := []struct {
+		name    string
+		body    string
+		dbErr   error
+		callDB  bool
+		expCode int
+	}{
+		{name: "ok", body: `{"max": 1000, "daily_max": 3000}`, callDB: true, expCode: http.StatusOK},
+		{name: "negative", body: `{"max": -1}`, expCode: http.StatusBadRequest},
+		{name: "bad_json", body: `{"max": `, expCode: http.StatusBadRequest},
+		{name: "no_user", body: `{"max": 1000}`, callDB: true, dbErr: prjerrors.ErrNotExists, expCode: http.StatusNotFound},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db := mock.NewMockStore(ctrl)
+
+			h := &handlers{
+				ctx:    context.Background(),
+				seckey: seckey,
+				db:     db,
+				retry:  retry.NewRetry(),
+				admins: []string{adminLogin},
+			}
+
+			db.EXPECT().GetUserLogin(gomock.Any(), userID).Return(adminLogin, nil)
+			if v.callDB {
+				var limitsPtr *models.UserWithdrawalLimits
+				db.EXPECT().SetUserWithdrawalLimits(gomock.Any(), gomock.AssignableToTypeOf(limitsPtr)).DoAndReturn(
+					func(ctx context.Context, limits *models.UserWithdrawalLimits) error {
+						assert.Equal(t, "user", limits.Login)
+						assert.Equal(t, adminLogin, limits.Author)
+						limits.UpdatedAt = "2024-07-16T10:00:00Z"
+						return v.dbErr
+					})
+			}
+
+			r := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(v.body))
+			r.Header.Add("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			h.setUserWithdrawalLimits()(w, withURLParams(withUserToken(t, r, userID), map[string]string{"login": "user"}))
+
+			res := w.Result()
+			b, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			assert.Equal(t, v.expCode, res.StatusCode)
+			if v.expCode == http.StatusOK {
+				assert.JSONEq(t, `{"login": "user", "max": 1000, "daily_max": 3000, "monthly_max": 0,
+					"author": "admin", "updated_at": "2024-07-16T10:00:00Z"}`, string(b))
+			}
+		})
+	}
+}
+
+func TestUserWithdrawalLimits(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	h := &handlers{
+		ctx:    context.Background(),
+		seckey: seckey,
+		db:     db,
+		retry:  retry.NewRetry(),
+		admins: []string{adminLogin},
+	}
+
+	db.EXPECT().GetUserLogin(gomock.Any(), userID).Return(adminLogin, nil).Times(3)
+	db.EXPECT().GetUserWithdrawalLimits(gomock.Any(), "user", gomock.Any()).Return(prjerrors.ErrWithdrawalLimitsNotSet)
+	db.EXPECT().DeleteUserWithdrawalLimits(gomock.Any(), "user").Return(nil)
+	db.EXPECT().DeleteUserWithdrawalLimits(gomock.Any(), "user").Return(prjerrors.ErrWithdrawalLimitsNotSet)
+
+	w := httptest.NewRecorder()
+	h.userWithdrawalLimits()(w, withURLParams(withUserToken(t, httptest.NewRequest(http.MethodGet, "/", nil), userID),
+		map[string]string{"login": "user"}))
+	res := w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	for _, code := range []int{http.StatusOK, http.StatusNotFound} {
+		w := httptest.NewRecorder()
+		h.deleteUserWithdrawalLimits()(w, withURLParams(withUserToken(t, httptest.NewRequest(http.MethodDelete, "/", nil), userID),
+			map[string]string{"login": "user"}))
+		res := w.Result()
+		defer res.Body.Close()
+		assert.Equal(t, code, res.StatusCode)
+	}
+}
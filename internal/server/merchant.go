@@ -0,0 +1,264 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sourcecd/gofermart/internal/auth"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/sourcecd/gofermart/internal/storage"
+	"github.com/theplant/luhn"
+)
+
+// checkMerchant gives merchant name by X-Merchant-Key header.
+func (h *handlers) checkMerchant(r *http.Request) (string, error) {
+	key := r.Header.Get("X-Merchant-Key")
+	if key == "" {
+		return "", prjerrors.ErrAuthCredsNotFound
+	}
+	for k, name := range h.merchants {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			return name, nil
+		}
+	}
+	return "", prjerrors.ErrAuthCredsNotFound
+}
+
+func (h *handlers) knownMerchant(name string) bool {
+	for _, v := range h.merchants {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+func authorizationIDParam(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errors.New("wrong authorization id")
+	}
+	return id, nil
+}
+
+func authorizationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, prjerrors.ErrAuthorizationNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, prjerrors.ErrPaymentToken):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, prjerrors.ErrNotEnough):
+		http.Error(w, err.Error(), http.StatusPaymentRequired)
+	case errors.Is(err, prjerrors.ErrWithdrawalLimit):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, prjerrors.ErrAuthorizationState), errors.Is(err, prjerrors.ErrOrderAlreadyExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, prjerrors.ErrAuthorizationAmount):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// paymentToken issues user consent to one merchant authorization, body is
+// {"merchant": "...", "max_amount": 100}, both optional.
+func (h *handlers) paymentToken() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := checkContentType(r, "application/json"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		gettoken, err := checkRequestCreds(r)
+		if err != nil {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		userid, err := auth.ParseJWT(gettoken, h.seckey)
+		if err != nil {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var token models.PaymentToken
+		if err := json.NewDecoder(r.Body).Decode(&token); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if token.MaxAmount < 0 || token.Merchant != "" && !h.knownMerchant(token.Merchant) {
+			http.Error(w, "wrong merchant or max amount", http.StatusUnprocessableEntity)
+			return
+		}
+
+		if err := h.retry.PaymentTokenFuncRetry(h.db.CreatePaymentToken)(h.ctx, userid, &token); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusCreated, &token)
+	}
+}
+
+// authorize holds user points for merchant cart, body is
+// {"token": "...", "amount": 100} with payment token the user issued.
+func (h *handlers) authorize() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := checkContentType(r, "application/json"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		merchant, err := h.checkMerchant(r)
+		if err != nil {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var auth models.Authorization
+		if err := json.NewDecoder(r.Body).Decode(&auth); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if auth.Token == "" || auth.Amount <= 0 {
+			http.Error(w, "wrong token or amount", http.StatusUnprocessableEntity)
+			return
+		}
+		auth.Login = ""
+		auth.Merchant = merchant
+
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.Authorize(ctx, &auth) }); err != nil {
+			authorizationError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, &auth)
+	}
+}
+
+func (h *handlers) authorization() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		merchant, err := h.checkMerchant(r)
+		if err != nil {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		id, err := authorizationIDParam(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var auth models.Authorization
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.GetAuthorization(ctx, merchant, id, &auth) }); err != nil {
+			authorizationError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, &auth)
+	}
+}
+
+// authorizationOp parses merchant operation on authorization, body is
+// optional for void.
+func (h *handlers) authorizationOp(w http.ResponseWriter, r *http.Request, withBody bool) (*models.AuthorizationOp, bool) {
+	merchant, err := h.checkMerchant(r)
+	if err != nil {
+		http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	id, err := authorizationIDParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	op := &models.AuthorizationOp{}
+	if withBody {
+		if err := checkContentType(r, "application/json"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil, false
+		}
+		if err := json.NewDecoder(r.Body).Decode(op); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return nil, false
+		}
+		if op.Amount <= 0 {
+			http.Error(w, "wrong amount", http.StatusUnprocessableEntity)
+			return nil, false
+		}
+	}
+	op.Merchant = merchant
+	op.ID = id
+	return op, true
+}
+
+// captureAuthorization withdraws final amount, body is
+// {"amount": 80, "order": "..."}, the rest of the hold is released.
+func (h *handlers) captureAuthorization() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		op, ok := h.authorizationOp(w, r, true)
+		if !ok {
+			return
+		}
+		num, err := strconv.Atoi(op.Order)
+		if err != nil || !luhn.Valid(num) {
+			http.Error(w, "order number is not valid", http.StatusUnprocessableEntity)
+			return
+		}
+
+		var auth models.Authorization
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.CaptureAuthorization(ctx, op, &auth) }); err != nil {
+			authorizationError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, &auth)
+	}
+}
+
+func (h *handlers) voidAuthorization() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		op, ok := h.authorizationOp(w, r, false)
+		if !ok {
+			return
+		}
+
+		var auth models.Authorization
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.VoidAuthorization(ctx, op, &auth) }); err != nil {
+			authorizationError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, &auth)
+	}
+}
+
+// refundAuthorization returns part or all of captured amount, body is
+// {"amount": 20}.
+func (h *handlers) refundAuthorization() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		op, ok := h.authorizationOp(w, r, true)
+		if !ok {
+			return
+		}
+
+		var auth models.Authorization
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.RefundAuthorization(ctx, op, &auth) }); err != nil {
+			authorizationError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, &auth)
+	}
+}
+
+// authorizationsExpiry releases holds of expired authorizations.
+func authorizationsExpiry(ctx context.Context, db storage.Store) error {
+	count, err := db.ExpireAuthorizations(ctx)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		slog.Info("merchant authorizations expired", slog.Int64("authorizations", count))
+	}
+	return nil
+}
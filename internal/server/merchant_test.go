@@ -0,0 +1,248 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/sourcecd/gofermart/internal/retry"
+	"github.com/sourcecd/gofermart/internal/storage/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	merchantName = "shop"
+	merchantKey  = "shopKey"
+)
+
+func merchantRequest(method, body, key, id string) *http.Request {
+	r := httptest.NewRequest(method, "/", strings.NewReader(body))
+	r.Header.Add("Content-Type", "application/json")
+	if key != "" {
+		r.Header.Add("X-Merchant-Key", key)
+	}
+	if id != "" {
+		r = withURLParams(r, map[string]string{"id": id})
+	}
+	return r
+}
+
+func TestAuthorize(t *testing.T) {
+	testCases := []struct {
+		name    string
+		key     string
+		body    string
+		dbErr   error
+		callDB  bool
+		expCode int
+	}{
+		{name: "ok", key: merchantKey, body: `{"token": "tok", "amount": 100}`, callDB: true, expCode: http.StatusCreated},
+		{name: "wrong_key", key: "other", body: `{"token": "tok", "amount": 100}`, expCode: http.StatusUnauthorized},
+		{name: "no_key", body: `{"token": "tok", "amount": 100}`, expCode: http.StatusUnauthorized},
+		{name: "wrong_amount", key: merchantKey, body: `{"token": "tok", "amount": 0}`, expCode: http.StatusUnprocessableEntity},
+		{name: "login_only", key: merchantKey, body: `{"login": "test", "amount": 100}`, expCode: http.StatusUnprocessableEntity},
+		{name: "not_enough", key: merchantKey, body: `{"token": "tok", "amount": 100}`, callDB: true, dbErr: prjerrors.ErrNotEnough, expCode: http.StatusPaymentRequired},
+		{name: "bad_token", key: merchantKey, body: `{"token": "tok", "amount": 100}`, callDB: true, dbErr: prjerrors.ErrPaymentToken, expCode: http.StatusForbidden},
+		{name: "limit", key: merchantKey, body: `{"token": "tok", "amount": 100}`, callDB: true, dbErr: prjerrors.ErrWithdrawalLimit, expCode: http.StatusForbidden},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db := mock.NewMockStore(ctrl)
+
+			h := &handlers{
+				ctx:       context.Background(),
+				db:        db,
+				retry:     retry.NewRetry(),
+				merchants: map[string]string{merchantKey: merchantName},
+			}
+
+			if v.callDB {
+				var authPtr *models.Authorization
+				db.EXPECT().Authorize(gomock.Any(), gomock.AssignableToTypeOf(authPtr)).DoAndReturn(
+					func(ctx context.Context, auth *models.Authorization) error {
+						assert.Equal(t, merchantName, auth.Merchant)
+						assert.Equal(t, "tok", auth.Token)
+						auth.Token = ""
+						auth.Login = login
+						auth.ID = 1
+						auth.Status = models.AuthorizationAuthorized
+						return v.dbErr
+					})
+			}
+
+			w := httptest.NewRecorder()
+			h.authorize()(w, merchantRequest(http.MethodPost, v.body, v.key, ""))
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, v.expCode, res.StatusCode)
+		})
+	}
+}
+
+func TestPaymentToken(t *testing.T) {
+	testCases := []struct {
+		name    string
+		body    string
+		auth    bool
+		callDB  bool
+		expCode int
+	}{
+		{name: "ok", body: `{"merchant": "shop", "max_amount": 100}`, auth: true, callDB: true, expCode: http.StatusCreated},
+		{name: "any_merchant", body: `{}`, auth: true, callDB: true, expCode: http.StatusCreated},
+		{name: "unknown_merchant", body: `{"merchant": "other"}`, auth: true, expCode: http.StatusUnprocessableEntity},
+		{name: "wrong_amount", body: `{"max_amount": -1}`, auth: true, expCode: http.StatusUnprocessableEntity},
+		{name: "no_session", body: `{}`, expCode: http.StatusUnauthorized},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db := mock.NewMockStore(ctrl)
+
+			h := &handlers{
+				ctx:       context.Background(),
+				seckey:    seckey,
+				db:        db,
+				retry:     retry.NewRetry(),
+				merchants: map[string]string{merchantKey: merchantName},
+			}
+
+			if v.callDB {
+				var tokenPtr *models.PaymentToken
+				db.EXPECT().CreatePaymentToken(gomock.Any(), userID, gomock.AssignableToTypeOf(tokenPtr)).DoAndReturn(
+					func(ctx context.Context, userid int64, token *models.PaymentToken) error {
+						token.Token = "tok"
+						token.ExpiresAt = "2024-07-21T12:10:00Z"
+						return nil
+					})
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(v.body))
+			r.Header.Add("Content-Type", "application/json")
+			if v.auth {
+				r = withUserToken(t, r, userID)
+			}
+			w := httptest.NewRecorder()
+			h.paymentToken()(w, r)
+			res := w.Result()
+			b, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			assert.Equal(t, v.expCode, res.StatusCode)
+			if v.name == "ok" {
+				assert.JSONEq(t, `{"token": "tok", "merchant": "shop", "max_amount": 100, "expires_at": "2024-07-21T12:10:00Z"}`, string(b))
+			}
+		})
+	}
+}
+
+func TestCaptureAuthorization(t *testing.T) {
+	testCases := []struct {
+		name    string
+		body    string
+		dbErr   error
+		callDB  bool
+		expCode int
+	}{
+		{name: "partial", body: `{"amount": 80, "order": "12345678903"}`, callDB: true, expCode: http.StatusOK},
+		{name: "wrong_order", body: `{"amount": 80, "order": "12345678900"}`, expCode: http.StatusUnprocessableEntity},
+		{name: "over_amount", body: `{"amount": 180, "order": "12345678903"}`, callDB: true, dbErr: prjerrors.ErrAuthorizationAmount, expCode: http.StatusUnprocessableEntity},
+		{name: "voided", body: `{"amount": 80, "order": "12345678903"}`, callDB: true, dbErr: prjerrors.ErrAuthorizationState, expCode: http.StatusConflict},
+		{name: "other_merchant", body: `{"amount": 80, "order": "12345678903"}`, callDB: true, dbErr: prjerrors.ErrAuthorizationNotFound, expCode: http.StatusNotFound},
+		{name: "limit", body: `{"amount": 80, "order": "12345678903"}`, callDB: true, dbErr: prjerrors.ErrWithdrawalLimit, expCode: http.StatusForbidden},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db := mock.NewMockStore(ctrl)
+
+			h := &handlers{
+				ctx:       context.Background(),
+				db:        db,
+				retry:     retry.NewRetry(),
+				merchants: map[string]string{merchantKey: merchantName},
+			}
+
+			if v.callDB {
+				var authPtr *models.Authorization
+				db.EXPECT().CaptureAuthorization(gomock.Any(), gomock.AssignableToTypeOf(&models.AuthorizationOp{}), gomock.AssignableToTypeOf(authPtr)).DoAndReturn(
+					func(ctx context.Context, op *models.AuthorizationOp, auth *models.Authorization) error {
+						assert.Equal(t, &models.AuthorizationOp{Merchant: merchantName, ID: 7, Amount: op.Amount, Order: "12345678903"}, op)
+						*auth = models.Authorization{ID: 7, Login: login, Amount: 100, Captured: op.Amount, Order: op.Order, Status: models.AuthorizationCaptured}
+						return v.dbErr
+					})
+			}
+
+			w := httptest.NewRecorder()
+			h.captureAuthorization()(w, merchantRequest(http.MethodPost, v.body, merchantKey, "7"))
+			res := w.Result()
+			b, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			assert.Equal(t, v.expCode, res.StatusCode)
+			if v.expCode == http.StatusOK {
+				assert.JSONEq(t, `{"id": 7, "login": "test", "amount": 100, "captured": 80, "refunded": 0,
+					"order": "12345678903", "status": "CAPTURED", "created_at": "", "expires_at": ""}`, string(b))
+			}
+		})
+	}
+}
+
+func TestVoidRefundAuthorization(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	h := &handlers{
+		ctx:       context.Background(),
+		db:        db,
+		retry:     retry.NewRetry(),
+		merchants: map[string]string{merchantKey: merchantName},
+	}
+
+	db.EXPECT().VoidAuthorization(gomock.Any(), &models.AuthorizationOp{Merchant: merchantName, ID: 7}, gomock.Any()).Return(nil)
+	w := httptest.NewRecorder()
+	h.voidAuthorization()(w, merchantRequest(http.MethodPost, "", merchantKey, "7"))
+	res := w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	db.EXPECT().RefundAuthorization(gomock.Any(), &models.AuthorizationOp{Merchant: merchantName, ID: 7, Amount: 20}, gomock.Any()).Return(nil)
+	w = httptest.NewRecorder()
+	h.refundAuthorization()(w, merchantRequest(http.MethodPost, `{"amount": 20}`, merchantKey, "7"))
+	res = w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	w = httptest.NewRecorder()
+	h.refundAuthorization()(w, merchantRequest(http.MethodPost, `{"amount": -20}`, merchantKey, "7"))
+	res = w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+}
+
+func TestAuthorizationsExpiry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	db.EXPECT().ExpireAuthorizations(gomock.Any()).Return(int64(2), nil)
+	require.NoError(t, authorizationsExpiry(context.Background(), db))
+
+	db.EXPECT().ExpireAuthorizations(gomock.Any()).Return(int64(0), errors.New("db down"))
+	require.Error(t, authorizationsExpiry(context.Background(), db))
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/sourcecd/gofermart/internal/auth"
+	"github.com/sourcecd/gofermart/internal/models"
+)
+
+func (h *handlers) referral() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		gettoken, err := checkRequestCreds(r)
+		if err != nil {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		userid, err := auth.ParseJWT(gettoken, h.seckey)
+		if err != nil {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var referral models.Referral
+		if err := h.retry.ReferralFuncRetry(h.db.GetReferral)(h.ctx, userid, &referral); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, referral)
+	}
+}
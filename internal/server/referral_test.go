@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/sourcecd/gofermart/internal/retry"
+	"github.com/sourcecd/gofermart/internal/storage/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterUserReferral(t *testing.T) {
+	const (
+		code     = "ABCD2345"
+		friendID = int64(200)
+	)
+	testCases := []struct {
+		name       string
+		session    int64
+		dbErr      error
+		expSession int64
+		expCode    int
+	}{
+		{name: "ok", expCode: http.StatusOK},
+		{name: "friend_session", session: friendID, expSession: friendID, expCode: http.StatusOK},
+		{name: "unknown_code", dbErr: prjerrors.ErrReferralCode, expCode: http.StatusBadRequest},
+		{name: "self_referral", session: userID, expSession: userID, dbErr: prjerrors.ErrSelfReferral, expCode: http.StatusForbidden},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db := mock.NewMockStore(ctrl)
+
+			h := &handlers{
+				ctx:    context.Background(),
+				seckey: seckey,
+				db:     db,
+				retry:  retry.NewRetry(),
+			}
+
+			db.EXPECT().RegisterUser(gomock.Any(), &models.User{
+				Login:        "friend",
+				Password:     password,
+				ReferralCode: code,
+				SessionUser:  v.expSession,
+			}).Return(int64(300), v.dbErr)
+
+			body := fmt.Sprintf(`{"login": "friend", "password": "%s", "referral_code": "%s"}`, password, code)
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			r.Header.Add("Content-Type", "application/json")
+			if v.session != 0 {
+				r = withUserToken(t, r, v.session)
+			}
+			w := httptest.NewRecorder()
+			h.registerUser()(w, r)
+
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, v.expCode, res.StatusCode)
+		})
+	}
+}
+
+func TestReferral(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	h := &handlers{
+		ctx:    context.Background(),
+		seckey: seckey,
+		db:     db,
+		retry:  retry.NewRetry(),
+	}
+
+	var referralPtr *models.Referral
+	db.EXPECT().GetReferral(gomock.Any(), userID, gomock.AssignableToTypeOf(referralPtr)).DoAndReturn(
+		func(ctx context.Context, userid int64, referral *models.Referral) error {
+			*referral = models.Referral{Code: "ABCD2345", Invited: 3, Rewarded: 1, Earned: 500}
+			return nil
+		})
+
+	w := httptest.NewRecorder()
+	h.referral()(w, withUserToken(t, httptest.NewRequest(http.MethodGet, "/", nil), userID))
+	res := w.Result()
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.JSONEq(t, `{"code": "ABCD2345", "invited": 3, "rewarded": 1, "earned": 500}`, string(b))
+
+	w = httptest.NewRecorder()
+	h.referral()(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	res = w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+}
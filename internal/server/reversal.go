@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+// reversalParse reads optional {"amount": 20, "reason": "..."} body, no
+// amount reverses all that is left.
+func reversalParse(r *http.Request) (*models.Reversal, error) {
+	num, err := orderNumberParam(r)
+	if err != nil {
+		return nil, err
+	}
+	var body struct {
+		Amount float64 `json:"amount"`
+		Reason string  `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return nil, prjerrors.ErrReqJSONParse
+		}
+	}
+	if body.Amount < 0 {
+		return nil, errors.New("wrong reversal amount")
+	}
+	return &models.Reversal{Order: fmt.Sprint(num), Amount: body.Amount, Reason: body.Reason}, nil
+}
+
+func (h *handlers) writeReversal(w http.ResponseWriter, reversal *models.Reversal) {
+	if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.ReverseWithdrawal(ctx, reversal) }); err != nil {
+		switch {
+		case errors.Is(err, prjerrors.ErrOrderNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, prjerrors.ErrWithdrawalNotFinal):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, prjerrors.ErrReversalAmount):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, reversal)
+}
+
+func (h *handlers) reverseWithdrawal() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		author, err := h.checkAdmin(h.ctx, r)
+		if err != nil {
+			adminError(w, err)
+			return
+		}
+		reversal, err := reversalParse(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reversal.Author = author
+		h.writeReversal(w, reversal)
+	}
+}
+
+// merchantReverseWithdrawal reverses withdrawal captured by the merchant
+// authorization only.
+func (h *handlers) merchantReverseWithdrawal() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		merchant, err := h.checkMerchant(r)
+		if err != nil {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		reversal, err := reversalParse(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reversal.Merchant = merchant
+		h.writeReversal(w, reversal)
+	}
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/sourcecd/gofermart/internal/retry"
+	"github.com/sourcecd/gofermart/internal/storage/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReverseWithdrawal(t *testing.T) {
+	const number = "12345678903"
+	testCases := []struct {
+		name      string
+		body      string
+		expAmount float64
+		dbErr     error
+		callDB    bool
+		expCode   int
+	}{
+		{name: "full", callDB: true, expCode: http.StatusOK},
+		{name: "partial", body: `{"amount": 20, "reason": "returned item"}`, expAmount: 20, callDB: true, expCode: http.StatusOK},
+		{name: "negative", body: `{"amount": -20}`, expCode: http.StatusBadRequest},
+		{name: "over_amount", body: `{"amount": 200}`, expAmount: 200, callDB: true, dbErr: prjerrors.ErrReversalAmount, expCode: http.StatusUnprocessableEntity},
+		{name: "pending_approval", callDB: true, dbErr: prjerrors.ErrWithdrawalNotFinal, expCode: http.StatusConflict},
+		{name: "not_found", callDB: true, dbErr: prjerrors.ErrOrderNotFound, expCode: http.StatusNotFound},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db := mock.NewMockStore(ctrl)
+
+			h := &handlers{
+				ctx:    context.Background(),
+				seckey: seckey,
+				db:     db,
+				retry:  retry.NewRetry(),
+				admins: []string{adminLogin},
+			}
+
+			db.EXPECT().GetUserLogin(gomock.Any(), userID).Return(adminLogin, nil)
+			if v.callDB {
+				var reversalPtr *models.Reversal
+				db.EXPECT().ReverseWithdrawal(gomock.Any(), gomock.AssignableToTypeOf(reversalPtr)).DoAndReturn(
+					func(ctx context.Context, reversal *models.Reversal) error {
+						assert.Equal(t, number, reversal.Order)
+						assert.Equal(t, adminLogin, reversal.Author)
+						assert.Equal(t, v.expAmount, reversal.Amount)
+						if reversal.Amount == 0 {
+							reversal.Amount = 100
+						}
+						reversal.Reversed = reversal.Amount
+						reversal.Remaining = 100 - reversal.Amount
+						return v.dbErr
+					})
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(v.body))
+			w := httptest.NewRecorder()
+			h.reverseWithdrawal()(w, withURLParams(withUserToken(t, r, userID), map[string]string{"number": number}))
+
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, v.expCode, res.StatusCode)
+		})
+	}
+}
+
+func TestMerchantReverseWithdrawal(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	h := &handlers{
+		ctx:       context.Background(),
+		db:        db,
+		retry:     retry.NewRetry(),
+		merchants: map[string]string{merchantKey: merchantName},
+	}
+
+	db.EXPECT().ReverseWithdrawal(gomock.Any(), &models.Reversal{Order: "12345678903", Amount: 30, Merchant: merchantName}).DoAndReturn(
+		func(ctx context.Context, reversal *models.Reversal) error {
+			reversal.Reversed = 30
+			reversal.Remaining = 50
+			return nil
+		})
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"amount": 30}`))
+	r.Header.Add("X-Merchant-Key", merchantKey)
+	w := httptest.NewRecorder()
+	h.merchantReverseWithdrawal()(w, withURLParams(r, map[string]string{"number": "12345678903"}))
+	res := w.Result()
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.JSONEq(t, `{"order": "12345678903", "amount": 30, "merchant": "shop", "reversed": 30, "remaining": 50}`, string(b))
+
+	r = httptest.NewRequest(http.MethodPost, "/", nil)
+	w = httptest.NewRecorder()
+	h.merchantReverseWithdrawal()(w, withURLParams(r, map[string]string{"number": "12345678903"}))
+	res = w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+}
+
+func TestWithdrawalsReversed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	h := &handlers{
+		ctx:    context.Background(),
+		seckey: seckey,
+		db:     db,
+		retry:  retry.NewRetry(),
+	}
+
+	var withdrawalsPtr *[]models.Withdrawals
+	db.EXPECT().Withdrawals(gomock.Any(), userID, gomock.AssignableToTypeOf(withdrawalsPtr)).DoAndReturn(
+		func(ctx context.Context, userid int64, withdrawals *[]models.Withdrawals) error {
+			*withdrawals = append(*withdrawals, models.Withdrawals{
+				Order:       "12345678903",
+				Sum:         100,
+				ProcessedAt: "2024-07-15T10:00:00Z",
+				Reversed:    30,
+			})
+			return nil
+		})
+
+	w := httptest.NewRecorder()
+	h.withdrawals()(w, withUserToken(t, httptest.NewRequest(http.MethodGet, "/", nil), userID))
+	res := w.Result()
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.JSONEq(t, `[{"order": "12345678903", "sum": 100, "processed_at": "2024-07-15T10:00:00Z", "reversed": 30}]`, string(b))
+}
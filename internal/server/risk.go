@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+// screen evaluates event with risk rules and records the decision, events
+// are allowed and not recorded when risk rules are disabled.
+func (h *handlers) screen(event *models.RiskEvent) error {
+	if h.risk == nil {
+		event.Action = models.RiskAllow
+		return nil
+	}
+	var profile models.RiskProfile
+	if err := h.retry.RiskProfileFuncRetry(h.db.RiskProfile)(h.ctx, event.UserID, &profile); err != nil {
+		return err
+	}
+	h.risk.Evaluate(event, &profile, time.Now())
+	return h.retry.RiskEventFuncRetry(h.db.AddRiskEvent)(h.ctx, event)
+}
+
+// riskEvents lists flagged events waiting for review, action and reviewed
+// query params select blocked or already reviewed ones.
+func (h *handlers) riskEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, err := h.checkAdmin(h.ctx, r); err != nil {
+			adminError(w, err)
+			return
+		}
+		action := r.URL.Query().Get("action")
+		switch action {
+		case "":
+			action = models.RiskFlag
+		case models.RiskFlag, models.RiskBlock:
+		default:
+			http.Error(w, "wrong action", http.StatusBadRequest)
+			return
+		}
+		var reviewed bool
+		if v := r.URL.Query().Get("reviewed"); v != "" {
+			var err error
+			if reviewed, err = strconv.ParseBool(v); err != nil {
+				http.Error(w, "wrong reviewed", http.StatusBadRequest)
+				return
+			}
+		}
+		page, err := pageParams(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var events []models.RiskEvent
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.ListRiskEvents(ctx, action, reviewed, page, &events) }); err != nil {
+			if errors.Is(err, prjerrors.ErrEmptyData) {
+				http.Error(w, err.Error(), http.StatusNoContent)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, events)
+	}
+}
+
+// reviewRiskEvent resolves flagged or blocked event, body is
+// {"resolution": "CONFIRMED", "note": "..."}, resolution is CONFIRMED or
+// DISMISSED. Dismissing a blocked order unblocks the user orders.
+func (h *handlers) reviewRiskEvent() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := checkContentType(r, "application/json"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		author, err := h.checkAdmin(h.ctx, r)
+		if err != nil {
+			adminError(w, err)
+			return
+		}
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil || id <= 0 {
+			http.Error(w, "wrong risk event id", http.StatusBadRequest)
+			return
+		}
+
+		var body struct {
+			Resolution string `json:"resolution"`
+			Note       string `json:"note"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, prjerrors.ErrReqJSONParse.Error(), http.StatusBadRequest)
+			return
+		}
+		if body.Resolution != models.RiskConfirmed && body.Resolution != models.RiskDismissed {
+			http.Error(w, "wrong resolution", http.StatusBadRequest)
+			return
+		}
+		review := &models.RiskReview{ID: id, Resolution: body.Resolution, Author: author, Note: body.Note}
+
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.ReviewRiskEvent(ctx, review) }); err != nil {
+			switch {
+			case errors.Is(err, prjerrors.ErrRiskEventNotFound):
+				http.Error(w, err.Error(), http.StatusNotFound)
+			case errors.Is(err, prjerrors.ErrRiskEventReviewed):
+				http.Error(w, err.Error(), http.StatusConflict)
+			default:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("\n"))
+	}
+}
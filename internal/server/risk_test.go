@@ -0,0 +1,225 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/sourcecd/gofermart/internal/retry"
+	"github.com/sourcecd/gofermart/internal/risk"
+	"github.com/sourcecd/gofermart/internal/storage/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderRegisterRisk(t *testing.T) {
+	testCases := []struct {
+		name    string
+		order   string
+		streak  int
+		expCode int
+		expRisk string
+	}{
+		{name: "invalid", order: "12345678900", streak: 0, expCode: http.StatusUnprocessableEntity, expRisk: models.RiskAllow},
+		{name: "invalid_flag", order: "12345678900", streak: 1, expCode: http.StatusUnprocessableEntity, expRisk: models.RiskFlag},
+		{name: "invalid_block", order: "12345678900", streak: 2, expCode: http.StatusForbidden, expRisk: models.RiskBlock},
+		{name: "guessed", order: "12345678903", streak: 3, expCode: http.StatusForbidden, expRisk: models.RiskBlock},
+		{name: "valid", order: "12345678903", streak: 0, expCode: http.StatusAccepted, expRisk: models.RiskAllow},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db := mock.NewMockStore(ctrl)
+
+			h := &handlers{
+				ctx:    context.Background(),
+				seckey: seckey,
+				db:     db,
+				retry:  retry.NewRetry(),
+				risk: risk.NewEngine(
+					&risk.InvalidOrders{Streak: 2, Action: models.RiskFlag},
+					&risk.InvalidOrders{Streak: 3, Action: models.RiskBlock},
+				),
+			}
+
+			var profilePtr *models.RiskProfile
+			db.EXPECT().RiskProfile(gomock.Any(), userID, gomock.AssignableToTypeOf(profilePtr)).DoAndReturn(
+				func(ctx context.Context, userid int64, profile *models.RiskProfile) error {
+					profile.InvalidStreak = v.streak
+					return nil
+				})
+			var eventPtr *models.RiskEvent
+			db.EXPECT().AddRiskEvent(gomock.Any(), gomock.AssignableToTypeOf(eventPtr)).DoAndReturn(
+				func(ctx context.Context, event *models.RiskEvent) error {
+					assert.Equal(t, userID, event.UserID)
+					assert.Equal(t, models.RiskEventOrder, event.Kind)
+					assert.Equal(t, v.order, event.Order)
+					assert.Equal(t, v.expRisk, event.Action)
+					return nil
+				})
+			if v.expCode == http.StatusAccepted {
+				db.EXPECT().CreateOrder(gomock.Any(), userID, int64(12345678903)).Return(nil)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(v.order))
+			r.Header.Add("Content-Type", "text/plain")
+			w := httptest.NewRecorder()
+			h.orderRegister()(w, withUserToken(t, r, userID))
+
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, v.expCode, res.StatusCode)
+		})
+	}
+}
+
+func TestWithdrawRisk(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	h := &handlers{
+		ctx:    context.Background(),
+		seckey: seckey,
+		db:     db,
+		retry:  retry.NewRetry(),
+		risk:   risk.NewEngine(&risk.FastWithdrawal{Window: time.Hour, Share: 0.9, Action: models.RiskBlock}),
+	}
+
+	db.EXPECT().RiskProfile(gomock.Any(), userID, gomock.Any()).DoAndReturn(
+		func(ctx context.Context, userid int64, profile *models.RiskProfile) error {
+			profile.FirstAccrualAt = time.Now().Add(-time.Minute)
+			profile.Current = 100
+			return nil
+		})
+	var eventPtr *models.RiskEvent
+	db.EXPECT().AddRiskEvent(gomock.Any(), gomock.AssignableToTypeOf(eventPtr)).DoAndReturn(
+		func(ctx context.Context, event *models.RiskEvent) error {
+			assert.Equal(t, models.RiskEventWithdrawal, event.Kind)
+			assert.Equal(t, float64(100), event.Sum)
+			assert.Equal(t, models.RiskBlock, event.Action)
+			assert.Equal(t, risk.RuleFastWithdrawal, event.Rule)
+			return nil
+		})
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"order": "12345678903", "sum": 100}`))
+	r.Header.Add("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.withdraw()(w, withUserToken(t, r, userID))
+
+	res := w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+}
+
+func TestRiskEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	h := &handlers{
+		ctx:    context.Background(),
+		seckey: seckey,
+		db:     db,
+		retry:  retry.NewRetry(),
+		admins: []string{adminLogin},
+	}
+
+	db.EXPECT().GetUserLogin(gomock.Any(), userID).Return(adminLogin, nil).Times(2)
+	var eventsPtr *[]models.RiskEvent
+	db.EXPECT().ListRiskEvents(gomock.Any(), models.RiskBlock, true, models.Page{Limit: defaultPageLimit},
+		gomock.AssignableToTypeOf(eventsPtr)).DoAndReturn(
+		func(ctx context.Context, action string, reviewed bool, page models.Page, events *[]models.RiskEvent) error {
+			*events = append(*events, models.RiskEvent{
+				ID:         1,
+				Login:      "user",
+				Kind:       models.RiskEventOrder,
+				Order:      "12345678903",
+				Valid:      true,
+				Action:     models.RiskBlock,
+				Rule:       risk.RuleInvalidOrders,
+				CreatedAt:  "2024-07-17T10:00:00Z",
+				Resolution: models.RiskConfirmed,
+				ReviewedBy: adminLogin,
+				ReviewedAt: "2024-07-17T11:00:00Z",
+			})
+			return nil
+		})
+
+	w := httptest.NewRecorder()
+	h.riskEvents()(w, withUserToken(t, httptest.NewRequest(http.MethodGet, "/?action=BLOCK&reviewed=true", nil), userID))
+	res := w.Result()
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.JSONEq(t, `[{"id": 1, "login": "user", "kind": "order", "order": "12345678903", "valid": true,
+		"action": "BLOCK", "rule": "invalid_orders", "created_at": "2024-07-17T10:00:00Z",
+		"resolution": "CONFIRMED", "reviewed_by": "admin", "reviewed_at": "2024-07-17T11:00:00Z"}]`, string(b))
+
+	w = httptest.NewRecorder()
+	h.riskEvents()(w, withUserToken(t, httptest.NewRequest(http.MethodGet, "/?action=ALLOW", nil), userID))
+	res = w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestReviewRiskEvent(t *testing.T) {
+	testCases := []struct {
+		name    string
+		body    string
+		dbErr   error
+		callDB  bool
+		expCode int
+	}{
+		{name: "ok", body: `{"resolution": "DISMISSED", "note": "known customer"}`, callDB: true, expCode: http.StatusOK},
+		{name: "wrong_resolution", body: `{"resolution": "MAYBE"}`, expCode: http.StatusBadRequest},
+		{name: "not_found", body: `{"resolution": "CONFIRMED"}`, callDB: true, dbErr: prjerrors.ErrRiskEventNotFound, expCode: http.StatusNotFound},
+		{name: "reviewed", body: `{"resolution": "CONFIRMED"}`, callDB: true, dbErr: prjerrors.ErrRiskEventReviewed, expCode: http.StatusConflict},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db := mock.NewMockStore(ctrl)
+
+			h := &handlers{
+				ctx:    context.Background(),
+				seckey: seckey,
+				db:     db,
+				retry:  retry.NewRetry(),
+				admins: []string{adminLogin},
+			}
+
+			db.EXPECT().GetUserLogin(gomock.Any(), userID).Return(adminLogin, nil)
+			if v.callDB {
+				var reviewPtr *models.RiskReview
+				db.EXPECT().ReviewRiskEvent(gomock.Any(), gomock.AssignableToTypeOf(reviewPtr)).DoAndReturn(
+					func(ctx context.Context, review *models.RiskReview) error {
+						assert.Equal(t, int64(7), review.ID)
+						assert.Equal(t, adminLogin, review.Author)
+						return v.dbErr
+					})
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(v.body))
+			r.Header.Add("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			h.reviewRiskEvent()(w, withURLParams(withUserToken(t, r, userID), map[string]string{"id": "7"}))
+
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, v.expCode, res.StatusCode)
+		})
+	}
+}
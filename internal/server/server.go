@@ -15,7 +15,7 @@ import (
 
 	"github.com/asaskevich/govalidator"
 	"github.com/go-chi/chi/v5"
-	"github.com/go-resty/resty/v2"
+	"github.com/sourcecd/gofermart/internal/accrual"
 	"github.com/sourcecd/gofermart/internal/auth"
 	"github.com/sourcecd/gofermart/internal/compression"
 	"github.com/sourcecd/gofermart/internal/config"
@@ -23,6 +23,7 @@ import (
 	"github.com/sourcecd/gofermart/internal/models"
 	"github.com/sourcecd/gofermart/internal/prjerrors"
 	"github.com/sourcecd/gofermart/internal/retry"
+	"github.com/sourcecd/gofermart/internal/risk"
 	"github.com/sourcecd/gofermart/internal/storage"
 	"golang.org/x/sync/errgroup"
 
@@ -32,14 +33,53 @@ import (
 const (
 	cookieMaxAge       = 43200
 	pollInterval       = 1
+	healthInterval     = 10
+	expiryInterval     = 3600
+	tiersInterval      = 3600
+	authExpiryInterval = 60
 	serverShutdownTime = 10
 )
 
+const (
+	healthOK          = "ok"
+	healthDegraded    = "degraded"
+	healthUnavailable = "unavailable"
+)
+
 type handlers struct {
-	ctx    context.Context
-	seckey string
-	db     storage.Store
-	retry  *retry.Retry
+	ctx     context.Context
+	seckey  string
+	db      storage.Store
+	retry   *retry.Retry
+	accrual accrual.Client
+	// endpoints are remote accrual system instances, empty in local mode
+	endpoints []*accrual.Endpoint
+	// rules is the local accrual rules engine, nil in remote mode
+	rules *accrual.Rules
+
+	accrualMode     string
+	accrualRegister bool
+	webhookSecret   string
+	admins          []string
+	transferLimits  models.TransferLimits
+	// tiers is nil when loyalty tiers are disabled
+	tiers *models.TierPolicy
+	// merchants maps merchant API key to merchant name
+	merchants map[string]string
+	// risk is nil when risk rules are disabled
+	risk *risk.Engine
+}
+
+// writeJSON sends v indented with status code.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := enc.Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 }
 
 func checkRequestCreds(r *http.Request) (string, error) {
@@ -87,6 +127,14 @@ func checkContentType(r *http.Request, contentType string) error {
 	return nil
 }
 
+func orderNumberParam(r *http.Request) (int64, error) {
+	num, err := strconv.ParseInt(chi.URLParam(r, "number"), 10, 64)
+	if err != nil {
+		return 0, errors.New("order number is not number")
+	}
+	return num, nil
+}
+
 func (h *handlers) registerUser() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := checkContentType(r, "application/json"); err != nil {
@@ -99,14 +147,25 @@ func (h *handlers) registerUser() http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		// registering from the inviting user session is a self-referral
+		if gettoken, err := checkRequestCreds(r); err == nil {
+			if userid, err := auth.ParseJWT(gettoken, h.seckey); err == nil {
+				reg.SessionUser = userid
+			}
+		}
 
 		id, err := h.retry.UserFuncRetry(h.db.RegisterUser)(h.ctx, reg)
 		if err != nil {
-			if errors.Is(err, prjerrors.ErrAlreadyExists) {
+			switch {
+			case errors.Is(err, prjerrors.ErrAlreadyExists):
 				http.Error(w, err.Error(), http.StatusConflict)
-				return
+			case errors.Is(err, prjerrors.ErrReferralCode):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			case errors.Is(err, prjerrors.ErrSelfReferral):
+				http.Error(w, err.Error(), http.StatusForbidden)
+			default:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
 			}
-			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -157,9 +216,37 @@ func (h *handlers) authUser() http.HandlerFunc {
 	}
 }
 
+func receiptParse(body []byte) (*models.AccrualOrder, error) {
+	receipt := &models.AccrualOrder{}
+	if err := json.Unmarshal(body, receipt); err != nil {
+		return nil, prjerrors.ErrReqJSONParse
+	}
+	if len(receipt.Goods) == 0 {
+		return nil, prjerrors.ErrValidateReceipt
+	}
+	for _, v := range receipt.Goods {
+		if v.Description == "" || v.Price <= 0 {
+			return nil, prjerrors.ErrValidateReceipt
+		}
+	}
+	return receipt, nil
+}
+
+// registerAccrualOrder passes the receipt to the accrual system when
+// gophermart is the registering party, failures are retried by the poller.
+func (h *handlers) registerAccrualOrder(receipt *models.AccrualOrder) {
+	if !h.accrualRegister {
+		return
+	}
+	if err := h.accrual.RegisterOrder(h.ctx, receipt); err != nil && !errors.Is(err, prjerrors.ErrAccrualAlreadyExists) {
+		slog.Error(err.Error())
+	}
+}
+
 func (h *handlers) orderRegister() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if err := checkContentType(r, "text/plain"); err != nil {
+		jsonReceipt := checkContentType(r, "application/json") == nil
+		if err := checkContentType(r, "text/plain"); err != nil && !jsonReceipt {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
@@ -180,17 +267,40 @@ func (h *handlers) orderRegister() http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		ordnum, err := strconv.Atoi(string(body))
+		receipt := &models.AccrualOrder{Order: string(body)}
+		if jsonReceipt {
+			receipt, err = receiptParse(body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		ordnum, err := strconv.Atoi(receipt.Order)
 		if err != nil {
 			http.Error(w, "order number is not number", http.StatusBadRequest)
 			return
 		}
-		if !luhn.Valid(ordnum) {
+		// invalid numbers are screened too, rules look for guessing
+		event := &models.RiskEvent{UserID: userid, Kind: models.RiskEventOrder, Order: receipt.Order, Valid: luhn.Valid(ordnum)}
+		if err := h.screen(event); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if event.Action == models.RiskBlock {
+			http.Error(w, prjerrors.ErrRiskBlocked.Error(), http.StatusForbidden)
+			return
+		}
+		if !event.Valid {
 			http.Error(w, "luhn number is not valid", http.StatusUnprocessableEntity)
 			return
 		}
 
-		if err := h.retry.CreateOrderFuncRetry(h.db.CreateOrder)(h.ctx, userid, int64(ordnum)); err != nil {
+		if jsonReceipt {
+			err = h.retry.CreateOrderReceiptFuncRetry(h.db.CreateOrderReceipt)(h.ctx, userid, receipt)
+		} else {
+			err = h.retry.CreateOrderFuncRetry(h.db.CreateOrder)(h.ctx, userid, int64(ordnum))
+		}
+		if err != nil {
 			if errors.Is(err, prjerrors.ErrOrderAlreadyExists) {
 				http.Error(w, err.Error(), http.StatusOK)
 				return
@@ -202,6 +312,9 @@ func (h *handlers) orderRegister() http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if jsonReceipt {
+			h.registerAccrualOrder(receipt)
+		}
 
 		w.WriteHeader(http.StatusAccepted)
 		w.Write([]byte(fmt.Sprint(ordnum)))
@@ -241,6 +354,38 @@ func (h *handlers) ordersList() http.HandlerFunc {
 	}
 }
 
+func (h *handlers) orderHistory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		gettoken, err := checkRequestCreds(r)
+		if err != nil {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		userid, err := auth.ParseJWT(gettoken, h.seckey)
+		if err != nil {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		ordnum, err := orderNumberParam(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var history []models.OrderStatusChange
+		if err := h.retry.HistoryFuncRetry(h.db.OrderStatusHistory)(h.ctx, userid, ordnum, &history); err != nil {
+			if errors.Is(err, prjerrors.ErrOrderNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, history)
+	}
+}
+
 func (h *handlers) getBalance() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		gettoken, err := checkRequestCreds(r)
@@ -256,8 +401,15 @@ func (h *handlers) getBalance() http.HandlerFunc {
 
 		var balance models.Balance
 		if err := h.retry.GetBalanceFuncRetry(h.db.GetBalance)(h.ctx, userid, &balance); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if balance.Pending != nil && h.rules != nil {
+			if err := h.pendingAmount(userid, balance.Pending); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
 
 		enc := json.NewEncoder(w)
 		enc.SetIndent("", "  ")
@@ -270,6 +422,26 @@ func (h *handlers) getBalance() http.HandlerFunc {
 	}
 }
 
+// pendingAmount sets accrual expected by the local rules engine for pending
+// orders, it stays unknown when some order has no receipt goods.
+func (h *handlers) pendingAmount(userid int64, pending *models.PendingAccrual) error {
+	var orders []models.AccrualOrder
+	if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.PendingOrders(ctx, userid, &orders) }); err != nil {
+		return err
+	}
+	var amount float64
+	for _, v := range orders {
+		if len(v.Goods) == 0 {
+			return nil
+		}
+		if reward, matched := h.rules.Reward(v.Goods); matched {
+			amount += reward
+		}
+	}
+	pending.Amount = &amount
+	return nil
+}
+
 func (h *handlers) withdraw() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := checkContentType(r, "application/json"); err != nil {
@@ -307,6 +479,15 @@ func (h *handlers) withdraw() http.HandlerFunc {
 			http.Error(w, "wrong withdraw sum", http.StatusUnprocessableEntity)
 			return
 		}
+		event := &models.RiskEvent{UserID: userid, Kind: models.RiskEventWithdrawal, Order: withdraw.Order, Sum: withdraw.Sum, Valid: true}
+		if err := h.screen(event); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if event.Action == models.RiskBlock {
+			http.Error(w, prjerrors.ErrRiskBlocked.Error(), http.StatusForbidden)
+			return
+		}
 
 		if err := h.retry.WithdrawFuncRetry(h.db.Withdraw)(h.ctx, userid, &withdraw); err != nil {
 			if errors.Is(err, prjerrors.ErrNotEnough) {
@@ -317,9 +498,19 @@ func (h *handlers) withdraw() http.HandlerFunc {
 				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
 				return
 			}
+			if errors.Is(err, prjerrors.ErrWithdrawalLimit) {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		// sum is on hold until admin approval
+		if withdraw.Status == models.WithdrawalPendingApproval {
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("\n"))
+			return
+		}
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("\n"))
 	}
@@ -359,61 +550,178 @@ func (h *handlers) withdrawals() http.HandlerFunc {
 	}
 }
 
+func (h *handlers) health() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		health := models.Health{
+			Status:   healthOK,
+			Database: healthOK,
+			Accrual: models.AccrualHealth{
+				Mode:    h.accrualMode,
+				Breaker: accrual.BreakerClosed.String(),
+			},
+		}
+		status := http.StatusOK
+
+		// overall breaker is open only when no endpoint can take calls
+		degraded := false
+		for i, e := range h.endpoints {
+			state := e.State()
+			if state != accrual.BreakerClosed {
+				degraded = true
+			}
+			if i == 0 || health.Accrual.Breaker == accrual.BreakerOpen.String() {
+				health.Accrual.Breaker = state.String()
+			}
+			health.Accrual.Endpoints = append(health.Accrual.Endpoints, models.AccrualEndpointHealth{
+				URL:     e.URL,
+				Breaker: state.String(),
+			})
+		}
+
+		ctx, cancel := context.WithTimeout(h.ctx, h.retry.GetTimeoutCtx())
+		defer cancel()
+		if err := h.db.Ping(ctx); err != nil {
+			health.Status = healthUnavailable
+			health.Database = err.Error()
+			status = http.StatusServiceUnavailable
+		} else if degraded {
+			health.Status = healthDegraded
+		}
+
+		writeJSON(w, status, health)
+	}
+}
+
 func webRouter(h *handlers) *chi.Mux {
 	mux := chi.NewRouter()
 	mux.Post("/api/user/register", logging.WriteLogging(compression.GzipCompressDecompress(h.registerUser())))
 	mux.Post("/api/user/login", logging.WriteLogging(compression.GzipCompressDecompress(h.authUser())))
 	mux.Post("/api/user/orders", logging.WriteLogging(compression.GzipCompressDecompress(h.orderRegister())))
 	mux.Get("/api/user/orders", logging.WriteLogging(compression.GzipCompressDecompress(h.ordersList())))
+	mux.Get("/api/user/orders/{number}/history", logging.WriteLogging(compression.GzipCompressDecompress(h.orderHistory())))
 	mux.Get("/api/user/balance", logging.WriteLogging(compression.GzipCompressDecompress(h.getBalance())))
+	mux.Get("/api/user/balance/history", logging.WriteLogging(compression.GzipCompressDecompress(h.balanceHistory())))
+	mux.Get("/api/user/balance/as-of", logging.WriteLogging(compression.GzipCompressDecompress(h.balanceAsOf())))
+	mux.Get("/api/user/balance/statement", logging.WriteLogging(compression.GzipCompressDecompress(h.statement())))
+	mux.Get("/api/user/referral", logging.WriteLogging(compression.GzipCompressDecompress(h.referral())))
+	mux.Get("/api/user/tier", logging.WriteLogging(compression.GzipCompressDecompress(h.userTier())))
+	mux.Post("/api/user/vouchers/redeem", logging.WriteLogging(compression.GzipCompressDecompress(h.redeemVoucher())))
+	mux.Post("/api/user/balance/transfer", logging.WriteLogging(compression.GzipCompressDecompress(h.transfer())))
+	mux.Post("/api/user/payment-tokens", logging.WriteLogging(compression.GzipCompressDecompress(h.paymentToken())))
 	mux.Post("/api/user/balance/withdraw", logging.WriteLogging(compression.GzipCompressDecompress(h.withdraw())))
 	mux.Get("/api/user/withdrawals", logging.WriteLogging(compression.GzipCompressDecompress(h.withdrawals())))
+	mux.Post("/api/accrual/callback", logging.WriteLogging(compression.GzipCompressDecompress(h.accrualCallback())))
+	mux.Get("/api/admin/orders/dead", logging.WriteLogging(compression.GzipCompressDecompress(h.deadLetters())))
+	mux.Post("/api/admin/orders/{number}/requeue", logging.WriteLogging(compression.GzipCompressDecompress(h.requeueDeadLetter())))
+	mux.Post("/api/admin/orders/{number}/poll", logging.WriteLogging(compression.GzipCompressDecompress(h.repollOrder())))
+	mux.Post("/api/admin/orders/{number}/override", logging.WriteLogging(compression.GzipCompressDecompress(h.overrideOrder())))
+	mux.Get("/api/admin/users/{login}/balance", logging.WriteLogging(compression.GzipCompressDecompress(h.userBalanceAsOf())))
+	mux.Get("/api/admin/users/{login}/statement", logging.WriteLogging(compression.GzipCompressDecompress(h.userStatement())))
+	mux.Post("/api/admin/accrual/goods", logging.WriteLogging(compression.GzipCompressDecompress(h.createRewardRule())))
+	mux.Get("/api/admin/accrual/goods", logging.WriteLogging(compression.GzipCompressDecompress(h.rewardRules())))
+	mux.Get("/api/admin/withdrawals", logging.WriteLogging(compression.GzipCompressDecompress(h.withdrawalApprovals())))
+	mux.Post("/api/admin/withdrawals/{number}/approve", logging.WriteLogging(compression.GzipCompressDecompress(h.decideWithdrawal(true))))
+	mux.Post("/api/admin/withdrawals/{number}/reject", logging.WriteLogging(compression.GzipCompressDecompress(h.decideWithdrawal(false))))
+	mux.Post("/api/admin/withdrawals/{number}/reverse", logging.WriteLogging(compression.GzipCompressDecompress(h.reverseWithdrawal())))
+	mux.Get("/api/admin/users/{login}/withdrawal-limits", logging.WriteLogging(compression.GzipCompressDecompress(h.userWithdrawalLimits())))
+	mux.Put("/api/admin/users/{login}/withdrawal-limits", logging.WriteLogging(compression.GzipCompressDecompress(h.setUserWithdrawalLimits())))
+	mux.Delete("/api/admin/users/{login}/withdrawal-limits", logging.WriteLogging(compression.GzipCompressDecompress(h.deleteUserWithdrawalLimits())))
+	mux.Get("/api/admin/risk/events", logging.WriteLogging(compression.GzipCompressDecompress(h.riskEvents())))
+	mux.Post("/api/admin/risk/events/{id}/review", logging.WriteLogging(compression.GzipCompressDecompress(h.reviewRiskEvent())))
+	mux.Post("/api/admin/vouchers", logging.WriteLogging(compression.GzipCompressDecompress(h.createVoucherBatch())))
+	mux.Get("/api/admin/vouchers/{code}", logging.WriteLogging(compression.GzipCompressDecompress(h.voucher())))
+	mux.Post("/api/admin/campaigns", logging.WriteLogging(compression.GzipCompressDecompress(h.createCampaign())))
+	mux.Get("/api/admin/campaigns", logging.WriteLogging(compression.GzipCompressDecompress(h.campaigns())))
+	mux.Get("/api/admin/campaigns/{id}", logging.WriteLogging(compression.GzipCompressDecompress(h.campaign())))
+	mux.Put("/api/admin/campaigns/{id}", logging.WriteLogging(compression.GzipCompressDecompress(h.updateCampaign())))
+	mux.Delete("/api/admin/campaigns/{id}", logging.WriteLogging(compression.GzipCompressDecompress(h.deleteCampaign())))
+	mux.Post("/api/merchant/authorizations", logging.WriteLogging(compression.GzipCompressDecompress(h.authorize())))
+	mux.Get("/api/merchant/authorizations/{id}", logging.WriteLogging(compression.GzipCompressDecompress(h.authorization())))
+	mux.Post("/api/merchant/authorizations/{id}/capture", logging.WriteLogging(compression.GzipCompressDecompress(h.captureAuthorization())))
+	mux.Post("/api/merchant/authorizations/{id}/void", logging.WriteLogging(compression.GzipCompressDecompress(h.voidAuthorization())))
+	mux.Post("/api/merchant/authorizations/{id}/refund", logging.WriteLogging(compression.GzipCompressDecompress(h.refundAuthorization())))
+	mux.Post("/api/merchant/withdrawals/{number}/reverse", logging.WriteLogging(compression.GzipCompressDecompress(h.merchantReverseWithdrawal())))
+	mux.Get("/api/health", logging.WriteLogging(compression.GzipCompressDecompress(h.health())))
 
 	return mux
 }
 
-func accrualSystemPoll(ctx context.Context, db *storage.PgDB, srv string) error {
-	cl := resty.New().R()
-	var orders []int64
-	var listParsedOrders []models.Accrual
+// newHandlers builds handlers from config, failover is nil in local
+// accrual mode.
+func newHandlers(ctx context.Context, config config.Config, db storage.Store, seckey string, retry *retry.Retry) (*handlers, *accrual.FailoverClient, error) {
+	h := &handlers{
+		ctx:    ctx,
+		seckey: seckey,
+		db:     db,
+		retry:  retry,
 
-	if err := db.AccrualSystemPoll(ctx, &orders); err != nil {
-		return err
+		accrualMode:     config.AccrualMode,
+		accrualRegister: config.AccrualLocal() || config.AccrualRegisterOrders,
+		webhookSecret:   config.AccrualWebhookSecret,
+		admins:          config.AdminLogins,
+		transferLimits: models.TransferLimits{
+			Max:      config.TransferMax,
+			DailyMax: config.TransferDailyMax,
+		},
 	}
 
-	for _, v := range orders {
-		var parsedOrders models.Accrual
-		resp, err := cl.Get(fmt.Sprintf("%s/api/orders/%d", srv, v))
+	var failover *accrual.FailoverClient
+	if config.AccrualLocal() {
+		rules, err := accrual.LoadRules(config.AccrualRulesFile)
 		if err != nil {
-			slog.Error(err.Error())
-			continue
-		}
-		switch resp.StatusCode() {
-		case http.StatusNoContent:
-			continue
-		case http.StatusTooManyRequests:
-			hdr := resp.Header().Get("Retry-After")
-			if hdr != "" {
-				i, err := strconv.Atoi(hdr)
-				if err != nil {
-					time.Sleep(pollInterval * time.Second)
-				}
-				time.Sleep(time.Duration(i) * time.Second)
+			return nil, nil, err
+		}
+		// rules created through admin API survive restarts
+		if err := loadRewardRules(ctx, db, rules); err != nil {
+			return nil, nil, err
+		}
+		h.rules = rules
+		h.accrual = accrual.NewLocalClient(rules)
+	} else {
+		for i, addr := range config.AccrualSystemAddresses {
+			e := accrual.NewEndpoint(
+				addr,
+				accrual.NewHTTPClient(addr, config.AccrualTimeout),
+				accrual.NewBreaker(config.AccrualBreakerFailures, config.AccrualBreakerTimeout, config.AccrualBreakerSuccesses),
+			)
+			if len(config.AccrualSystemWeights) > 0 {
+				e.Weight = config.AccrualSystemWeights[i]
 			}
-		case http.StatusInternalServerError:
-			slog.Error("unknown error: 500")
-			continue
+			h.endpoints = append(h.endpoints, e)
 		}
-		if err := json.Unmarshal(resp.Body(), &parsedOrders); err != nil {
-			slog.Error(err.Error())
-			continue
+		if len(config.AccrualSystemWeights) > 0 {
+			failover = accrual.NewWeightedFailoverClient(h.endpoints...)
+		} else {
+			failover = accrual.NewFailoverClient(h.endpoints...)
 		}
-		listParsedOrders = append(listParsedOrders, parsedOrders)
+		h.accrual = failover
 	}
-	if err := db.AccrualSystemSave(ctx, listParsedOrders); err != nil {
-		return err
+
+	var err error
+	if h.merchants, err = models.ParseMerchants(config.Merchants); err != nil {
+		return nil, nil, err
 	}
-	return nil
+
+	if config.RiskRules != "" {
+		rules, err := risk.ParseRules(config.RiskRules)
+		if err != nil {
+			return nil, nil, err
+		}
+		h.risk = risk.NewEngine(rules...)
+	}
+
+	if config.Tiers != "" {
+		list, err := models.ParseTiers(config.Tiers)
+		if err != nil {
+			return nil, nil, err
+		}
+		h.tiers = &models.TierPolicy{
+			Tiers:  list,
+			Window: config.TierWindow,
+			Basis:  config.TierBasis,
+		}
+	}
+	return h, failover, nil
 }
 
 func Run(ctx context.Context, config config.Config) {
@@ -423,6 +731,27 @@ func Run(ctx context.Context, config config.Config) {
 	if err != nil {
 		log.Fatal(err)
 	}
+	db.SetPointsExpiry(config.PointsExpiryMonths, config.PointsExpiringSoon)
+	db.SetWithdrawalApproval(config.WithdrawApprovalThreshold)
+	db.SetAuthorizationTTL(config.AuthorizationTTL)
+	db.SetPaymentTokenTTL(config.PaymentTokenTTL)
+	tierLimits, err := models.ParseWithdrawalTierLimits(config.WithdrawTierLimits)
+	if err != nil {
+		log.Fatal(err)
+	}
+	db.SetWithdrawalLimits(models.WithdrawalLimitPolicy{
+		Global: models.WithdrawalLimits{
+			Max:        config.WithdrawMax,
+			DailyMax:   config.WithdrawDailyMax,
+			MonthlyMax: config.WithdrawMonthlyMax,
+		},
+		Tiers: tierLimits,
+	})
+	db.SetReferralPolicy(models.ReferralPolicy{
+		ReferrerBonus: config.ReferrerBonus,
+		RefereeBonus:  config.RefereeBonus,
+		MaxRewards:    config.ReferralMaxRewards,
+	})
 	if err := db.CreateDatabaseScheme(ctx); err != nil {
 		log.Fatal(err)
 	}
@@ -437,12 +766,11 @@ func Run(ctx context.Context, config config.Config) {
 	retry := retry.NewRetry()
 	retry.SetParams(1*time.Second, 30*time.Second, 3)
 
-	h := &handlers{
-		ctx:    ctx,
-		seckey: seckey,
-		db:     db,
-		retry:  retry,
+	h, failover, err := newHandlers(ctx, config, db, seckey, retry)
+	if err != nil {
+		log.Fatal(err)
 	}
+	db.SetTierPolicy(h.tiers)
 
 	srv := http.Server{
 		Addr:    config.ServerAddr,
@@ -464,23 +792,77 @@ func Run(ctx context.Context, config config.Config) {
 	})
 
 	g.Go(func() error {
+		if !config.AccrualLocal() && len(config.AccrualSystemAddresses) == 0 {
+			logging.Slog.Warn("Accrual system address empty, polling disabled")
+			return nil
+		}
 		for {
 			select {
 			case <-ctx.Done():
 				return nil
 			default:
 			}
-			if config.AccrualSystemAddress != "" {
-				if err := accrualSystemPoll(ctx, db, config.AccrualSystemAddress); err != nil {
-					slog.Error(err.Error())
-				}
-			} else {
-				return errors.New("accrual system address empty")
+			if err := accrualDeadLetter(ctx, db, config.StuckMaxAge, config.StuckMaxAttempts); err != nil {
+				slog.Error(err.Error())
+			}
+			if err := accrualSystemPoll(ctx, db, h.accrual, h.accrualRegister); err != nil {
+				slog.Error(err.Error())
 			}
 			time.Sleep(pollInterval * time.Second)
 		}
 	})
 
+	g.Go(func() error {
+		if config.PointsExpiryMonths <= 0 {
+			return nil
+		}
+		for ctx.Err() == nil {
+			if err := pointsExpiry(ctx, db); err != nil {
+				slog.Error(err.Error())
+			}
+			sleepCtx(ctx, expiryInterval*time.Second)
+		}
+		return nil
+	})
+
+	g.Go(func() error {
+		if len(h.merchants) == 0 {
+			return nil
+		}
+		for ctx.Err() == nil {
+			if err := authorizationsExpiry(ctx, db); err != nil {
+				slog.Error(err.Error())
+			}
+			sleepCtx(ctx, authExpiryInterval*time.Second)
+		}
+		return nil
+	})
+
+	g.Go(func() error {
+		if h.tiers == nil {
+			return nil
+		}
+		for ctx.Err() == nil {
+			if err := tiersRecalculate(ctx, db, h.tiers); err != nil {
+				slog.Error(err.Error())
+			}
+			sleepCtx(ctx, tiersInterval*time.Second)
+		}
+		return nil
+	})
+
+	g.Go(func() error {
+		if failover == nil || len(failover.Endpoints()) < 2 {
+			return nil
+		}
+		// probe standby endpoints so failover does not go to a dead one
+		for ctx.Err() == nil {
+			failover.Check(ctx, config.AccrualTimeout)
+			sleepCtx(ctx, healthInterval*time.Second)
+		}
+		return nil
+	})
+
 	if err := g.Wait(); err != nil {
 		if errors.Is(err, http.ErrServerClosed) {
 			logging.Slog.Info("Server successful shutdown")
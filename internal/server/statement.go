@@ -0,0 +1,223 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sourcecd/gofermart/internal/auth"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+const (
+	statementCSV  = "csv"
+	statementJSON = "json"
+)
+
+// parseDate accepts a plain date or RFC3339 time, dateOnly reports the
+// plain date form.
+func parseDate(v string) (t time.Time, dateOnly bool, err error) {
+	if date, err := time.Parse(time.DateOnly, v); err == nil {
+		return date, true, nil
+	}
+	t, err = time.Parse(time.RFC3339, v)
+	return t, false, err
+}
+
+// periodParams reads statement period, from is inclusive and to is
+// exclusive. Plain date to covers the whole day, so from=2024-06-01 and
+// to=2024-06-30 is the June statement.
+func periodParams(r *http.Request) (models.Period, error) {
+	var period models.Period
+	from, _, err := parseDate(r.URL.Query().Get("from"))
+	if err != nil {
+		return period, errors.New("wrong from date")
+	}
+	to, dateOnly, err := parseDate(r.URL.Query().Get("to"))
+	if err != nil {
+		return period, errors.New("wrong to date")
+	}
+	if dateOnly {
+		to = to.AddDate(0, 0, 1)
+	}
+	if !to.After(from) {
+		return period, errors.New("to date must be after from date")
+	}
+	period.From = from
+	period.To = to
+	return period, nil
+}
+
+// statementWriter encodes statement lines to the response one by one.
+type statementWriter interface {
+	line(line *models.BalanceEntry) error
+	close() error
+}
+
+type csvStatement struct {
+	w *csv.Writer
+}
+
+func newCSVStatement(w io.Writer) (*csvStatement, error) {
+	s := &csvStatement{w: csv.NewWriter(w)}
+	return s, s.w.Write([]string{"date", "kind", "order", "counterparty", "amount", "balance"})
+}
+
+func (s *csvStatement) line(line *models.BalanceEntry) error {
+	return s.w.Write([]string{
+		line.CreatedAt,
+		line.Kind,
+		line.Order,
+		line.Counterparty,
+		strconv.FormatFloat(line.Amount, 'f', -1, 64),
+		strconv.FormatFloat(line.Balance, 'f', -1, 64),
+	})
+}
+
+func (s *csvStatement) close() error {
+	s.w.Flush()
+	return s.w.Error()
+}
+
+// jsonStatement writes {"opening": ..., "entries": [...], "closing": ...}
+// without holding entries.
+type jsonStatement struct {
+	w       io.Writer
+	entries int
+}
+
+func (s *jsonStatement) line(line *models.BalanceEntry) error {
+	b, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	switch {
+	case line.Kind == models.LedgerOpening:
+		_, err = fmt.Fprintf(s.w, "{\n  \"opening\": %s,\n  \"entries\": [", b)
+	case line.Kind == models.LedgerClosing:
+		_, err = fmt.Fprintf(s.w, "\n  ],\n  \"closing\": %s\n}\n", b)
+	case s.entries == 0:
+		_, err = fmt.Fprintf(s.w, "\n    %s", b)
+	default:
+		_, err = fmt.Fprintf(s.w, ",\n    %s", b)
+	}
+	if line.Kind != models.LedgerOpening && line.Kind != models.LedgerClosing {
+		s.entries++
+	}
+	return err
+}
+
+func (s *jsonStatement) close() error {
+	return nil
+}
+
+// writeStatement streams user statement in the requested format, errors
+// after the first line can only be logged.
+func (h *handlers) writeStatement(ctx context.Context, w http.ResponseWriter, r *http.Request, userid int64) {
+	period, err := periodParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = statementJSON
+	}
+	if format != statementCSV && format != statementJSON {
+		http.Error(w, "wrong format, csv or json expected", http.StatusBadRequest)
+		return
+	}
+
+	var (
+		sw      statementWriter
+		started bool
+	)
+	line := func(line *models.BalanceEntry) error {
+		if !started {
+			started = true
+			filename := fmt.Sprintf("statement_%s_%s.%s", period.From.Format(time.DateOnly), period.To.Format(time.DateOnly), format)
+			w.Header().Set("Content-Disposition", "attachment; filename="+filename)
+			if format == statementCSV {
+				w.Header().Set("Content-Type", "text/csv")
+				w.WriteHeader(http.StatusOK)
+				cs, err := newCSVStatement(w)
+				if err != nil {
+					return err
+				}
+				sw = cs
+			} else {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				sw = &jsonStatement{w: w}
+			}
+		}
+		return sw.line(line)
+	}
+
+	// streamed response can not be retried
+	err = h.db.Statement(ctx, userid, period, line)
+	if err == nil && started {
+		err = sw.close()
+	}
+	if err != nil {
+		if !started {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		slog.Error("statement export failed", slog.Int64("userid", userid), slog.String("error", err.Error()))
+	}
+}
+
+func (h *handlers) statement() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		gettoken, err := checkRequestCreds(r)
+		if err != nil {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		userid, err := auth.ParseJWT(gettoken, h.seckey)
+		if err != nil {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(h.ctx, h.retry.GetTimeoutCtx())
+		defer cancel()
+		h.writeStatement(ctx, w, r, userid)
+	}
+}
+
+// userStatement is statement of any user by login, for support.
+func (h *handlers) userStatement() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(h.ctx, h.retry.GetTimeoutCtx())
+		defer cancel()
+
+		if _, err := h.checkAdmin(ctx, r); err != nil {
+			adminError(w, err)
+			return
+		}
+		var userid int64
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) (err error) {
+			userid, err = h.db.GetUserID(ctx, chi.URLParam(r, "login"))
+			return err
+		}); err != nil {
+			if errors.Is(err, prjerrors.ErrNotExists) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		h.writeStatement(ctx, w, r, userid)
+	}
+}
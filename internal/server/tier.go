@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/sourcecd/gofermart/internal/auth"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/storage"
+)
+
+func (h *handlers) userTier() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		gettoken, err := checkRequestCreds(r)
+		if err != nil {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		userid, err := auth.ParseJWT(gettoken, h.seckey)
+		if err != nil {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if h.tiers == nil {
+			http.Error(w, "loyalty tiers disabled", http.StatusNotFound)
+			return
+		}
+
+		var tier models.UserTier
+		if err := h.retry.TierFuncRetry(h.db.GetUserTier)(h.ctx, userid, h.tiers, &tier); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, tier)
+	}
+}
+
+// tiersRecalculate moves users between tiers by their rolling totals.
+func tiersRecalculate(ctx context.Context, db storage.Store, policy *models.TierPolicy) error {
+	count, err := db.RecalculateTiers(ctx, policy)
+	if err != nil {
+		return err
+	}
+	slog.Info("loyalty tiers recalculated", slog.Int64("users", count))
+	return nil
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/retry"
+	"github.com/sourcecd/gofermart/internal/storage/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserTier(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	policy := &models.TierPolicy{
+		Tiers: []models.Tier{
+			{Name: "silver", Threshold: 1000, Multiplier: 1.1},
+			{Name: "gold", Threshold: 5000, Multiplier: 1.25},
+		},
+		Basis: models.TierBasisAccrued,
+	}
+	h := &handlers{
+		ctx:    context.Background(),
+		seckey: seckey,
+		db:     db,
+		retry:  retry.NewRetry(),
+	}
+
+	// tiers disabled
+	w := httptest.NewRecorder()
+	h.userTier()(w, withUserToken(t, httptest.NewRequest(http.MethodGet, "/", nil), userID))
+	res := w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	h.tiers = policy
+	var tierPtr *models.UserTier
+	db.EXPECT().GetUserTier(gomock.Any(), userID, policy, gomock.AssignableToTypeOf(tierPtr)).DoAndReturn(
+		func(ctx context.Context, userid int64, policy *models.TierPolicy, tier *models.UserTier) error {
+			*tier = models.UserTier{
+				Tier:          "silver",
+				Multiplier:    1.1,
+				Basis:         policy.Basis,
+				Total:         1500,
+				NextTier:      "gold",
+				NextThreshold: 5000,
+				Remaining:     3500,
+			}
+			return nil
+		})
+
+	w = httptest.NewRecorder()
+	h.userTier()(w, withUserToken(t, httptest.NewRequest(http.MethodGet, "/", nil), userID))
+	res = w.Result()
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.JSONEq(t, `{"tier": "silver", "multiplier": 1.1, "basis": "accrued", "total": 1500,
+		"next_tier": "gold", "next_threshold": 5000, "remaining": 3500}`, string(b))
+
+	w = httptest.NewRecorder()
+	h.userTier()(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	res = w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+}
+
+func TestTiersRecalculate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	policy := &models.TierPolicy{Tiers: []models.Tier{{Name: "silver", Threshold: 1000, Multiplier: 1.1}}}
+	db.EXPECT().RecalculateTiers(gomock.Any(), policy).Return(int64(3), nil)
+	require.NoError(t, tiersRecalculate(context.Background(), db, policy))
+
+	db.EXPECT().RecalculateTiers(gomock.Any(), policy).Return(int64(0), errors.New("db down"))
+	require.Error(t, tiersRecalculate(context.Background(), db, policy))
+}
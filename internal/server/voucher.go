@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sourcecd/gofermart/internal/auth"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+// createVoucherBatch generates voucher codes, body is {"count": 100,
+// "points": 500, "max_redemptions": 1, "expires_at": "..."}, codes are
+// given only in this response.
+func (h *handlers) createVoucherBatch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := checkContentType(r, "application/json"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		author, err := h.checkAdmin(h.ctx, r)
+		if err != nil {
+			adminError(w, err)
+			return
+		}
+
+		var body struct {
+			Count          int        `json:"count"`
+			Points         float64    `json:"points"`
+			MaxRedemptions int        `json:"max_redemptions"`
+			ExpiresAt      *time.Time `json:"expires_at"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, prjerrors.ErrReqJSONParse.Error(), http.StatusBadRequest)
+			return
+		}
+		batch := &models.VoucherBatch{
+			Count:          body.Count,
+			Points:         body.Points,
+			MaxRedemptions: body.MaxRedemptions,
+			ExpiresAt:      body.ExpiresAt,
+			Author:         author,
+		}
+		if err := batch.Validate(time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error { return h.db.CreateVoucherBatch(ctx, batch) }); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusCreated, batch)
+	}
+}
+
+func (h *handlers) voucher() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, err := h.checkAdmin(h.ctx, r); err != nil {
+			adminError(w, err)
+			return
+		}
+
+		var voucher models.Voucher
+		if err := h.retry.Do(h.ctx, func(ctx context.Context) error {
+			return h.db.GetVoucher(ctx, models.NormalizeVoucherCode(chi.URLParam(r, "code")), &voucher)
+		}); err != nil {
+			if errors.Is(err, prjerrors.ErrVoucherNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, &voucher)
+	}
+}
+
+// redeemVoucher credits voucher points to the user, body is
+// {"code": "..."}.
+func (h *handlers) redeemVoucher() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := checkContentType(r, "application/json"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		gettoken, err := checkRequestCreds(r)
+		if err != nil {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		userid, err := auth.ParseJWT(gettoken, h.seckey)
+		if err != nil {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var redemption models.VoucherRedemption
+		if err := json.NewDecoder(r.Body).Decode(&redemption); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		redemption = models.VoucherRedemption{Code: models.NormalizeVoucherCode(redemption.Code)}
+		if redemption.Code == "" {
+			http.Error(w, "empty voucher code", http.StatusUnprocessableEntity)
+			return
+		}
+
+		if err := h.retry.VoucherFuncRetry(h.db.RedeemVoucher)(h.ctx, userid, &redemption); err != nil {
+			switch {
+			case errors.Is(err, prjerrors.ErrVoucherNotFound):
+				http.Error(w, err.Error(), http.StatusNotFound)
+			case errors.Is(err, prjerrors.ErrVoucherRedeemed):
+				http.Error(w, err.Error(), http.StatusConflict)
+			case errors.Is(err, prjerrors.ErrVoucherExpired):
+				http.Error(w, err.Error(), http.StatusGone)
+			default:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		writeJSON(w, http.StatusOK, &redemption)
+	}
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+	"github.com/sourcecd/gofermart/internal/retry"
+	"github.com/sourcecd/gofermart/internal/storage/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateVoucherBatch(t *testing.T) {
+	testCases := []struct {
+		name    string
+		body    string
+		callDB  bool
+		expCode int
+	}{
+		{name: "ok", body: `{"count": 2, "points": 500, "id": 42, "codes": ["MINE"]}`, callDB: true, expCode: http.StatusCreated},
+		{name: "no_points", body: `{"count": 2}`, expCode: http.StatusBadRequest},
+		{name: "expired", body: `{"count": 2, "points": 500, "expires_at": "2020-01-01T00:00:00Z"}`, expCode: http.StatusBadRequest},
+		{name: "bad_json", body: `{"count": `, expCode: http.StatusBadRequest},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db := mock.NewMockStore(ctrl)
+
+			h := &handlers{
+				ctx:    context.Background(),
+				seckey: seckey,
+				db:     db,
+				retry:  retry.NewRetry(),
+				admins: []string{adminLogin},
+			}
+
+			db.EXPECT().GetUserLogin(gomock.Any(), userID).Return(adminLogin, nil)
+			if v.callDB {
+				var batchPtr *models.VoucherBatch
+				db.EXPECT().CreateVoucherBatch(gomock.Any(), gomock.AssignableToTypeOf(batchPtr)).DoAndReturn(
+					func(ctx context.Context, batch *models.VoucherBatch) error {
+						// id and codes can not be set by the client
+						assert.Zero(t, batch.ID)
+						assert.Empty(t, batch.Codes)
+						assert.Equal(t, 1, batch.MaxRedemptions)
+						assert.Equal(t, adminLogin, batch.Author)
+						batch.ID = 1
+						batch.Codes = []string{"AAAAAAAAAAAAAAAA", "BBBBBBBBBBBBBBBB"}
+						batch.CreatedAt = "2024-07-18T10:00:00Z"
+						return nil
+					})
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(v.body))
+			r.Header.Add("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			h.createVoucherBatch()(w, withUserToken(t, r, userID))
+
+			res := w.Result()
+			b, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			assert.Equal(t, v.expCode, res.StatusCode)
+			if v.expCode == http.StatusCreated {
+				assert.JSONEq(t, `{"id": 1, "count": 2, "points": 500, "max_redemptions": 1, "author": "admin",
+					"created_at": "2024-07-18T10:00:00Z", "codes": ["AAAAAAAAAAAAAAAA", "BBBBBBBBBBBBBBBB"]}`, string(b))
+			}
+		})
+	}
+}
+
+func TestVoucher(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := mock.NewMockStore(ctrl)
+
+	h := &handlers{
+		ctx:    context.Background(),
+		seckey: seckey,
+		db:     db,
+		retry:  retry.NewRetry(),
+		admins: []string{adminLogin},
+	}
+
+	db.EXPECT().GetUserLogin(gomock.Any(), userID).Return(adminLogin, nil).Times(2)
+	var voucherPtr *models.Voucher
+	db.EXPECT().GetVoucher(gomock.Any(), "AAAAAAAAAAAAAAAA", gomock.AssignableToTypeOf(voucherPtr)).DoAndReturn(
+		func(ctx context.Context, code string, voucher *models.Voucher) error {
+			*voucher = models.Voucher{Code: code, BatchID: 1, Points: 500, MaxRedemptions: 10, Redeemed: 3,
+				CreatedAt: "2024-07-18T10:00:00Z"}
+			return nil
+		})
+	db.EXPECT().GetVoucher(gomock.Any(), "CCCCCCCCCCCCCCCC", gomock.Any()).Return(prjerrors.ErrVoucherNotFound)
+
+	w := httptest.NewRecorder()
+	h.voucher()(w, withURLParams(withUserToken(t, httptest.NewRequest(http.MethodGet, "/", nil), userID),
+		map[string]string{"code": "aaaa-aaaa-aaaa-aaaa"}))
+	res := w.Result()
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.JSONEq(t, `{"code": "AAAAAAAAAAAAAAAA", "batch_id": 1, "points": 500, "max_redemptions": 10,
+		"redeemed": 3, "created_at": "2024-07-18T10:00:00Z"}`, string(b))
+
+	w = httptest.NewRecorder()
+	h.voucher()(w, withURLParams(withUserToken(t, httptest.NewRequest(http.MethodGet, "/", nil), userID),
+		map[string]string{"code": "CCCCCCCCCCCCCCCC"}))
+	res = w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestRedeemVoucher(t *testing.T) {
+	testCases := []struct {
+		name    string
+		body    string
+		dbErr   error
+		callDB  bool
+		expCode int
+	}{
+		{name: "ok", body: `{"code": "aaaa-aaaa-aaaa-aaaa", "points": 100000}`, callDB: true, expCode: http.StatusOK},
+		{name: "empty", body: `{"code": " "}`, expCode: http.StatusUnprocessableEntity},
+		{name: "not_found", body: `{"code": "AAAAAAAAAAAAAAAA"}`, callDB: true, dbErr: prjerrors.ErrVoucherNotFound, expCode: http.StatusNotFound},
+		{name: "redeemed", body: `{"code": "AAAAAAAAAAAAAAAA"}`, callDB: true, dbErr: prjerrors.ErrVoucherRedeemed, expCode: http.StatusConflict},
+		{name: "expired", body: `{"code": "AAAAAAAAAAAAAAAA"}`, callDB: true, dbErr: prjerrors.ErrVoucherExpired, expCode: http.StatusGone},
+	}
+
+	for _, v := range testCases {
+		t.Run(v.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			db := mock.NewMockStore(ctrl)
+
+			h := &handlers{
+				ctx:    context.Background(),
+				seckey: seckey,
+				db:     db,
+				retry:  retry.NewRetry(),
+			}
+
+			if v.callDB {
+				var redemptionPtr *models.VoucherRedemption
+				// voucher errors are not retried
+				db.EXPECT().RedeemVoucher(gomock.Any(), userID, gomock.AssignableToTypeOf(redemptionPtr)).DoAndReturn(
+					func(ctx context.Context, userid int64, redemption *models.VoucherRedemption) error {
+						// points can not be set by the client
+						assert.Equal(t, models.VoucherRedemption{Code: "AAAAAAAAAAAAAAAA"}, *redemption)
+						redemption.Points = 500
+						redemption.Current = 750
+						return v.dbErr
+					}).Times(1)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(v.body))
+			r.Header.Add("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			h.redeemVoucher()(w, withUserToken(t, r, userID))
+
+			res := w.Result()
+			b, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			assert.Equal(t, v.expCode, res.StatusCode)
+			if v.expCode == http.StatusOK {
+				assert.JSONEq(t, `{"code": "AAAAAAAAAAAAAAAA", "points": 500, "current": 750}`, string(b))
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+const (
+	withdrawHold = "UPDATE balance SET current=(current - $1), held=(held + $1) WHERE userid=$2 RETURNING current"
+	addApproval  = "INSERT INTO withdrawal_approvals (number, userid, sum, status, requested_at) VALUES ($1, $2, $3, $4, $5)"
+
+	listApprovals = "SELECT withdrawal_approvals.number, users.login, withdrawal_approvals.sum, withdrawal_approvals.status, " +
+		"withdrawal_approvals.requested_at, COALESCE(withdrawal_approvals.decided_by, ''), withdrawal_approvals.decided_at, " +
+		"COALESCE(withdrawal_approvals.reason, '') FROM withdrawal_approvals JOIN users ON users.id=withdrawal_approvals.userid " +
+		"WHERE withdrawal_approvals.status=$1 ORDER BY withdrawal_approvals.requested_at"
+	pendingApproval = "SELECT userid, sum, status FROM withdrawal_approvals WHERE number=$1 FOR UPDATE"
+	decideApproval  = "UPDATE withdrawal_approvals SET status=$1, decided_by=$2, decided_at=$3, reason=NULLIF($4, '') WHERE number=$5"
+	approveHold     = "UPDATE balance SET held=(held - $1), withdrawn=(withdrawn + $1) WHERE userid=$2"
+	releaseHold     = "UPDATE balance SET held=(held - $1) WHERE userid=$2"
+)
+
+// SetWithdrawalApproval sets sum above which withdrawals need an admin
+// approval, zero disables approvals.
+func (pg *PgDB) SetWithdrawalApproval(threshold float64) {
+	pg.approvalThreshold = threshold
+}
+
+func (pg *PgDB) ListWithdrawalApprovals(ctx context.Context, status string, approvals *[]models.WithdrawalApproval) error {
+	rows, err := pg.db.QueryContext(ctx, listApprovals, status)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			approval    models.WithdrawalApproval
+			number      int64
+			requestedAt time.Time
+			decidedAt   sql.NullTime
+		)
+		if err := rows.Scan(&number, &approval.Login, &approval.Sum, &approval.Status,
+			&requestedAt, &approval.DecidedBy, &decidedAt, &approval.Reason); err != nil {
+			return err
+		}
+		approval.Order = fmt.Sprint(number)
+		approval.RequestedAt = requestedAt.Format(time.RFC3339)
+		if decidedAt.Valid {
+			approval.DecidedAt = decidedAt.Time.Format(time.RFC3339)
+		}
+		*approvals = append(*approvals, approval)
+	}
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+	if len(*approvals) == 0 {
+		return prjerrors.ErrEmptyData
+	}
+	return nil
+}
+
+// DecideWithdrawal approves pending withdrawal moving the hold to withdrawn
+// or rejects it returning the sum to current and to the original lots.
+func (pg *PgDB) DecideWithdrawal(ctx context.Context, decision *models.WithdrawalDecision) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var (
+		userid int64
+		sum    float64
+		status string
+	)
+	if err := tx.QueryRowContext(ctx, pendingApproval, decision.Number).Scan(&userid, &sum, &status); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return prjerrors.ErrOrderNotFound
+		}
+		return err
+	}
+	if status != models.WithdrawalPendingApproval {
+		return prjerrors.ErrWithdrawalNotPending
+	}
+
+	status = models.WithdrawalApproved
+	if decision.Approve {
+		if _, err := tx.ExecContext(ctx, approveHold, sum, userid); err != nil {
+			return err
+		}
+	} else {
+		status = models.WithdrawalRejected
+		if _, err := tx.ExecContext(ctx, releaseHold, sum, userid); err != nil {
+			return err
+		}
+		if err := balanceMove(ctx, tx, userid, models.LedgerRelease, decision.Number, sum, "",
+			lotSpend{spendWithdrawal, decision.Number}); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, decideApproval, status, decision.Author, time.Now(), decision.Reason, decision.Number); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
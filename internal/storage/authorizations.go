@@ -0,0 +1,376 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sourcecd/gofermart/internal/crypto"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+const (
+	addAuthorization = "INSERT INTO merchant_authorizations (merchant, userid, amount, status, created_at, expires_at, updated_at) " +
+		"VALUES ($1, $2, $3, $4, $5, $6, $5) RETURNING id"
+	getAuthorization = "SELECT merchant_authorizations.id, users.login, merchant_authorizations.amount, merchant_authorizations.captured, " +
+		"merchant_authorizations.refunded, COALESCE(merchant_authorizations.number, 0), merchant_authorizations.status, " +
+		"merchant_authorizations.created_at, merchant_authorizations.expires_at FROM merchant_authorizations " +
+		"JOIN users ON users.id=merchant_authorizations.userid WHERE (merchant_authorizations.id=$1 AND merchant_authorizations.merchant=$2)"
+	lockAuthorization = "SELECT userid, amount, captured, refunded, COALESCE(number, 0), status, expires_at FROM merchant_authorizations " +
+		"WHERE (id=$1 AND merchant=$2) FOR UPDATE"
+	captureAuthorization = "UPDATE merchant_authorizations SET captured=$1, number=$2, status=$3, updated_at=$4 WHERE id=$5"
+	setAuthorization     = "UPDATE merchant_authorizations SET status=$1, updated_at=$2 WHERE id=$3"
+	refundAuthorization  = "UPDATE merchant_authorizations SET refunded=$1, status=$2, updated_at=$3 WHERE id=$4"
+	expiredAuthorization = "SELECT id, merchant FROM merchant_authorizations WHERE (status=$1 AND expires_at <= $2)"
+
+	captureHold = "UPDATE balance SET held=(held - $1), withdrawn=(withdrawn + $2) WHERE userid=$3"
+
+	addPaymentToken = "INSERT INTO payment_tokens (token_hash, userid, merchant, max_amount, created_at, expires_at) " +
+		"VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, 0), $5, $6)"
+	lockPaymentToken = "SELECT payment_tokens.id, payment_tokens.userid, users.login, COALESCE(payment_tokens.merchant, ''), " +
+		"COALESCE(payment_tokens.max_amount, 0), payment_tokens.expires_at, payment_tokens.used_at IS NOT NULL FROM payment_tokens " +
+		"JOIN users ON users.id=payment_tokens.userid WHERE payment_tokens.token_hash=$1 FOR UPDATE OF payment_tokens"
+	usePaymentToken = "UPDATE payment_tokens SET used_at=$1, authorization_id=$2 WHERE id=$3"
+)
+
+// authorizationLock is merchant authorization row locked for an operation.
+type authorizationLock struct {
+	userid    int64
+	amount    float64
+	captured  float64
+	refunded  float64
+	number    int64
+	status    string
+	expiresAt time.Time
+}
+
+// SetAuthorizationTTL sets how long merchant authorizations hold points.
+func (pg *PgDB) SetAuthorizationTTL(ttl time.Duration) {
+	pg.authorizationTTL = ttl
+}
+
+// SetPaymentTokenTTL sets how long user payment tokens are accepted.
+func (pg *PgDB) SetPaymentTokenTTL(ttl time.Duration) {
+	pg.paymentTokenTTL = ttl
+}
+
+// CreatePaymentToken issues single use token the user gives to merchant,
+// only token hash is stored.
+func (pg *PgDB) CreatePaymentToken(ctx context.Context, userid int64, token *models.PaymentToken) error {
+	code, err := crypto.GenerateRandomKey()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	expiresAt := now.Add(pg.paymentTokenTTL)
+	if _, err := pg.db.ExecContext(ctx, addPaymentToken, crypto.GeneratePasswordHash(code), userid,
+		token.Merchant, token.MaxAmount, now, expiresAt); err != nil {
+		return err
+	}
+	token.Token = code
+	token.ExpiresAt = expiresAt.Format(time.RFC3339)
+	return nil
+}
+
+// Authorize holds amount of user points for merchant, points leave current
+// but stay out of withdrawn until capture. Payment token of the user is
+// spent by the authorization.
+func (pg *PgDB) Authorize(ctx context.Context, auth *models.Authorization) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var (
+		tokenID   int64
+		userid    int64
+		merchant  string
+		maxAmount float64
+		tokenTill time.Time
+		used      bool
+	)
+	if err := tx.QueryRowContext(ctx, lockPaymentToken, crypto.GeneratePasswordHash(auth.Token)).Scan(&tokenID, &userid,
+		&auth.Login, &merchant, &maxAmount, &tokenTill, &used); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return prjerrors.ErrPaymentToken
+		}
+		return err
+	}
+	if used || !tokenTill.After(time.Now()) || (merchant != "" && merchant != auth.Merchant) ||
+		(maxAmount > 0 && auth.Amount > maxAmount) {
+		return prjerrors.ErrPaymentToken
+	}
+	if err := pg.checkWithdrawalLimits(ctx, tx, userid, auth.Amount, 0); err != nil {
+		return err
+	}
+	var current float64
+	if err := tx.QueryRowContext(ctx, withdrawHold, auth.Amount, userid).Scan(&current); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.Is(err, sql.ErrNoRows) || errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
+			return prjerrors.ErrNotEnough
+		}
+		return err
+	}
+	now := time.Now()
+	expiresAt := now.Add(pg.authorizationTTL)
+	if err := tx.QueryRowContext(ctx, addAuthorization, auth.Merchant, userid, auth.Amount,
+		models.AuthorizationAuthorized, now, expiresAt).Scan(&auth.ID); err != nil {
+		return err
+	}
+	if _, err := spendLots(ctx, tx, userid, auth.Amount, lotSpend{spendAuthorization, auth.ID}); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, usePaymentToken, now, auth.ID, tokenID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, addLedger, userid, models.LedgerAuthorization, 0, -auth.Amount, current, now, auth.Merchant); err != nil {
+		return err
+	}
+	auth.Token = ""
+	auth.Status = models.AuthorizationAuthorized
+	auth.CreatedAt = now.Format(time.RFC3339)
+	auth.ExpiresAt = expiresAt.Format(time.RFC3339)
+	return tx.Commit()
+}
+
+func scanAuthorization(row rowScanner, auth *models.Authorization) error {
+	var (
+		number    int64
+		createdAt time.Time
+		expiresAt time.Time
+	)
+	if err := row.Scan(&auth.ID, &auth.Login, &auth.Amount, &auth.Captured, &auth.Refunded,
+		&number, &auth.Status, &createdAt, &expiresAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return prjerrors.ErrAuthorizationNotFound
+		}
+		return err
+	}
+	auth.Order = ""
+	if number != 0 {
+		auth.Order = fmt.Sprint(number)
+	}
+	auth.CreatedAt = createdAt.Format(time.RFC3339)
+	auth.ExpiresAt = expiresAt.Format(time.RFC3339)
+	return nil
+}
+
+// GetAuthorization gives authorization of the merchant only.
+func (pg *PgDB) GetAuthorization(ctx context.Context, merchant string, id int64, auth *models.Authorization) error {
+	return scanAuthorization(pg.db.QueryRowContext(ctx, getAuthorization, id, merchant), auth)
+}
+
+func lockMerchantAuthorization(ctx context.Context, tx *sql.Tx, merchant string, id int64) (*authorizationLock, error) {
+	var lock authorizationLock
+	if err := tx.QueryRowContext(ctx, lockAuthorization, id, merchant).Scan(&lock.userid, &lock.amount,
+		&lock.captured, &lock.refunded, &lock.number, &lock.status, &lock.expiresAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, prjerrors.ErrAuthorizationNotFound
+		}
+		return nil, err
+	}
+	return &lock, nil
+}
+
+// releaseAuthorization returns held amount to current and to the lots it
+// was taken from.
+func releaseAuthorization(ctx context.Context, tx *sql.Tx, id, userid int64, amount float64, merchant string) error {
+	if _, err := tx.ExecContext(ctx, releaseHold, amount, userid); err != nil {
+		return err
+	}
+	return balanceMove(ctx, tx, userid, models.LedgerAuthRelease, 0, amount, merchant, lotSpend{spendAuthorization, id})
+}
+
+// CaptureAuthorization withdraws op amount with op order number, the rest
+// of the hold goes back to current.
+func (pg *PgDB) CaptureAuthorization(ctx context.Context, op *models.AuthorizationOp, auth *models.Authorization) error {
+	num, err := strconv.ParseInt(op.Order, 10, 64)
+	if err != nil {
+		return err
+	}
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	lock, err := lockMerchantAuthorization(ctx, tx, op.Merchant, op.ID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if lock.status != models.AuthorizationAuthorized || !lock.expiresAt.After(now) {
+		return prjerrors.ErrAuthorizationState
+	}
+	if op.Amount > lock.amount {
+		return prjerrors.ErrAuthorizationAmount
+	}
+	// limits may have changed since authorization
+	if err := pg.checkWithdrawalLimits(ctx, tx, lock.userid, op.Amount, op.ID); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, createOrderRecWithdraw, lock.userid, num, op.Amount, now, false); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
+			return prjerrors.ErrOrderAlreadyExists
+		}
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, captureHold, lock.amount, op.Amount, lock.userid); err != nil {
+		return err
+	}
+	held := lotSpend{spendAuthorization, op.ID}
+	if rest := math.Round((lock.amount-op.Amount)*100) / 100; rest > 0 {
+		if err := balanceMove(ctx, tx, lock.userid, models.LedgerAuthRelease, num, rest, op.Merchant, held); err != nil {
+			return err
+		}
+	}
+	// captured lots are refunded as the withdrawal
+	if err := retagLots(ctx, tx, held, lotSpend{spendWithdrawal, num}); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, captureAuthorization, op.Amount, num, models.AuthorizationCaptured, now, op.ID); err != nil {
+		return err
+	}
+	if err := scanAuthorization(tx.QueryRowContext(ctx, getAuthorization, op.ID, op.Merchant), auth); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// VoidAuthorization cancels not captured authorization and returns the
+// whole hold to current.
+func (pg *PgDB) VoidAuthorization(ctx context.Context, op *models.AuthorizationOp, auth *models.Authorization) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	lock, err := lockMerchantAuthorization(ctx, tx, op.Merchant, op.ID)
+	if err != nil {
+		return err
+	}
+	if lock.status != models.AuthorizationAuthorized {
+		return prjerrors.ErrAuthorizationState
+	}
+	if err := releaseAuthorization(ctx, tx, op.ID, lock.userid, lock.amount, op.Merchant); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, setAuthorization, models.AuthorizationVoided, time.Now(), op.ID); err != nil {
+		return err
+	}
+	if err := scanAuthorization(tx.QueryRowContext(ctx, getAuthorization, op.ID, op.Merchant), auth); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RefundAuthorization reverses op amount of the captured withdrawal,
+// refunds may be partial until all is returned.
+func (pg *PgDB) RefundAuthorization(ctx context.Context, op *models.AuthorizationOp, auth *models.Authorization) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	lock, err := lockMerchantAuthorization(ctx, tx, op.Merchant, op.ID)
+	if err != nil {
+		return err
+	}
+	if lock.status != models.AuthorizationCaptured {
+		return prjerrors.ErrAuthorizationState
+	}
+	if math.Round((lock.refunded+op.Amount)*100)/100 > lock.captured {
+		return prjerrors.ErrAuthorizationAmount
+	}
+
+	reversal := &models.Reversal{Amount: op.Amount, Merchant: op.Merchant}
+	if err := reverseWithdrawal(ctx, tx, lock.number, reversal); err != nil {
+		return err
+	}
+	status := models.AuthorizationCaptured
+	if reversal.Remaining == 0 {
+		status = models.AuthorizationRefunded
+	}
+	if _, err := tx.ExecContext(ctx, refundAuthorization, reversal.Reversed, status, time.Now(), op.ID); err != nil {
+		return err
+	}
+	if err := scanAuthorization(tx.QueryRowContext(ctx, getAuthorization, op.ID, op.Merchant), auth); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ExpireAuthorizations releases holds of authorizations not captured in
+// time, returns number of authorizations expired.
+func (pg *PgDB) ExpireAuthorizations(ctx context.Context) (int64, error) {
+	type expired struct {
+		id       int64
+		merchant string
+	}
+	var list []expired
+	rows, err := pg.db.QueryContext(ctx, expiredAuthorization, models.AuthorizationAuthorized, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var e expired
+		if err := rows.Scan(&e.id, &e.merchant); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		list = append(list, e)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return 0, rows.Err()
+	}
+
+	var count int64
+	for _, e := range list {
+		ok, err := pg.expireAuthorization(ctx, e.merchant, e.id)
+		if err != nil {
+			return count, err
+		}
+		if ok {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (pg *PgDB) expireAuthorization(ctx context.Context, merchant string, id int64) (bool, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	lock, err := lockMerchantAuthorization(ctx, tx, merchant, id)
+	if err != nil {
+		return false, err
+	}
+	// captured or voided meanwhile
+	now := time.Now()
+	if lock.status != models.AuthorizationAuthorized || lock.expiresAt.After(now) {
+		return false, nil
+	}
+	if err := releaseAuthorization(ctx, tx, id, lock.userid, lock.amount, merchant); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, setAuthorization, models.AuthorizationExpired, now, id); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+const (
+	campaignColumns = "id, name, starts_at, ends_at, first_order, tier, min_accrual, bonus_type, bonus, author, created_at"
+
+	createCampaign = "INSERT INTO campaigns (name, starts_at, ends_at, first_order, tier, min_accrual, bonus_type, bonus, author, created_at) " +
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id"
+	listCampaigns  = "SELECT " + campaignColumns + " FROM campaigns WHERE deleted_at IS NULL ORDER BY starts_at, id"
+	getCampaign    = "SELECT " + campaignColumns + " FROM campaigns WHERE (id=$1 AND deleted_at IS NULL)"
+	updateCampaign = "UPDATE campaigns SET name=$1, starts_at=$2, ends_at=$3, first_order=$4, tier=$5, min_accrual=$6, bonus_type=$7, bonus=$8 " +
+		"WHERE (id=$9 AND deleted_at IS NULL)"
+	deleteCampaign = "UPDATE campaigns SET deleted_at=$1 WHERE (id=$2 AND deleted_at IS NULL)"
+
+	activeCampaigns  = "SELECT " + campaignColumns + " FROM campaigns WHERE (deleted_at IS NULL AND starts_at <= $1 AND ends_at > $1) ORDER BY id"
+	userTierName     = "SELECT tier FROM user_tiers WHERE userid=$1"
+	otherProcessed   = "SELECT EXISTS (SELECT 1 FROM orders WHERE (userid=$1 AND processable=true AND status=$2 AND number <> $3))"
+	addCampaignBonus = "INSERT INTO campaign_bonuses (campaign_id, number, userid, amount, created_at) VALUES ($1, $2, $3, $4, $5) " +
+		"ON CONFLICT DO NOTHING"
+)
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCampaign(row rowScanner, campaign *models.Campaign) error {
+	var createdAt time.Time
+	if err := row.Scan(&campaign.ID, &campaign.Name, &campaign.StartsAt, &campaign.EndsAt, &campaign.FirstOrder,
+		&campaign.Tier, &campaign.MinAccrual, &campaign.BonusType, &campaign.Bonus, &campaign.Author, &createdAt); err != nil {
+		return err
+	}
+	campaign.CreatedAt = createdAt.Format(time.RFC3339)
+	return nil
+}
+
+func (pg *PgDB) CreateCampaign(ctx context.Context, campaign *models.Campaign) error {
+	return pg.db.QueryRowContext(ctx, createCampaign, campaign.Name, campaign.StartsAt, campaign.EndsAt, campaign.FirstOrder,
+		campaign.Tier, campaign.MinAccrual, campaign.BonusType, campaign.Bonus, campaign.Author, time.Now()).Scan(&campaign.ID)
+}
+
+func (pg *PgDB) ListCampaigns(ctx context.Context, campaigns *[]models.Campaign) error {
+	rows, err := pg.db.QueryContext(ctx, listCampaigns)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var campaign models.Campaign
+		if err := scanCampaign(rows, &campaign); err != nil {
+			return err
+		}
+		*campaigns = append(*campaigns, campaign)
+	}
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+	if len(*campaigns) == 0 {
+		return prjerrors.ErrEmptyData
+	}
+	return nil
+}
+
+func (pg *PgDB) GetCampaign(ctx context.Context, id int64, campaign *models.Campaign) error {
+	if err := scanCampaign(pg.db.QueryRowContext(ctx, getCampaign, id), campaign); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return prjerrors.ErrCampaignNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// UpdateCampaign changes campaign rules, bonuses already credited stay.
+func (pg *PgDB) UpdateCampaign(ctx context.Context, campaign *models.Campaign) error {
+	res, err := pg.db.ExecContext(ctx, updateCampaign, campaign.Name, campaign.StartsAt, campaign.EndsAt, campaign.FirstOrder,
+		campaign.Tier, campaign.MinAccrual, campaign.BonusType, campaign.Bonus, campaign.ID)
+	if err != nil {
+		return err
+	}
+	return campaignAffected(res)
+}
+
+// DeleteCampaign hides campaign, it is kept for bonuses attribution.
+func (pg *PgDB) DeleteCampaign(ctx context.Context, id int64) error {
+	res, err := pg.db.ExecContext(ctx, deleteCampaign, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	return campaignAffected(res)
+}
+
+func campaignAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return prjerrors.ErrCampaignNotFound
+	}
+	return nil
+}
+
+// campaignBonuses credits bonus of every campaign active now the processed
+// order is eligible for, each as own ledger entry named after the campaign.
+// User tier is matched only with tiers policy set.
+func campaignBonuses(ctx context.Context, tx *sql.Tx, tiers *models.TierPolicy, userid, number int64, accrual float64) error {
+	now := time.Now()
+	var campaigns []models.Campaign
+	rows, err := tx.QueryContext(ctx, activeCampaigns, now)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var campaign models.Campaign
+		if err := scanCampaign(rows, &campaign); err != nil {
+			rows.Close()
+			return err
+		}
+		campaigns = append(campaigns, campaign)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+	if len(campaigns) == 0 {
+		return nil
+	}
+
+	var tier string
+	if tiers != nil {
+		if err := tx.QueryRowContext(ctx, userTierName, userid).Scan(&tier); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+	}
+	var (
+		hasProcessed bool
+		checked      bool
+	)
+	for _, campaign := range campaigns {
+		if !campaign.Eligible(tier, accrual) {
+			continue
+		}
+		if campaign.FirstOrder {
+			if !checked {
+				if err := tx.QueryRowContext(ctx, otherProcessed, userid, models.StatusProcessed, number).Scan(&hasProcessed); err != nil {
+					return err
+				}
+				checked = true
+			}
+			if hasProcessed {
+				continue
+			}
+		}
+		bonus := campaign.BonusFor(accrual)
+		if bonus <= 0 {
+			continue
+		}
+		res, err := tx.ExecContext(ctx, addCampaignBonus, campaign.ID, number, userid, bonus, now)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			// already credited for the order
+			continue
+		}
+		if err := balanceAdd(ctx, tx, userid, models.LedgerCampaign, number, bonus, campaign.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
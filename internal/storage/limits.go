@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+const (
+	withdrawalLimits = "SELECT COALESCE(user_tiers.tier, ''), withdrawal_limits.max_sum, withdrawal_limits.daily_max, " +
+		"withdrawal_limits.monthly_max FROM users LEFT JOIN user_tiers ON user_tiers.userid=users.id " +
+		"LEFT JOIN withdrawal_limits ON withdrawal_limits.userid=users.id WHERE users.id=$1"
+	withdrawLock = "SELECT userid FROM balance WHERE userid=$1 FOR UPDATE"
+	// rejected withdrawals never left the balance, reversed part came back
+	withdrawnSince = "SELECT COALESCE(SUM(net) FILTER (WHERE processed_at >= $2), 0), COALESCE(SUM(net), 0) FROM (" +
+		"SELECT orders.processed_at, orders.sum - COALESCE(SUM(withdrawal_reversals.amount), 0) AS net " +
+		"FROM orders LEFT JOIN withdrawal_approvals ON withdrawal_approvals.number=orders.number " +
+		"LEFT JOIN withdrawal_reversals ON withdrawal_reversals.number=orders.number " +
+		"WHERE (orders.userid=$1 AND orders.processable=false AND orders.processed_at >= $3 " +
+		"AND COALESCE(withdrawal_approvals.status, '') <> $4) GROUP BY orders.number, orders.processed_at, orders.sum) withdrawn"
+	// merchant holds not captured yet, the one being captured is excluded
+	heldSince = "SELECT COALESCE(SUM(amount) FILTER (WHERE created_at >= $2), 0), COALESCE(SUM(amount), 0) " +
+		"FROM merchant_authorizations WHERE (userid=$1 AND created_at >= $3 AND status=$4 AND id <> $5)"
+	getUserWithdrawalLimits = "SELECT users.login, withdrawal_limits.max_sum, withdrawal_limits.daily_max, withdrawal_limits.monthly_max, " +
+		"withdrawal_limits.author, withdrawal_limits.updated_at FROM withdrawal_limits " +
+		"JOIN users ON users.id=withdrawal_limits.userid WHERE users.login=$1"
+	upsertWithdrawalLimits = "INSERT INTO withdrawal_limits (userid, max_sum, daily_max, monthly_max, author, updated_at) " +
+		"VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (userid) DO UPDATE SET max_sum=$2, daily_max=$3, monthly_max=$4, author=$5, updated_at=$6"
+	deleteWithdrawalLimits = "DELETE FROM withdrawal_limits WHERE userid=(SELECT id FROM users WHERE login=$1)"
+)
+
+// SetWithdrawalLimits sets global and tier withdrawal limits.
+func (pg *PgDB) SetWithdrawalLimits(policy models.WithdrawalLimitPolicy) {
+	pg.withdrawalLimits = policy
+}
+
+// checkWithdrawalLimits fails with ErrWithdrawalLimit when sum breaks user
+// limits, user override goes before tier and global limits. Merchant holds
+// count as withdrawn, capture passes its authorization id not to count the
+// hold twice. The balance row is locked so concurrent withdrawals of the
+// user are counted.
+func (pg *PgDB) checkWithdrawalLimits(ctx context.Context, tx *sql.Tx, userid int64, sum float64, capture int64) error {
+	var (
+		tier                   string
+		maxSum, daily, monthly sql.NullFloat64
+	)
+	if err := tx.QueryRowContext(ctx, withdrawalLimits, userid).Scan(&tier, &maxSum, &daily, &monthly); err != nil {
+		return err
+	}
+	if pg.tiers == nil {
+		tier = ""
+	}
+	limits := pg.withdrawalLimits.For(tier)
+	if maxSum.Valid {
+		limits = models.WithdrawalLimits{Max: maxSum.Float64, DailyMax: daily.Float64, MonthlyMax: monthly.Float64}
+	}
+	if limits.Max > 0 && sum > limits.Max {
+		return prjerrors.ErrWithdrawalLimit
+	}
+	if limits.DailyMax <= 0 && limits.MonthlyMax <= 0 {
+		return nil
+	}
+
+	rows, err := tx.QueryContext(ctx, withdrawLock, userid)
+	if err != nil {
+		return err
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+	now := time.Now()
+	day, month := now.Add(-24*time.Hour), now.Add(-30*24*time.Hour)
+	var dailySum, monthlySum, dailyHeld, monthlyHeld float64
+	if err := tx.QueryRowContext(ctx, withdrawnSince, userid, day, month,
+		models.WithdrawalRejected).Scan(&dailySum, &monthlySum); err != nil {
+		return err
+	}
+	if err := tx.QueryRowContext(ctx, heldSince, userid, day, month,
+		models.AuthorizationAuthorized, capture).Scan(&dailyHeld, &monthlyHeld); err != nil {
+		return err
+	}
+	dailySum += dailyHeld
+	monthlySum += monthlyHeld
+	if limits.DailyMax > 0 && math.Round((dailySum+sum)*100)/100 > limits.DailyMax {
+		return prjerrors.ErrWithdrawalLimit
+	}
+	if limits.MonthlyMax > 0 && math.Round((monthlySum+sum)*100)/100 > limits.MonthlyMax {
+		return prjerrors.ErrWithdrawalLimit
+	}
+	return nil
+}
+
+// GetUserWithdrawalLimits gives limits override of the user.
+func (pg *PgDB) GetUserWithdrawalLimits(ctx context.Context, login string, limits *models.UserWithdrawalLimits) error {
+	var updatedAt time.Time
+	if err := pg.db.QueryRowContext(ctx, getUserWithdrawalLimits, login).Scan(&limits.Login, &limits.Max,
+		&limits.DailyMax, &limits.MonthlyMax, &limits.Author, &updatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return prjerrors.ErrWithdrawalLimitsNotSet
+		}
+		return err
+	}
+	limits.UpdatedAt = updatedAt.Format(time.RFC3339)
+	return nil
+}
+
+// SetUserWithdrawalLimits creates or replaces limits override of the user.
+func (pg *PgDB) SetUserWithdrawalLimits(ctx context.Context, limits *models.UserWithdrawalLimits) error {
+	var userid int64
+	if err := pg.db.QueryRowContext(ctx, getUserID, limits.Login).Scan(&userid); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return prjerrors.ErrNotExists
+		}
+		return err
+	}
+	now := time.Now()
+	if _, err := pg.db.ExecContext(ctx, upsertWithdrawalLimits, userid, limits.Max, limits.DailyMax,
+		limits.MonthlyMax, limits.Author, now); err != nil {
+		return err
+	}
+	limits.UpdatedAt = now.Format(time.RFC3339)
+	return nil
+}
+
+// DeleteUserWithdrawalLimits drops limits override, the user gets tier or
+// global limits again.
+func (pg *PgDB) DeleteUserWithdrawalLimits(ctx context.Context, login string) error {
+	res, err := pg.db.ExecContext(ctx, deleteWithdrawalLimits, login)
+	if err != nil {
+		return err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return prjerrors.ErrWithdrawalLimitsNotSet
+	}
+	return nil
+}
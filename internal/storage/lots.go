@@ -0,0 +1,289 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/sourcecd/gofermart/internal/models"
+)
+
+const (
+	addLot   = "INSERT INTO accrual_lots (userid, amount, remaining, created_at) VALUES ($1, $2, $2, $3)"
+	openLots = "SELECT id, remaining, created_at FROM accrual_lots WHERE (userid=$1 AND remaining > 0) ORDER BY created_at, id FOR UPDATE"
+	spendLot = "UPDATE accrual_lots SET remaining=(remaining - $1) WHERE id=$2"
+
+	addLotSpend   = "INSERT INTO lot_spends (lot_id, source, ref, amount, created_at) VALUES ($1, $2, $3, $4, $5)"
+	lotSpends     = "SELECT id, lot_id, amount FROM lot_spends WHERE (source=$1 AND ref=$2 AND amount > 0) ORDER BY id DESC FOR UPDATE"
+	restoreLot    = "UPDATE accrual_lots SET remaining=(remaining + $1) WHERE id=$2"
+	takeLotSpend  = "UPDATE lot_spends SET amount=(amount - $1) WHERE id=$2"
+	retagLotSpend = "UPDATE lot_spends SET source=$1, ref=$2 WHERE (source=$3 AND ref=$4 AND amount > 0)"
+
+	expiringSoon = "SELECT COALESCE(SUM(remaining), 0), MIN(created_at) FROM accrual_lots " +
+		"WHERE (userid=$1 AND remaining > 0 AND created_at + make_interval(months => $2) <= $3)"
+	expiryUsers = "SELECT DISTINCT userid FROM accrual_lots WHERE (remaining > 0 AND created_at + make_interval(months => $1) <= $2)"
+	expiryLock  = "SELECT current FROM balance WHERE userid=$1 FOR UPDATE"
+	expireLots  = "WITH due AS (SELECT id, remaining FROM accrual_lots " +
+		"WHERE (userid=$1 AND remaining > 0 AND created_at + make_interval(months => $2) <= $3) FOR UPDATE) " +
+		"UPDATE accrual_lots SET remaining=0 FROM due WHERE accrual_lots.id=due.id RETURNING due.remaining"
+	expireBalance = "UPDATE balance SET current=(current - $1) WHERE userid=$2 RETURNING current"
+)
+
+// lotsEpsilon absorbs float rounding when lots are spent.
+const lotsEpsilon = 1e-9
+
+// sources of lot spends returned later
+const (
+	spendWithdrawal    = "withdrawal"
+	spendAuthorization = "authorization"
+)
+
+// lotSpend names the operation lots are spent for, so the points it gives
+// back return to the same lots keeping their age. Zero value is not
+// recorded.
+type lotSpend struct {
+	source string
+	ref    int64
+}
+
+// SetPointsExpiry sets months accrued points live and how long before
+// expiry they are reported as expiring soon, zero months disables expiry.
+func (pg *PgDB) SetPointsExpiry(months int, soon time.Duration) {
+	pg.expiryMonths = months
+	pg.expirySoon = soon
+}
+
+// lotPart is amount taken from a lot created at the time.
+type lotPart struct {
+	createdAt time.Time
+	amount    float64
+}
+
+// spendLots takes amount from user lots oldest first, balance row must be
+// locked by caller before lots. Lots taken are recorded for the spend and
+// returned oldest first.
+func spendLots(ctx context.Context, tx *sql.Tx, userid int64, amount float64, spend lotSpend) ([]lotPart, error) {
+	type lot struct {
+		id        int64
+		remaining float64
+		createdAt time.Time
+	}
+	var lots []lot
+	rows, err := tx.QueryContext(ctx, openLots, userid)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var l lot
+		if err := rows.Scan(&l.id, &l.remaining, &l.createdAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		lots = append(lots, l)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	var parts []lotPart
+	now := time.Now()
+	for _, l := range lots {
+		if amount <= lotsEpsilon {
+			break
+		}
+		take := min(l.remaining, amount)
+		if _, err := tx.ExecContext(ctx, spendLot, take, l.id); err != nil {
+			return nil, err
+		}
+		if spend.source != "" {
+			if _, err := tx.ExecContext(ctx, addLotSpend, l.id, spend.source, spend.ref, take, now); err != nil {
+				return nil, err
+			}
+		}
+		parts = append(parts, lotPart{createdAt: l.createdAt, amount: take})
+		amount -= take
+	}
+	if amount > lotsEpsilon {
+		slog.Warn("balance spent over accrual lots", slog.Int64("userid", userid), slog.Float64("uncovered", amount))
+	}
+	return parts, nil
+}
+
+// addLots credits amount as lots keeping creation time of the parts, e.g.
+// points transferred from another user expire when they would expire there.
+// Amount not covered by parts becomes a new lot.
+func addLots(ctx context.Context, tx *sql.Tx, userid int64, amount float64, parts []lotPart) error {
+	for _, p := range parts {
+		if amount <= lotsEpsilon {
+			return nil
+		}
+		take := min(p.amount, amount)
+		if _, err := tx.ExecContext(ctx, addLot, userid, take, p.createdAt); err != nil {
+			return err
+		}
+		amount -= take
+	}
+	if amount > lotsEpsilon {
+		_, err := tx.ExecContext(ctx, addLot, userid, amount, time.Now())
+		return err
+	}
+	return nil
+}
+
+// restoreLots returns amount to the lots spent for the operation, latest
+// spent first. Amount not covered by spends, e.g. spent before spends were
+// recorded, becomes a new lot.
+func restoreLots(ctx context.Context, tx *sql.Tx, userid int64, amount float64, spend lotSpend) error {
+	type spent struct {
+		id     int64
+		lotID  int64
+		amount float64
+	}
+	var spends []spent
+	rows, err := tx.QueryContext(ctx, lotSpends, spend.source, spend.ref)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var s spent
+		if err := rows.Scan(&s.id, &s.lotID, &s.amount); err != nil {
+			rows.Close()
+			return err
+		}
+		spends = append(spends, s)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+
+	for _, s := range spends {
+		if amount <= lotsEpsilon {
+			break
+		}
+		take := min(s.amount, amount)
+		if _, err := tx.ExecContext(ctx, restoreLot, take, s.lotID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, takeLotSpend, take, s.id); err != nil {
+			return err
+		}
+		amount -= take
+	}
+	if amount > lotsEpsilon {
+		_, err := tx.ExecContext(ctx, addLot, userid, amount, time.Now())
+		return err
+	}
+	return nil
+}
+
+// retagLots moves lots still spent for one operation to another, e.g.
+// captured authorization becomes a withdrawal.
+func retagLots(ctx context.Context, tx *sql.Tx, from, to lotSpend) error {
+	_, err := tx.ExecContext(ctx, retagLotSpend, to.source, to.ref, from.source, from.ref)
+	return err
+}
+
+func (pg *PgDB) expiringSoon(ctx context.Context, userid int64, balance *models.Balance) error {
+	if pg.expiryMonths <= 0 {
+		return nil
+	}
+	var (
+		amount    float64
+		createdAt sql.NullTime
+	)
+	if err := pg.db.QueryRowContext(ctx, expiringSoon, userid, pg.expiryMonths, time.Now().Add(pg.expirySoon)).Scan(&amount, &createdAt); err != nil {
+		return err
+	}
+	if amount > 0 && createdAt.Valid {
+		balance.ExpiringSoon = &models.ExpiringPoints{
+			Amount:    amount,
+			ExpiresAt: createdAt.Time.AddDate(0, pg.expiryMonths, 0).Format(time.RFC3339),
+		}
+	}
+	return nil
+}
+
+// ExpirePoints writes off lots older than expiry months, every user gets
+// one expiry ledger entry per run. Returns number of users affected.
+func (pg *PgDB) ExpirePoints(ctx context.Context) (int64, error) {
+	if pg.expiryMonths <= 0 {
+		return 0, nil
+	}
+	now := time.Now()
+	var users []int64
+	rows, err := pg.db.QueryContext(ctx, expiryUsers, pg.expiryMonths, now)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var userid int64
+		if err := rows.Scan(&userid); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		users = append(users, userid)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return 0, rows.Err()
+	}
+
+	var count int64
+	for _, userid := range users {
+		if err := pg.expireUser(ctx, userid, now); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (pg *PgDB) expireUser(ctx context.Context, userid int64, now time.Time) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// balance first, the same lock order as withdraw and accrual credit
+	var current float64
+	if err := tx.QueryRowContext(ctx, expiryLock, userid).Scan(&current); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	var expired float64
+	rows, err := tx.QueryContext(ctx, expireLots, userid, pg.expiryMonths, now)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var remaining float64
+		if err := rows.Scan(&remaining); err != nil {
+			rows.Close()
+			return err
+		}
+		expired += remaining
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+
+	expired = min(expired, current)
+	if expired <= 0 {
+		return tx.Commit()
+	}
+	if err := tx.QueryRowContext(ctx, expireBalance, expired, userid).Scan(&current); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, addLedger, userid, models.LedgerExpiry, 0, -expired, current, now, ""); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
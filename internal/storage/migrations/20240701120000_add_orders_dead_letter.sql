@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS queued_at TIMESTAMPTZ DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS poll_attempts BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_error TEXT,
+    ADD COLUMN IF NOT EXISTS dead_letter BOOL NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS dead_lettered_at TIMESTAMPTZ;
+UPDATE orders SET queued_at=uploaded_at WHERE processable=true;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE orders
+    DROP COLUMN queued_at,
+    DROP COLUMN poll_attempts,
+    DROP COLUMN last_error,
+    DROP COLUMN dead_letter,
+    DROP COLUMN dead_lettered_at;
+-- +goose StatementEnd
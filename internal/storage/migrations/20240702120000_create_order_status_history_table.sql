@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    number BIGINT NOT NULL,
+    status VARCHAR(255) NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS order_status_history_number_idx ON order_status_history (number);
+INSERT INTO order_status_history (number, status, changed_at)
+    SELECT number, status, uploaded_at FROM orders WHERE processable=true;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE order_status_history;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS order_goods (
+    id BIGSERIAL PRIMARY KEY,
+    number BIGINT NOT NULL REFERENCES orders (number) ON DELETE CASCADE,
+    description TEXT NOT NULL,
+    price DOUBLE PRECISION NOT NULL CHECK (price > 0)
+);
+CREATE INDEX IF NOT EXISTS order_goods_number_idx ON order_goods (number);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE order_goods;
+-- +goose StatementEnd
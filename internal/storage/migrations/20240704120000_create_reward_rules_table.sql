@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS reward_rules (
+    id BIGSERIAL PRIMARY KEY,
+    match VARCHAR(255) NOT NULL,
+    reward DOUBLE PRECISION NOT NULL,
+    reward_type VARCHAR(16) NOT NULL,
+    author VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE reward_rules;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS order_overrides (
+    id BIGSERIAL PRIMARY KEY,
+    number BIGINT NOT NULL REFERENCES orders (number) ON DELETE CASCADE,
+    author VARCHAR(255) NOT NULL,
+    reason TEXT NOT NULL,
+    prev_status VARCHAR(255),
+    prev_accrual DOUBLE PRECISION,
+    status VARCHAR(255) NOT NULL,
+    accrual DOUBLE PRECISION,
+    correction DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS order_overrides_number_idx ON order_overrides (number);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE order_overrides;
+-- +goose StatementEnd
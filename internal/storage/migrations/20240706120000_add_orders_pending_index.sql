@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS orders_pending_idx ON orders (userid) WHERE (processable=true AND processed=false);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX orders_pending_idx;
+-- +goose StatementEnd
@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS balance_ledger (
+    id BIGSERIAL PRIMARY KEY,
+    userid BIGINT NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    number BIGINT,
+    amount DOUBLE PRECISION NOT NULL,
+    balance_after DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS balance_ledger_userid_idx ON balance_ledger (userid, created_at, id);
+-- existing accruals, withdrawals and corrections, running totals in time order
+INSERT INTO balance_ledger (userid, kind, number, amount, balance_after, created_at)
+SELECT userid, kind, number, amount,
+    SUM(amount) OVER (PARTITION BY userid ORDER BY created_at, number, kind ROWS UNBOUNDED PRECEDING),
+    created_at
+FROM (
+    SELECT orders.userid, 'accrual' AS kind, orders.number, orders.accrual AS amount,
+        COALESCE((SELECT MAX(changed_at) FROM order_status_history
+            WHERE order_status_history.number=orders.number AND order_status_history.status='PROCESSED'), orders.uploaded_at) AS created_at
+    FROM orders WHERE (orders.processable=true AND orders.status='PROCESSED' AND orders.accrual IS NOT NULL)
+    UNION ALL
+    SELECT userid, 'withdrawal', number, -sum, processed_at FROM orders WHERE processable=false
+) AS entries;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE balance_ledger;
+-- +goose StatementEnd
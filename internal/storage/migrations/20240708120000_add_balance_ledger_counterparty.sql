@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE balance_ledger ADD COLUMN IF NOT EXISTS counterparty VARCHAR(255);
+CREATE INDEX IF NOT EXISTS balance_ledger_kind_idx ON balance_ledger (userid, kind, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX balance_ledger_kind_idx;
+ALTER TABLE balance_ledger DROP COLUMN counterparty;
+-- +goose StatementEnd
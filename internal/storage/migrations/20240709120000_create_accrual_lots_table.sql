@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS accrual_lots (
+    id BIGSERIAL PRIMARY KEY,
+    userid BIGINT NOT NULL,
+    amount DOUBLE PRECISION NOT NULL,
+    remaining DOUBLE PRECISION NOT NULL CHECK (remaining >= 0),
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS accrual_lots_open_idx ON accrual_lots (userid, created_at, id) WHERE remaining > 0;
+-- points earned before expiry existed start their term now
+INSERT INTO accrual_lots (userid, amount, remaining, created_at)
+SELECT userid, current, current, now() FROM balance WHERE current > 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE accrual_lots;
+-- +goose StatementEnd
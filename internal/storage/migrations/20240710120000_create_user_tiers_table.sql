@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS user_tiers (
+    userid BIGINT PRIMARY KEY,
+    tier VARCHAR(255) NOT NULL DEFAULT '',
+    multiplier DOUBLE PRECISION NOT NULL DEFAULT 1 CHECK (multiplier >= 1),
+    total DOUBLE PRECISION NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE user_tiers;
+-- +goose StatementEnd
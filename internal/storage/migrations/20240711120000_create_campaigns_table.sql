@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS campaigns (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    first_order BOOLEAN NOT NULL DEFAULT false,
+    tier VARCHAR(255) NOT NULL DEFAULT '',
+    min_accrual DOUBLE PRECISION NOT NULL DEFAULT 0,
+    bonus_type VARCHAR(16) NOT NULL,
+    bonus DOUBLE PRECISION NOT NULL,
+    author VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    deleted_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS campaigns_window_idx ON campaigns (starts_at, ends_at) WHERE deleted_at IS NULL;
+-- one bonus per campaign and order, keeps credits attributable
+CREATE TABLE IF NOT EXISTS campaign_bonuses (
+    campaign_id BIGINT NOT NULL,
+    number BIGINT NOT NULL,
+    userid BIGINT NOT NULL,
+    amount DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (campaign_id, number)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE campaign_bonuses;
+DROP TABLE campaigns;
+-- +goose StatementEnd
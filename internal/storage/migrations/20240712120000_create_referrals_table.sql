@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN IF NOT EXISTS referral_code VARCHAR(16);
+UPDATE users SET referral_code = upper(substr(md5(random()::text || id::text), 1, 8)) WHERE referral_code IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS users_referral_code_idx ON users (referral_code);
+CREATE TABLE IF NOT EXISTS referrals (
+    referee BIGINT PRIMARY KEY,
+    referrer BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    number BIGINT,
+    referrer_bonus DOUBLE PRECISION,
+    referee_bonus DOUBLE PRECISION,
+    rewarded_at TIMESTAMPTZ,
+    CHECK (referee <> referrer)
+);
+CREATE INDEX IF NOT EXISTS referrals_referrer_idx ON referrals (referrer);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE referrals;
+DROP INDEX users_referral_code_idx;
+ALTER TABLE users DROP COLUMN referral_code;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE balance ADD COLUMN IF NOT EXISTS held DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK (held >= 0);
+CREATE TABLE IF NOT EXISTS withdrawal_approvals (
+    number BIGINT PRIMARY KEY,
+    userid BIGINT NOT NULL,
+    sum DOUBLE PRECISION NOT NULL,
+    status VARCHAR(32) NOT NULL,
+    requested_at TIMESTAMPTZ NOT NULL,
+    decided_by VARCHAR(255),
+    decided_at TIMESTAMPTZ,
+    reason TEXT
+);
+CREATE INDEX IF NOT EXISTS withdrawal_approvals_status_idx ON withdrawal_approvals (status, requested_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE withdrawal_approvals;
+ALTER TABLE balance DROP COLUMN held;
+-- +goose StatementEnd
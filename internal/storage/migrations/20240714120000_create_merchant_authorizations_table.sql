@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS merchant_authorizations (
+    id BIGSERIAL PRIMARY KEY,
+    merchant VARCHAR(255) NOT NULL,
+    userid BIGINT NOT NULL,
+    amount DOUBLE PRECISION NOT NULL CHECK (amount > 0),
+    captured DOUBLE PRECISION NOT NULL DEFAULT 0,
+    refunded DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK (refunded <= captured),
+    number BIGINT,
+    status VARCHAR(32) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS merchant_authorizations_expiry_idx ON merchant_authorizations (expires_at) WHERE status = 'AUTHORIZED';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE merchant_authorizations;
+-- +goose StatementEnd
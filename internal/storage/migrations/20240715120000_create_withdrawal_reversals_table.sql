@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS withdrawal_reversals (
+    id BIGSERIAL PRIMARY KEY,
+    number BIGINT NOT NULL,
+    userid BIGINT NOT NULL,
+    amount DOUBLE PRECISION NOT NULL CHECK (amount > 0),
+    author VARCHAR(255),
+    merchant VARCHAR(255),
+    reason TEXT,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS withdrawal_reversals_number_idx ON withdrawal_reversals (number);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE withdrawal_reversals;
+-- +goose StatementEnd
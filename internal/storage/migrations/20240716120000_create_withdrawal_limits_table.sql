@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS withdrawal_limits (
+    userid BIGINT PRIMARY KEY,
+    max_sum DOUBLE PRECISION NOT NULL CHECK (max_sum >= 0),
+    daily_max DOUBLE PRECISION NOT NULL CHECK (daily_max >= 0),
+    monthly_max DOUBLE PRECISION NOT NULL CHECK (monthly_max >= 0),
+    author VARCHAR(255) NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS orders_withdrawals_idx ON orders (userid, processed_at) WHERE processable=false;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX orders_withdrawals_idx;
+DROP TABLE withdrawal_limits;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS risk_events (
+    id BIGSERIAL PRIMARY KEY,
+    userid BIGINT NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    number VARCHAR(255) NOT NULL,
+    sum DOUBLE PRECISION,
+    valid BOOLEAN NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    rule VARCHAR(64),
+    created_at TIMESTAMPTZ NOT NULL,
+    resolution VARCHAR(32),
+    reviewed_by VARCHAR(255),
+    reviewed_at TIMESTAMPTZ,
+    note TEXT
+);
+CREATE INDEX IF NOT EXISTS risk_events_userid_idx ON risk_events (userid, kind, id);
+CREATE INDEX IF NOT EXISTS risk_events_review_idx ON risk_events (action, created_at) WHERE action <> 'ALLOW';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE risk_events;
+-- +goose StatementEnd
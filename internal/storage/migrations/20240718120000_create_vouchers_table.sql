@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS voucher_batches (
+    id BIGSERIAL PRIMARY KEY,
+    points DOUBLE PRECISION NOT NULL CHECK (points > 0),
+    max_redemptions INTEGER NOT NULL CHECK (max_redemptions > 0),
+    expires_at TIMESTAMPTZ,
+    author VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE TABLE IF NOT EXISTS vouchers (
+    code VARCHAR(32) PRIMARY KEY,
+    batch_id BIGINT NOT NULL REFERENCES voucher_batches (id),
+    redeemed INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS voucher_redemptions (
+    code VARCHAR(32) NOT NULL,
+    userid BIGINT NOT NULL,
+    points DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (code, userid)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE voucher_redemptions;
+DROP TABLE vouchers;
+DROP TABLE voucher_batches;
+-- +goose StatementEnd
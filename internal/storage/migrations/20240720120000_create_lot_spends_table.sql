@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS lot_spends (
+    id BIGSERIAL PRIMARY KEY,
+    lot_id BIGINT NOT NULL,
+    source VARCHAR(32) NOT NULL,
+    ref BIGINT NOT NULL,
+    amount DOUBLE PRECISION NOT NULL CHECK (amount >= 0),
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS lot_spends_ref_idx ON lot_spends (source, ref) WHERE amount > 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE lot_spends;
+-- +goose StatementEnd
@@ -7,6 +7,7 @@ package mock
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	models "github.com/sourcecd/gofermart/internal/models"
@@ -35,6 +36,35 @@ func (m *MockStore) EXPECT() *MockStoreMockRecorder {
 	return m.recorder
 }
 
+// AccrualDeadLetter mocks base method.
+func (m *MockStore) AccrualDeadLetter(ctx context.Context, maxAge time.Duration, maxAttempts int) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AccrualDeadLetter", ctx, maxAge, maxAttempts)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AccrualDeadLetter indicates an expected call of AccrualDeadLetter.
+func (mr *MockStoreMockRecorder) AccrualDeadLetter(ctx, maxAge, maxAttempts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AccrualDeadLetter", reflect.TypeOf((*MockStore)(nil).AccrualDeadLetter), ctx, maxAge, maxAttempts)
+}
+
+// AccrualPollAttempts mocks base method.
+func (m *MockStore) AccrualPollAttempts(ctx context.Context, attempts []models.PollAttempt) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AccrualPollAttempts", ctx, attempts)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AccrualPollAttempts indicates an expected call of AccrualPollAttempts.
+func (mr *MockStoreMockRecorder) AccrualPollAttempts(ctx, attempts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AccrualPollAttempts", reflect.TypeOf((*MockStore)(nil).AccrualPollAttempts), ctx, attempts)
+}
+
 // AccrualSystemPoll mocks base method.
 func (m *MockStore) AccrualSystemPoll(ctx context.Context, orders *[]int64) error {
 	m.ctrl.T.Helper()
@@ -63,6 +93,20 @@ func (mr *MockStoreMockRecorder) AccrualSystemSave(ctx, accrual interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AccrualSystemSave", reflect.TypeOf((*MockStore)(nil).AccrualSystemSave), ctx, accrual)
 }
 
+// AddRiskEvent mocks base method.
+func (m *MockStore) AddRiskEvent(ctx context.Context, event *models.RiskEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddRiskEvent", ctx, event)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddRiskEvent indicates an expected call of AddRiskEvent.
+func (mr *MockStoreMockRecorder) AddRiskEvent(ctx, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddRiskEvent", reflect.TypeOf((*MockStore)(nil).AddRiskEvent), ctx, event)
+}
+
 // AuthUser mocks base method.
 func (m *MockStore) AuthUser(ctx context.Context, reg *models.User) (int64, error) {
 	m.ctrl.T.Helper()
@@ -78,6 +122,76 @@ func (mr *MockStoreMockRecorder) AuthUser(ctx, reg interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthUser", reflect.TypeOf((*MockStore)(nil).AuthUser), ctx, reg)
 }
 
+// Authorize mocks base method.
+func (m *MockStore) Authorize(ctx context.Context, auth *models.Authorization) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Authorize", ctx, auth)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Authorize indicates an expected call of Authorize.
+func (mr *MockStoreMockRecorder) Authorize(ctx, auth interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Authorize", reflect.TypeOf((*MockStore)(nil).Authorize), ctx, auth)
+}
+
+// BalanceAsOf mocks base method.
+func (m *MockStore) BalanceAsOf(ctx context.Context, userid int64, date time.Time, balance *models.BalanceAsOf) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BalanceAsOf", ctx, userid, date, balance)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BalanceAsOf indicates an expected call of BalanceAsOf.
+func (mr *MockStoreMockRecorder) BalanceAsOf(ctx, userid, date, balance interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BalanceAsOf", reflect.TypeOf((*MockStore)(nil).BalanceAsOf), ctx, userid, date, balance)
+}
+
+// BalanceHistory mocks base method.
+func (m *MockStore) BalanceHistory(ctx context.Context, userid int64, page models.Page, entries *[]models.BalanceEntry) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BalanceHistory", ctx, userid, page, entries)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BalanceHistory indicates an expected call of BalanceHistory.
+func (mr *MockStoreMockRecorder) BalanceHistory(ctx, userid, page, entries interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BalanceHistory", reflect.TypeOf((*MockStore)(nil).BalanceHistory), ctx, userid, page, entries)
+}
+
+// CaptureAuthorization mocks base method.
+func (m *MockStore) CaptureAuthorization(ctx context.Context, op *models.AuthorizationOp, auth *models.Authorization) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CaptureAuthorization", ctx, op, auth)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CaptureAuthorization indicates an expected call of CaptureAuthorization.
+func (mr *MockStoreMockRecorder) CaptureAuthorization(ctx, op, auth interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CaptureAuthorization", reflect.TypeOf((*MockStore)(nil).CaptureAuthorization), ctx, op, auth)
+}
+
+// CreateCampaign mocks base method.
+func (m *MockStore) CreateCampaign(ctx context.Context, campaign *models.Campaign) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateCampaign", ctx, campaign)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateCampaign indicates an expected call of CreateCampaign.
+func (mr *MockStoreMockRecorder) CreateCampaign(ctx, campaign interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCampaign", reflect.TypeOf((*MockStore)(nil).CreateCampaign), ctx, campaign)
+}
+
 // CreateDatabaseScheme mocks base method.
 func (m *MockStore) CreateDatabaseScheme(ctx context.Context) error {
 	m.ctrl.T.Helper()
@@ -106,6 +220,148 @@ func (mr *MockStoreMockRecorder) CreateOrder(ctx, userid, orderid interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrder", reflect.TypeOf((*MockStore)(nil).CreateOrder), ctx, userid, orderid)
 }
 
+// CreateOrderReceipt mocks base method.
+func (m *MockStore) CreateOrderReceipt(ctx context.Context, userid int64, receipt *models.AccrualOrder) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOrderReceipt", ctx, userid, receipt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateOrderReceipt indicates an expected call of CreateOrderReceipt.
+func (mr *MockStoreMockRecorder) CreateOrderReceipt(ctx, userid, receipt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrderReceipt", reflect.TypeOf((*MockStore)(nil).CreateOrderReceipt), ctx, userid, receipt)
+}
+
+// CreatePaymentToken mocks base method.
+func (m *MockStore) CreatePaymentToken(ctx context.Context, userid int64, token *models.PaymentToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePaymentToken", ctx, userid, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreatePaymentToken indicates an expected call of CreatePaymentToken.
+func (mr *MockStoreMockRecorder) CreatePaymentToken(ctx, userid, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePaymentToken", reflect.TypeOf((*MockStore)(nil).CreatePaymentToken), ctx, userid, token)
+}
+
+// CreateRewardRule mocks base method.
+func (m *MockStore) CreateRewardRule(ctx context.Context, rule *models.RewardRule) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRewardRule", ctx, rule)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateRewardRule indicates an expected call of CreateRewardRule.
+func (mr *MockStoreMockRecorder) CreateRewardRule(ctx, rule interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRewardRule", reflect.TypeOf((*MockStore)(nil).CreateRewardRule), ctx, rule)
+}
+
+// CreateVoucherBatch mocks base method.
+func (m *MockStore) CreateVoucherBatch(ctx context.Context, batch *models.VoucherBatch) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateVoucherBatch", ctx, batch)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateVoucherBatch indicates an expected call of CreateVoucherBatch.
+func (mr *MockStoreMockRecorder) CreateVoucherBatch(ctx, batch interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateVoucherBatch", reflect.TypeOf((*MockStore)(nil).CreateVoucherBatch), ctx, batch)
+}
+
+// DecideWithdrawal mocks base method.
+func (m *MockStore) DecideWithdrawal(ctx context.Context, decision *models.WithdrawalDecision) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DecideWithdrawal", ctx, decision)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DecideWithdrawal indicates an expected call of DecideWithdrawal.
+func (mr *MockStoreMockRecorder) DecideWithdrawal(ctx, decision interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecideWithdrawal", reflect.TypeOf((*MockStore)(nil).DecideWithdrawal), ctx, decision)
+}
+
+// DeleteCampaign mocks base method.
+func (m *MockStore) DeleteCampaign(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteCampaign", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteCampaign indicates an expected call of DeleteCampaign.
+func (mr *MockStoreMockRecorder) DeleteCampaign(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCampaign", reflect.TypeOf((*MockStore)(nil).DeleteCampaign), ctx, id)
+}
+
+// DeleteUserWithdrawalLimits mocks base method.
+func (m *MockStore) DeleteUserWithdrawalLimits(ctx context.Context, login string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserWithdrawalLimits", ctx, login)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUserWithdrawalLimits indicates an expected call of DeleteUserWithdrawalLimits.
+func (mr *MockStoreMockRecorder) DeleteUserWithdrawalLimits(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserWithdrawalLimits", reflect.TypeOf((*MockStore)(nil).DeleteUserWithdrawalLimits), ctx, login)
+}
+
+// ExpireAuthorizations mocks base method.
+func (m *MockStore) ExpireAuthorizations(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireAuthorizations", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireAuthorizations indicates an expected call of ExpireAuthorizations.
+func (mr *MockStoreMockRecorder) ExpireAuthorizations(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireAuthorizations", reflect.TypeOf((*MockStore)(nil).ExpireAuthorizations), ctx)
+}
+
+// ExpirePoints mocks base method.
+func (m *MockStore) ExpirePoints(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpirePoints", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpirePoints indicates an expected call of ExpirePoints.
+func (mr *MockStoreMockRecorder) ExpirePoints(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpirePoints", reflect.TypeOf((*MockStore)(nil).ExpirePoints), ctx)
+}
+
+// GetAuthorization mocks base method.
+func (m *MockStore) GetAuthorization(ctx context.Context, merchant string, id int64, auth *models.Authorization) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuthorization", ctx, merchant, id, auth)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GetAuthorization indicates an expected call of GetAuthorization.
+func (mr *MockStoreMockRecorder) GetAuthorization(ctx, merchant, id, auth interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuthorization", reflect.TypeOf((*MockStore)(nil).GetAuthorization), ctx, merchant, id, auth)
+}
+
 // GetBalance mocks base method.
 func (m *MockStore) GetBalance(ctx context.Context, userid int64, balance *models.Balance) error {
 	m.ctrl.T.Helper()
@@ -120,6 +376,34 @@ func (mr *MockStoreMockRecorder) GetBalance(ctx, userid, balance interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalance", reflect.TypeOf((*MockStore)(nil).GetBalance), ctx, userid, balance)
 }
 
+// GetCampaign mocks base method.
+func (m *MockStore) GetCampaign(ctx context.Context, id int64, campaign *models.Campaign) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCampaign", ctx, id, campaign)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GetCampaign indicates an expected call of GetCampaign.
+func (mr *MockStoreMockRecorder) GetCampaign(ctx, id, campaign interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCampaign", reflect.TypeOf((*MockStore)(nil).GetCampaign), ctx, id, campaign)
+}
+
+// GetReferral mocks base method.
+func (m *MockStore) GetReferral(ctx context.Context, userid int64, referral *models.Referral) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReferral", ctx, userid, referral)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GetReferral indicates an expected call of GetReferral.
+func (mr *MockStoreMockRecorder) GetReferral(ctx, userid, referral interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReferral", reflect.TypeOf((*MockStore)(nil).GetReferral), ctx, userid, referral)
+}
+
 // GetSecurityKey mocks base method.
 func (m *MockStore) GetSecurityKey(ctx context.Context) (string, error) {
 	m.ctrl.T.Helper()
@@ -135,6 +419,78 @@ func (mr *MockStoreMockRecorder) GetSecurityKey(ctx interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSecurityKey", reflect.TypeOf((*MockStore)(nil).GetSecurityKey), ctx)
 }
 
+// GetUserID mocks base method.
+func (m *MockStore) GetUserID(ctx context.Context, login string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserID", ctx, login)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserID indicates an expected call of GetUserID.
+func (mr *MockStoreMockRecorder) GetUserID(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserID", reflect.TypeOf((*MockStore)(nil).GetUserID), ctx, login)
+}
+
+// GetUserLogin mocks base method.
+func (m *MockStore) GetUserLogin(ctx context.Context, userid int64) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserLogin", ctx, userid)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserLogin indicates an expected call of GetUserLogin.
+func (mr *MockStoreMockRecorder) GetUserLogin(ctx, userid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserLogin", reflect.TypeOf((*MockStore)(nil).GetUserLogin), ctx, userid)
+}
+
+// GetUserTier mocks base method.
+func (m *MockStore) GetUserTier(ctx context.Context, userid int64, policy *models.TierPolicy, tier *models.UserTier) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserTier", ctx, userid, policy, tier)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GetUserTier indicates an expected call of GetUserTier.
+func (mr *MockStoreMockRecorder) GetUserTier(ctx, userid, policy, tier interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserTier", reflect.TypeOf((*MockStore)(nil).GetUserTier), ctx, userid, policy, tier)
+}
+
+// GetUserWithdrawalLimits mocks base method.
+func (m *MockStore) GetUserWithdrawalLimits(ctx context.Context, login string, limits *models.UserWithdrawalLimits) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserWithdrawalLimits", ctx, login, limits)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GetUserWithdrawalLimits indicates an expected call of GetUserWithdrawalLimits.
+func (mr *MockStoreMockRecorder) GetUserWithdrawalLimits(ctx, login, limits interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserWithdrawalLimits", reflect.TypeOf((*MockStore)(nil).GetUserWithdrawalLimits), ctx, login, limits)
+}
+
+// GetVoucher mocks base method.
+func (m *MockStore) GetVoucher(ctx context.Context, code string, voucher *models.Voucher) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVoucher", ctx, code, voucher)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GetVoucher indicates an expected call of GetVoucher.
+func (mr *MockStoreMockRecorder) GetVoucher(ctx, code, voucher interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVoucher", reflect.TypeOf((*MockStore)(nil).GetVoucher), ctx, code, voucher)
+}
+
 // InitializeSecurityKey mocks base method.
 func (m *MockStore) InitializeSecurityKey(ctx context.Context) error {
 	m.ctrl.T.Helper()
@@ -149,6 +505,34 @@ func (mr *MockStoreMockRecorder) InitializeSecurityKey(ctx interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InitializeSecurityKey", reflect.TypeOf((*MockStore)(nil).InitializeSecurityKey), ctx)
 }
 
+// ListCampaigns mocks base method.
+func (m *MockStore) ListCampaigns(ctx context.Context, campaigns *[]models.Campaign) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListCampaigns", ctx, campaigns)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ListCampaigns indicates an expected call of ListCampaigns.
+func (mr *MockStoreMockRecorder) ListCampaigns(ctx, campaigns interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCampaigns", reflect.TypeOf((*MockStore)(nil).ListCampaigns), ctx, campaigns)
+}
+
+// ListDeadLetters mocks base method.
+func (m *MockStore) ListDeadLetters(ctx context.Context, orders *[]models.DeadLetterOrder) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDeadLetters", ctx, orders)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ListDeadLetters indicates an expected call of ListDeadLetters.
+func (mr *MockStoreMockRecorder) ListDeadLetters(ctx, orders interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDeadLetters", reflect.TypeOf((*MockStore)(nil).ListDeadLetters), ctx, orders)
+}
+
 // ListOrders mocks base method.
 func (m *MockStore) ListOrders(ctx context.Context, userid int64, orderList *[]models.Order) error {
 	m.ctrl.T.Helper()
@@ -163,6 +547,162 @@ func (mr *MockStoreMockRecorder) ListOrders(ctx, userid, orderList interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOrders", reflect.TypeOf((*MockStore)(nil).ListOrders), ctx, userid, orderList)
 }
 
+// ListRewardRules mocks base method.
+func (m *MockStore) ListRewardRules(ctx context.Context, rules *[]models.RewardRule) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListRewardRules", ctx, rules)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ListRewardRules indicates an expected call of ListRewardRules.
+func (mr *MockStoreMockRecorder) ListRewardRules(ctx, rules interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRewardRules", reflect.TypeOf((*MockStore)(nil).ListRewardRules), ctx, rules)
+}
+
+// ListRiskEvents mocks base method.
+func (m *MockStore) ListRiskEvents(ctx context.Context, action string, reviewed bool, page models.Page, events *[]models.RiskEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListRiskEvents", ctx, action, reviewed, page, events)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ListRiskEvents indicates an expected call of ListRiskEvents.
+func (mr *MockStoreMockRecorder) ListRiskEvents(ctx, action, reviewed, page, events interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRiskEvents", reflect.TypeOf((*MockStore)(nil).ListRiskEvents), ctx, action, reviewed, page, events)
+}
+
+// ListWithdrawalApprovals mocks base method.
+func (m *MockStore) ListWithdrawalApprovals(ctx context.Context, status string, approvals *[]models.WithdrawalApproval) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWithdrawalApprovals", ctx, status, approvals)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ListWithdrawalApprovals indicates an expected call of ListWithdrawalApprovals.
+func (mr *MockStoreMockRecorder) ListWithdrawalApprovals(ctx, status, approvals interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWithdrawalApprovals", reflect.TypeOf((*MockStore)(nil).ListWithdrawalApprovals), ctx, status, approvals)
+}
+
+// OrderGoods mocks base method.
+func (m *MockStore) OrderGoods(ctx context.Context, orderid int64) ([]models.Good, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OrderGoods", ctx, orderid)
+	ret0, _ := ret[0].([]models.Good)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OrderGoods indicates an expected call of OrderGoods.
+func (mr *MockStoreMockRecorder) OrderGoods(ctx, orderid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderGoods", reflect.TypeOf((*MockStore)(nil).OrderGoods), ctx, orderid)
+}
+
+// OrderStatusHistory mocks base method.
+func (m *MockStore) OrderStatusHistory(ctx context.Context, userid, orderid int64, history *[]models.OrderStatusChange) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OrderStatusHistory", ctx, userid, orderid, history)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// OrderStatusHistory indicates an expected call of OrderStatusHistory.
+func (mr *MockStoreMockRecorder) OrderStatusHistory(ctx, userid, orderid, history interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderStatusHistory", reflect.TypeOf((*MockStore)(nil).OrderStatusHistory), ctx, userid, orderid, history)
+}
+
+// OverrideOrder mocks base method.
+func (m *MockStore) OverrideOrder(ctx context.Context, override *models.OrderOverride) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OverrideOrder", ctx, override)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// OverrideOrder indicates an expected call of OverrideOrder.
+func (mr *MockStoreMockRecorder) OverrideOrder(ctx, override interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OverrideOrder", reflect.TypeOf((*MockStore)(nil).OverrideOrder), ctx, override)
+}
+
+// PendingOrders mocks base method.
+func (m *MockStore) PendingOrders(ctx context.Context, userid int64, orders *[]models.AccrualOrder) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PendingOrders", ctx, userid, orders)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PendingOrders indicates an expected call of PendingOrders.
+func (mr *MockStoreMockRecorder) PendingOrders(ctx, userid, orders interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PendingOrders", reflect.TypeOf((*MockStore)(nil).PendingOrders), ctx, userid, orders)
+}
+
+// Ping mocks base method.
+func (m *MockStore) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockStoreMockRecorder) Ping(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockStore)(nil).Ping), ctx)
+}
+
+// RecalculateTiers mocks base method.
+func (m *MockStore) RecalculateTiers(ctx context.Context, policy *models.TierPolicy) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecalculateTiers", ctx, policy)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecalculateTiers indicates an expected call of RecalculateTiers.
+func (mr *MockStoreMockRecorder) RecalculateTiers(ctx, policy interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecalculateTiers", reflect.TypeOf((*MockStore)(nil).RecalculateTiers), ctx, policy)
+}
+
+// RedeemVoucher mocks base method.
+func (m *MockStore) RedeemVoucher(ctx context.Context, userid int64, redemption *models.VoucherRedemption) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RedeemVoucher", ctx, userid, redemption)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RedeemVoucher indicates an expected call of RedeemVoucher.
+func (mr *MockStoreMockRecorder) RedeemVoucher(ctx, userid, redemption interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RedeemVoucher", reflect.TypeOf((*MockStore)(nil).RedeemVoucher), ctx, userid, redemption)
+}
+
+// RefundAuthorization mocks base method.
+func (m *MockStore) RefundAuthorization(ctx context.Context, op *models.AuthorizationOp, auth *models.Authorization) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefundAuthorization", ctx, op, auth)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RefundAuthorization indicates an expected call of RefundAuthorization.
+func (mr *MockStoreMockRecorder) RefundAuthorization(ctx, op, auth interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefundAuthorization", reflect.TypeOf((*MockStore)(nil).RefundAuthorization), ctx, op, auth)
+}
+
 // RegisterUser mocks base method.
 func (m *MockStore) RegisterUser(ctx context.Context, reg *models.User) (int64, error) {
 	m.ctrl.T.Helper()
@@ -178,6 +718,146 @@ func (mr *MockStoreMockRecorder) RegisterUser(ctx, reg interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterUser", reflect.TypeOf((*MockStore)(nil).RegisterUser), ctx, reg)
 }
 
+// RequeueDeadLetter mocks base method.
+func (m *MockStore) RequeueDeadLetter(ctx context.Context, number int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequeueDeadLetter", ctx, number)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RequeueDeadLetter indicates an expected call of RequeueDeadLetter.
+func (mr *MockStoreMockRecorder) RequeueDeadLetter(ctx, number interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequeueDeadLetter", reflect.TypeOf((*MockStore)(nil).RequeueDeadLetter), ctx, number)
+}
+
+// ReverseWithdrawal mocks base method.
+func (m *MockStore) ReverseWithdrawal(ctx context.Context, reversal *models.Reversal) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReverseWithdrawal", ctx, reversal)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReverseWithdrawal indicates an expected call of ReverseWithdrawal.
+func (mr *MockStoreMockRecorder) ReverseWithdrawal(ctx, reversal interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReverseWithdrawal", reflect.TypeOf((*MockStore)(nil).ReverseWithdrawal), ctx, reversal)
+}
+
+// ReviewRiskEvent mocks base method.
+func (m *MockStore) ReviewRiskEvent(ctx context.Context, review *models.RiskReview) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReviewRiskEvent", ctx, review)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReviewRiskEvent indicates an expected call of ReviewRiskEvent.
+func (mr *MockStoreMockRecorder) ReviewRiskEvent(ctx, review interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReviewRiskEvent", reflect.TypeOf((*MockStore)(nil).ReviewRiskEvent), ctx, review)
+}
+
+// RiskProfile mocks base method.
+func (m *MockStore) RiskProfile(ctx context.Context, userid int64, profile *models.RiskProfile) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RiskProfile", ctx, userid, profile)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RiskProfile indicates an expected call of RiskProfile.
+func (mr *MockStoreMockRecorder) RiskProfile(ctx, userid, profile interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RiskProfile", reflect.TypeOf((*MockStore)(nil).RiskProfile), ctx, userid, profile)
+}
+
+// SetRewardRuleStatus mocks base method.
+func (m *MockStore) SetRewardRuleStatus(ctx context.Context, id int64, status string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRewardRuleStatus", ctx, id, status)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetRewardRuleStatus indicates an expected call of SetRewardRuleStatus.
+func (mr *MockStoreMockRecorder) SetRewardRuleStatus(ctx, id, status interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRewardRuleStatus", reflect.TypeOf((*MockStore)(nil).SetRewardRuleStatus), ctx, id, status)
+}
+
+// SetUserWithdrawalLimits mocks base method.
+func (m *MockStore) SetUserWithdrawalLimits(ctx context.Context, limits *models.UserWithdrawalLimits) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserWithdrawalLimits", ctx, limits)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetUserWithdrawalLimits indicates an expected call of SetUserWithdrawalLimits.
+func (mr *MockStoreMockRecorder) SetUserWithdrawalLimits(ctx, limits interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserWithdrawalLimits", reflect.TypeOf((*MockStore)(nil).SetUserWithdrawalLimits), ctx, limits)
+}
+
+// Statement mocks base method.
+func (m *MockStore) Statement(ctx context.Context, userid int64, period models.Period, line func(*models.BalanceEntry) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Statement", ctx, userid, period, line)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Statement indicates an expected call of Statement.
+func (mr *MockStoreMockRecorder) Statement(ctx, userid, period, line interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Statement", reflect.TypeOf((*MockStore)(nil).Statement), ctx, userid, period, line)
+}
+
+// Transfer mocks base method.
+func (m *MockStore) Transfer(ctx context.Context, userid int64, transfer *models.Transfer, limits models.TransferLimits) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Transfer", ctx, userid, transfer, limits)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Transfer indicates an expected call of Transfer.
+func (mr *MockStoreMockRecorder) Transfer(ctx, userid, transfer, limits interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Transfer", reflect.TypeOf((*MockStore)(nil).Transfer), ctx, userid, transfer, limits)
+}
+
+// UpdateCampaign mocks base method.
+func (m *MockStore) UpdateCampaign(ctx context.Context, campaign *models.Campaign) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateCampaign", ctx, campaign)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateCampaign indicates an expected call of UpdateCampaign.
+func (mr *MockStoreMockRecorder) UpdateCampaign(ctx, campaign interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCampaign", reflect.TypeOf((*MockStore)(nil).UpdateCampaign), ctx, campaign)
+}
+
+// VoidAuthorization mocks base method.
+func (m *MockStore) VoidAuthorization(ctx context.Context, op *models.AuthorizationOp, auth *models.Authorization) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VoidAuthorization", ctx, op, auth)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VoidAuthorization indicates an expected call of VoidAuthorization.
+func (mr *MockStoreMockRecorder) VoidAuthorization(ctx, op, auth interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VoidAuthorization", reflect.TypeOf((*MockStore)(nil).VoidAuthorization), ctx, op, auth)
+}
+
 // Withdraw mocks base method.
 func (m *MockStore) Withdraw(ctx context.Context, userid int64, withdraw *models.Withdraw) error {
 	m.ctrl.T.Helper()
@@ -21,6 +21,19 @@ import (
 
 type PgDB struct {
 	db *sql.DB
+
+	expiryMonths int
+	expirySoon   time.Duration
+
+	referral models.ReferralPolicy
+	// tiers is nil when loyalty tiers are disabled, user tiers left from
+	// earlier runs are ignored then
+	tiers *models.TierPolicy
+
+	approvalThreshold float64
+	authorizationTTL  time.Duration
+	paymentTokenTTL   time.Duration
+	withdrawalLimits  models.WithdrawalLimitPolicy
 }
 
 //go:embed migrations/*.sql
@@ -31,25 +44,79 @@ const (
 	createSecureKey  = "INSERT INTO security (seckey) VALUES ($1)"
 	getSecurityKey   = "SELECT seckey FROM security"
 
-	createUserRec = "INSERT INTO users (login, password) VALUES ($1, $2) RETURNING id"
+	createUserRec = "INSERT INTO users (login, password, referral_code) VALUES ($1, $2, $3) RETURNING id"
 
-	getUserRec = "SELECT id, login, password FROM users WHERE login=$1"
+	getUserRec   = "SELECT id, login, password FROM users WHERE login=$1"
+	getUserLogin = "SELECT login FROM users WHERE id=$1"
+	getUserID    = "SELECT id FROM users WHERE login=$1"
 
 	createOrderRec = "INSERT INTO orders (userid, number, uploaded_at, processable, processed, status) VALUES ($1, $2, $3, $4, $5, 'NEW')"
 	checkOrderRec  = "SELECT userid FROM orders WHERE number=$1"
 
+	addOrderGood  = "INSERT INTO order_goods (number, description, price) VALUES ($1, $2, $3)"
+	getOrderGoods = "SELECT description, price FROM order_goods WHERE number=$1 ORDER BY id"
+
+	addStatusHistory = "INSERT INTO order_status_history (number, status, changed_at) VALUES ($1, $2, $3)"
+	getStatusHistory = "SELECT order_status_history.status, order_status_history.changed_at FROM order_status_history " +
+		"JOIN orders ON orders.number=order_status_history.number " +
+		"WHERE (order_status_history.number=$1 AND orders.userid=$2 AND orders.processable=true) ORDER BY order_status_history.id"
+
 	listOrders = "SELECT number, uploaded_at, status, accrual FROM orders WHERE (userid=$1 AND processable=true) ORDER BY uploaded_at DESC"
 
-	checkBalance = "SELECT current, withdrawn FROM balance WHERE userid=$1"
+	checkBalance = "SELECT COALESCE(balance.current, 0), COALESCE(balance.withdrawn, 0), COALESCE(balance.held, 0), pending.count FROM " +
+		"(SELECT COUNT(*) AS count FROM orders WHERE (userid=$1 AND processable=true AND processed=false AND dead_letter=false)) AS pending " +
+		"LEFT JOIN balance ON balance.userid=$1"
+	pendingOrders = "SELECT orders.number, order_goods.description, order_goods.price FROM orders " +
+		"LEFT JOIN order_goods ON order_goods.number=orders.number " +
+		"WHERE (orders.userid=$1 AND orders.processable=true AND orders.processed=false AND orders.dead_letter=false) " +
+		"ORDER BY orders.number, order_goods.id"
 
-	withdrawOp             = "UPDATE balance SET current=(current - $1), withdrawn=(withdrawn + $1) WHERE userid=$2"
+	withdrawOp             = "UPDATE balance SET current=(current - $1), withdrawn=(withdrawn + $1) WHERE userid=$2 RETURNING current"
 	createOrderRecWithdraw = "INSERT INTO orders (userid, number, sum, processed_at, processable) VALUES ($1, $2, $3, $4, $5)"
 
-	getWithdrawals = "SELECT number, sum, processed_at FROM orders WHERE (userid=$1 AND processable=false) ORDER BY processed_at DESC"
+	transferLock  = "SELECT userid FROM balance WHERE userid IN ($1, $2) ORDER BY userid FOR UPDATE"
+	transferDaily = "SELECT COALESCE(SUM(-amount), 0) FROM balance_ledger WHERE (userid=$1 AND kind=$2 AND created_at >= $3)"
+
+	getWithdrawals = "SELECT orders.number, orders.sum, orders.processed_at, COALESCE(withdrawal_approvals.status, ''), COALESCE(reversals.amount, 0) " +
+		"FROM orders LEFT JOIN withdrawal_approvals ON withdrawal_approvals.number=orders.number " +
+		"LEFT JOIN (SELECT number, SUM(amount) AS amount FROM withdrawal_reversals WHERE userid=$1 GROUP BY number) AS reversals " +
+		"ON reversals.number=orders.number " +
+		"WHERE (orders.userid=$1 AND orders.processable=false) ORDER BY orders.processed_at DESC"
 
-	accrualPollReq = "SELECT number FROM orders WHERE (processable=true AND processed=false)"
+	accrualPollReq = "SELECT number FROM orders WHERE (processable=true AND processed=false AND dead_letter=false)"
+	accrualCurrent = "SELECT userid, status FROM orders WHERE (number=$1 AND processable=true) FOR UPDATE"
 	accrualUpdate  = "UPDATE orders SET status=$1, accrual=$2, processed=$3 WHERE number=$4"
-	accrualBalance = "INSERT INTO balance (userid, current, withdrawn) VALUES ($2, $1, 0) ON CONFLICT (userid) DO UPDATE SET current=(balance.current + $1)"
+	accrualBalance = "INSERT INTO balance (userid, current, withdrawn) VALUES ($2, $1, 0) ON CONFLICT (userid) DO UPDATE SET current=(balance.current + $1) RETURNING current"
+
+	overrideCurrent = "SELECT userid, status, accrual FROM orders WHERE (number=$1 AND processable=true) FOR UPDATE"
+	overrideUpdate  = "UPDATE orders SET status=$1, accrual=$2, processed=$3, dead_letter=false, dead_lettered_at=NULL WHERE number=$4"
+	orderBonuses    = "SELECT kind, COALESCE(counterparty, ''), SUM(amount) FROM balance_ledger " +
+		"WHERE (userid=$1 AND number=$2 AND kind = ANY($3)) GROUP BY kind, counterparty HAVING SUM(amount) > 0"
+	dropCampaignBonuses = "DELETE FROM campaign_bonuses WHERE (userid=$1 AND number=$2)"
+	addOverride         = "INSERT INTO order_overrides (number, author, reason, prev_status, prev_accrual, status, accrual, correction, created_at) " +
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)"
+
+	// zero number and empty counterparty are stored as NULL
+	addLedger = "INSERT INTO balance_ledger (userid, kind, number, amount, balance_after, created_at, counterparty) " +
+		"VALUES ($1, $2, NULLIF($3::bigint, 0), $4, $5, $6, NULLIF($7, ''))"
+	balanceLedger = "SELECT kind, number, COALESCE(counterparty, ''), amount, balance_after, created_at FROM balance_ledger " +
+		"WHERE userid=$1 ORDER BY created_at, id LIMIT $2 OFFSET $3"
+	balanceAsOf = "SELECT balance_after FROM balance_ledger WHERE (userid=$1 AND created_at <= $2) ORDER BY created_at DESC, id DESC LIMIT 1"
+
+	statementOpening = "SELECT balance_after FROM balance_ledger WHERE (userid=$1 AND created_at < $2) ORDER BY created_at DESC, id DESC LIMIT 1"
+	statementEntries = "SELECT kind, number, COALESCE(counterparty, ''), amount, balance_after, created_at FROM balance_ledger " +
+		"WHERE (userid=$1 AND created_at >= $2 AND created_at < $3) ORDER BY created_at, id"
+
+	createRewardRule = "INSERT INTO reward_rules (match, reward, reward_type, author, created_at, status) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id"
+	setRewardRule    = "UPDATE reward_rules SET status=$1 WHERE id=$2"
+	listRewardRules  = "SELECT match, reward, reward_type, author, created_at FROM reward_rules WHERE status=$1 ORDER BY id"
+
+	accrualAttempt    = "UPDATE orders SET poll_attempts=(poll_attempts + 1), last_error=COALESCE(NULLIF($2, ''), last_error) WHERE number=$1"
+	accrualDeadLetter = "UPDATE orders SET dead_letter=true, dead_lettered_at=$1, last_error=COALESCE(last_error, 'order stuck in polling') " +
+		"WHERE (processable=true AND processed=false AND dead_letter=false AND (($2 > 0 AND poll_attempts >= $2) OR ($3::timestamptz IS NOT NULL AND queued_at < $3)))"
+	listDeadLetters = "SELECT orders.number, users.login, orders.status, orders.uploaded_at, orders.poll_attempts, orders.last_error, orders.dead_lettered_at " +
+		"FROM orders JOIN users ON users.id=orders.userid WHERE orders.dead_letter=true ORDER BY orders.dead_lettered_at DESC"
+	requeueDeadLetter = "UPDATE orders SET dead_letter=false, dead_lettered_at=NULL, poll_attempts=0, queued_at=$1 WHERE (number=$2 AND dead_letter=true)"
 )
 
 func NewDB(dsn string) (*PgDB, error) {
@@ -62,6 +129,10 @@ func NewDB(dsn string) (*PgDB, error) {
 	}, nil
 }
 
+func (pg *PgDB) Ping(ctx context.Context) error {
+	return pg.db.PingContext(ctx)
+}
+
 func (pg *PgDB) CreateDatabaseScheme(ctx context.Context) error {
 	goose.SetBaseFS(embedMigrations)
 
@@ -105,17 +176,56 @@ func (pg *PgDB) GetSecurityKey(ctx context.Context) (string, error) {
 	return seckey, nil
 }
 
+// RegisterUser creates user with own referral code and links it to the
+// inviting user when registration carries a referral code. Referral code
+// collision is retried with a new code.
 func (pg *PgDB) RegisterUser(ctx context.Context, reg *models.User) (int64, error) {
-	var id int64
-	err := pg.db.QueryRowContext(ctx, createUserRec, reg.Login, crypto.GeneratePasswordHash(reg.Password)).Scan(&id)
+	var err error
+	for i := 0; i < referralCodeAttempts; i++ {
+		var code string
+		if code, err = crypto.GenerateReferralCode(); err != nil {
+			return -1, err
+		}
+		var id int64
+		id, err = pg.registerUser(ctx, reg, code)
+		if !errors.Is(err, errReferralCodeTaken) {
+			return id, err
+		}
+	}
+	return -1, err
+}
+
+func (pg *PgDB) registerUser(ctx context.Context, reg *models.User, code string) (int64, error) {
+	tx, err := pg.db.Begin()
 	if err != nil {
+		return -1, err
+	}
+	defer tx.Rollback()
+
+	var referrer int64
+	if reg.ReferralCode != "" {
+		if referrer, err = referrerByCode(ctx, tx, reg); err != nil {
+			return -1, err
+		}
+	}
+
+	var id int64
+	if err := tx.QueryRowContext(ctx, createUserRec, reg.Login, crypto.GeneratePasswordHash(reg.Password), code).Scan(&id); err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
+			if pgErr.ConstraintName == referralCodeIndex {
+				return -1, errReferralCodeTaken
+			}
 			return -1, prjerrors.ErrAlreadyExists
 		}
 		return -1, err
 	}
-	return id, nil
+	if referrer != 0 {
+		if _, err := tx.ExecContext(ctx, addReferral, id, referrer, time.Now()); err != nil {
+			return -1, err
+		}
+	}
+	return id, tx.Commit()
 }
 
 func (pg *PgDB) AuthUser(ctx context.Context, reg *models.User) (int64, error) {
@@ -137,9 +247,40 @@ func (pg *PgDB) AuthUser(ctx context.Context, reg *models.User) (int64, error) {
 	return -1, prjerrors.ErrNotExists
 }
 
-func (pg *PgDB) CreateOrder(ctx context.Context, userid, orderid int64) error {
+func (pg *PgDB) GetUserLogin(ctx context.Context, userid int64) (string, error) {
+	var login string
+	row := pg.db.QueryRowContext(ctx, getUserLogin, userid)
+	if err := row.Scan(&login); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", prjerrors.ErrNotExists
+		}
+		return "", err
+	}
+	return login, nil
+}
+
+func (pg *PgDB) GetUserID(ctx context.Context, login string) (int64, error) {
+	var id int64
+	row := pg.db.QueryRowContext(ctx, getUserID, login)
+	if err := row.Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return -1, prjerrors.ErrNotExists
+		}
+		return -1, err
+	}
+	return id, nil
+}
+
+func (pg *PgDB) createOrder(ctx context.Context, userid, orderid int64, goods []models.Good) error {
 	var checkUserID int64
-	if _, err := pg.db.ExecContext(ctx, createOrderRec, userid, orderid, time.Now(), true, false); err != nil {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx, createOrderRec, userid, orderid, now, true, false); err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
 			row := pg.db.QueryRowContext(ctx, checkOrderRec, orderid)
@@ -151,8 +292,56 @@ func (pg *PgDB) CreateOrder(ctx context.Context, userid, orderid int64) error {
 			}
 			return prjerrors.ErrOtherOrderAlreadyExists
 		}
+		return err
 	}
-	return nil
+	if _, err := tx.ExecContext(ctx, addStatusHistory, orderid, models.StatusNew, now); err != nil {
+		return err
+	}
+	for _, v := range goods {
+		if _, err := tx.ExecContext(ctx, addOrderGood, orderid, v.Description, v.Price); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (pg *PgDB) CreateOrder(ctx context.Context, userid, orderid int64) error {
+	return pg.createOrder(ctx, userid, orderid, nil)
+}
+
+func (pg *PgDB) CreateOrderReceipt(ctx context.Context, userid int64, receipt *models.AccrualOrder) error {
+	num, err := strconv.ParseInt(receipt.Order, 10, 64)
+	if err != nil {
+		return err
+	}
+	return pg.createOrder(ctx, userid, num, receipt.Goods)
+}
+
+func (pg *PgDB) OrderGoods(ctx context.Context, orderid int64) ([]models.Good, error) {
+	var (
+		goods       []models.Good
+		description string
+		price       float64
+	)
+	rows, err := pg.db.QueryContext(ctx, getOrderGoods, orderid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := rows.Scan(&description, &price); err != nil {
+			return nil, err
+		}
+		goods = append(goods, models.Good{
+			Description: description,
+			Price:       price,
+		})
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return goods, nil
 }
 
 func (pg *PgDB) ListOrders(ctx context.Context, userid int64, orderList *[]models.Order) error {
@@ -177,7 +366,7 @@ func (pg *PgDB) ListOrders(ctx context.Context, userid int64, orderList *[]model
 		*orderList = append(*orderList, models.Order{
 			Number:     fmt.Sprint(number),
 			UploadedAt: uploadedAt.Format(time.RFC3339),
-			Status:     status,
+			Status:     models.OrderStatus(status),
 			Accrual:    accrual.Float64,
 		})
 		rowsCount++
@@ -191,47 +380,122 @@ func (pg *PgDB) ListOrders(ctx context.Context, userid int64, orderList *[]model
 	return nil
 }
 
+func (pg *PgDB) OrderStatusHistory(ctx context.Context, userid, orderid int64, history *[]models.OrderStatusChange) error {
+	var (
+		status    string
+		changedAt time.Time
+
+		rowsCount int64
+	)
+	rows, err := pg.db.QueryContext(ctx, getStatusHistory, orderid, userid)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := rows.Scan(&status, &changedAt); err != nil {
+			return err
+		}
+		*history = append(*history, models.OrderStatusChange{
+			Status:    models.OrderStatus(status),
+			ChangedAt: changedAt.Format(time.RFC3339),
+		})
+		rowsCount++
+	}
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+	if rowsCount == 0 {
+		return prjerrors.ErrOrderNotFound
+	}
+	return nil
+}
+
 func (pg *PgDB) GetBalance(ctx context.Context, userid int64, balance *models.Balance) error {
 	var (
-		current   float64
-		withdrawn float64
+		current      float64
+		withdrawn    float64
+		held         float64
+		pendingCount int64
 	)
+	// pending subquery always returns one row, so no balance rec gives zeros
 	row := pg.db.QueryRowContext(ctx, checkBalance, userid)
-	if err := row.Scan(&current, &withdrawn); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			//may be need to create default balance rec
-			return nil
-		}
+	if err := row.Scan(&current, &withdrawn, &held, &pendingCount); err != nil {
 		return err
 	}
 	balance.Current = current
 	balance.Withdrawn = withdrawn
-	return nil
+	balance.Held = held
+	if pendingCount > 0 {
+		balance.Pending = &models.PendingAccrual{Count: pendingCount}
+	}
+	return pg.expiringSoon(ctx, userid, balance)
 }
 
+// PendingOrders lists orders not finished by the accrual system yet with
+// their receipt goods, goods are empty for orders uploaded without receipt.
+func (pg *PgDB) PendingOrders(ctx context.Context, userid int64, orders *[]models.AccrualOrder) error {
+	var (
+		number      int64
+		description sql.NullString
+		price       sql.NullFloat64
+	)
+	rows, err := pg.db.QueryContext(ctx, pendingOrders, userid)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := rows.Scan(&number, &description, &price); err != nil {
+			return err
+		}
+		num := fmt.Sprint(number)
+		if len(*orders) == 0 || (*orders)[len(*orders)-1].Order != num {
+			*orders = append(*orders, models.AccrualOrder{Order: num})
+		}
+		if description.Valid {
+			last := &(*orders)[len(*orders)-1]
+			last.Goods = append(last.Goods, models.Good{
+				Description: description.String,
+				Price:       price.Float64,
+			})
+		}
+	}
+	return rows.Err()
+}
+
+// Withdraw debits the sum right away, sums above the approval threshold are
+// put on hold instead and wait for an admin decision.
 func (pg *PgDB) Withdraw(ctx context.Context, userid int64, withdraw *models.Withdraw) error {
 	tx, err := pg.db.Begin()
-	defer tx.Rollback()
 	if err != nil {
 		return err
 	}
-	res, err := tx.ExecContext(ctx, withdrawOp, withdraw.Sum, userid)
-	if err != nil {
+	defer tx.Rollback()
+	if err := pg.checkWithdrawalLimits(ctx, tx, userid, withdraw.Sum, 0); err != nil {
+		return err
+	}
+	op := withdrawOp
+	withdraw.Status = ""
+	if pg.approvalThreshold > 0 && withdraw.Sum > pg.approvalThreshold {
+		op = withdrawHold
+		withdraw.Status = models.WithdrawalPendingApproval
+	}
+	var current float64
+	if err := tx.QueryRowContext(ctx, op, withdraw.Sum, userid).Scan(&current); err != nil {
 		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
+		if errors.Is(err, sql.ErrNoRows) || errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
 			return prjerrors.ErrNotEnough
 		}
 		return err
 	}
-	r, err := res.RowsAffected()
+	num, err := strconv.Atoi(withdraw.Order)
 	if err != nil {
 		return err
 	}
-	if r == 0 {
-		return prjerrors.ErrNotEnough
-	}
-	num, err := strconv.Atoi(withdraw.Order)
-	if err != nil {
+	if _, err := spendLots(ctx, tx, userid, withdraw.Sum, lotSpend{spendWithdrawal, int64(num)}); err != nil {
 		return err
 	}
 	if _, err := tx.ExecContext(ctx, createOrderRecWithdraw, userid, num, withdraw.Sum, time.Now(), false); err != nil {
@@ -241,14 +505,200 @@ func (pg *PgDB) Withdraw(ctx context.Context, userid int64, withdraw *models.Wit
 		}
 		return err
 	}
+	if withdraw.Status == models.WithdrawalPendingApproval {
+		if _, err := tx.ExecContext(ctx, addApproval, num, userid, withdraw.Sum, withdraw.Status, time.Now()); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, addLedger, userid, models.LedgerWithdrawal, num, -withdraw.Sum, current, time.Now(), ""); err != nil {
+		return err
+	}
 	return tx.Commit()
 }
 
+// Transfer moves sum from user to recipient login in one tx, both get
+// ledger entries with the other side as counterparty.
+func (pg *PgDB) Transfer(ctx context.Context, userid int64, transfer *models.Transfer, limits models.TransferLimits) error {
+	if limits.Max > 0 && transfer.Sum > limits.Max {
+		return prjerrors.ErrTransferLimit
+	}
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var recipient int64
+	if err := tx.QueryRowContext(ctx, getUserID, transfer.To).Scan(&recipient); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return prjerrors.ErrNotExists
+		}
+		return err
+	}
+	if recipient == userid {
+		return prjerrors.ErrSelfTransfer
+	}
+	var sender string
+	if err := tx.QueryRowContext(ctx, getUserLogin, userid).Scan(&sender); err != nil {
+		return err
+	}
+
+	// both rows locked in the same order, concurrent transfers of the
+	// sender also wait here before the daily sum is read
+	rows, err := tx.QueryContext(ctx, transferLock, userid, recipient)
+	if err != nil {
+		return err
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+
+	if limits.DailyMax > 0 {
+		var sent float64
+		if err := tx.QueryRowContext(ctx, transferDaily, userid, models.LedgerTransferOut, time.Now().Add(-24*time.Hour)).Scan(&sent); err != nil {
+			return err
+		}
+		if sent+transfer.Sum > limits.DailyMax {
+			return prjerrors.ErrTransferLimit
+		}
+	}
+
+	// recipient lots keep the age of sender lots, so passing points around
+	// does not put off their expiry
+	now := time.Now()
+	current, err := updateBalance(ctx, tx, userid, -transfer.Sum)
+	if err != nil {
+		return err
+	}
+	parts, err := spendLots(ctx, tx, userid, transfer.Sum, lotSpend{})
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, addLedger, userid, models.LedgerTransferOut, 0, -transfer.Sum, current, now, transfer.To); err != nil {
+		return err
+	}
+	if current, err = updateBalance(ctx, tx, recipient, transfer.Sum); err != nil {
+		return err
+	}
+	if err := addLots(ctx, tx, recipient, transfer.Sum, parts); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, addLedger, recipient, models.LedgerTransferIn, 0, transfer.Sum, current, now, sender); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// balanceAdd changes user balance by amount and writes ledger entry with
+// resulting balance, balance going below zero gives ErrNotEnough. Credits
+// open a new accrual lot, debits spend the oldest lots first.
+func balanceAdd(ctx context.Context, tx *sql.Tx, userid int64, kind string, number int64, amount float64, counterparty string) error {
+	return balanceMove(ctx, tx, userid, kind, number, amount, counterparty, lotSpend{})
+}
+
+// balanceMove is balanceAdd with lots spent or returned for the spend, credit
+// for a recorded spend goes back to the lots it was taken from.
+func balanceMove(ctx context.Context, tx *sql.Tx, userid int64, kind string, number int64, amount float64, counterparty string, spend lotSpend) error {
+	current, err := updateBalance(ctx, tx, userid, amount)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	switch {
+	case amount > 0 && spend.source != "":
+		if err := restoreLots(ctx, tx, userid, amount, spend); err != nil {
+			return err
+		}
+	case amount > 0:
+		if _, err := tx.ExecContext(ctx, addLot, userid, amount, now); err != nil {
+			return err
+		}
+	default:
+		if _, err := spendLots(ctx, tx, userid, -amount, spend); err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, addLedger, userid, kind, number, amount, current, now, counterparty)
+	return err
+}
+
+// updateBalance changes user balance by amount and returns the result,
+// balance going below zero gives ErrNotEnough.
+func updateBalance(ctx context.Context, tx *sql.Tx, userid int64, amount float64) (float64, error) {
+	var current float64
+	if err := tx.QueryRowContext(ctx, accrualBalance, amount, userid).Scan(&current); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
+			return 0, prjerrors.ErrNotEnough
+		}
+		return 0, err
+	}
+	return current, nil
+}
+
+func (pg *PgDB) BalanceHistory(ctx context.Context, userid int64, page models.Page, entries *[]models.BalanceEntry) error {
+	var (
+		kind         string
+		number       sql.NullInt64
+		counterparty string
+		amount       float64
+		balanceAfter float64
+		createdAt    time.Time
+
+		rowsCount int64
+	)
+	rows, err := pg.db.QueryContext(ctx, balanceLedger, userid, page.Limit, page.Offset)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := rows.Scan(&kind, &number, &counterparty, &amount, &balanceAfter, &createdAt); err != nil {
+			return err
+		}
+		entry := models.BalanceEntry{
+			Kind:         kind,
+			Counterparty: counterparty,
+			Amount:       amount,
+			Balance:      balanceAfter,
+			CreatedAt:    createdAt.Format(time.RFC3339),
+		}
+		if number.Valid {
+			entry.Order = fmt.Sprint(number.Int64)
+		}
+		*entries = append(*entries, entry)
+		rowsCount++
+	}
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+	if rowsCount == 0 {
+		return prjerrors.ErrEmptyData
+	}
+	return nil
+}
+
+// BalanceAsOf gives user balance after the last ledger entry made not
+// later than date, zero before the first one.
+func (pg *PgDB) BalanceAsOf(ctx context.Context, userid int64, date time.Time, balance *models.BalanceAsOf) error {
+	var current float64
+	if err := pg.db.QueryRowContext(ctx, balanceAsOf, userid, date).Scan(&current); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	balance.Date = date.Format(time.RFC3339)
+	balance.Current = current
+	return nil
+}
+
 func (pg *PgDB) Withdrawals(ctx context.Context, userid int64, withdrawals *[]models.Withdrawals) error {
 	var (
 		number      int64
 		sum         float64
 		processedAt time.Time
+		status      string
+		reversed    float64
 
 		rowsCount int64
 	)
@@ -259,13 +709,15 @@ func (pg *PgDB) Withdrawals(ctx context.Context, userid int64, withdrawals *[]mo
 	defer rows.Close()
 
 	for rows.Next() {
-		if err := rows.Scan(&number, &sum, &processedAt); err != nil {
+		if err := rows.Scan(&number, &sum, &processedAt, &status, &reversed); err != nil {
 			return err
 		}
 		*withdrawals = append(*withdrawals, models.Withdrawals{
 			Order:       fmt.Sprint(number),
 			Sum:         sum,
 			ProcessedAt: processedAt.Format(time.RFC3339),
+			Status:      status,
+			Reversed:    reversed,
 		})
 		rowsCount++
 	}
@@ -309,32 +761,369 @@ func (pg *PgDB) AccrualSystemSave(ctx context.Context, accrual []models.Accrual)
 		num, err := strconv.Atoi(v.Order)
 		if err != nil {
 			slog.Error(err.Error())
+			continue
 		}
-		switch v.Status {
-		case "PROCESSED":
-			var userid int64
-			if _, err := tx.ExecContext(ctx, accrualUpdate, v.Status, v.Accrual, true, num); err != nil {
-				return err
-			}
-			if err := pg.db.QueryRowContext(ctx, checkOrderRec, num).Scan(&userid); err != nil {
-				return err
-			}
-			if _, err := tx.ExecContext(ctx, accrualBalance, v.Accrual, userid); err != nil {
-				return err
+		if !v.Status.Valid() {
+			slog.Warn("unknown accrual status", slog.String("order", v.Order), slog.String("status", string(v.Status)))
+			continue
+		}
+
+		var (
+			userid  int64
+			current string
+		)
+		if err := tx.QueryRowContext(ctx, accrualCurrent, num).Scan(&userid, &current); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				slog.Warn("accrual for unknown order", slog.String("order", v.Order))
+				continue
 			}
-		case "PROCESSING":
-			if _, err := tx.ExecContext(ctx, accrualUpdate, v.Status, v.Accrual, false, num); err != nil {
+			return err
+		}
+		if models.OrderStatus(current) == v.Status {
+			continue
+		}
+		// repeated or misbehaving results (poll and callback) never move
+		// the order back and never credit twice
+		if !models.OrderStatus(current).CanTransition(v.Status) {
+			slog.Warn("illegal order status transition",
+				slog.String("order", v.Order), slog.String("from", current), slog.String("to", string(v.Status)))
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, accrualUpdate, v.Status, v.Accrual, v.Status.Final(), num); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, addStatusHistory, num, v.Status, time.Now()); err != nil {
+			return err
+		}
+		if v.Status == models.StatusProcessed && v.Accrual != nil {
+			if err := balanceAdd(ctx, tx, userid, models.LedgerAccrual, int64(num), *v.Accrual, ""); err != nil {
 				return err
 			}
-		case "INVALID":
-			if _, err := tx.ExecContext(ctx, accrualUpdate, v.Status, v.Accrual, true, num); err != nil {
+			if err := pg.grantOrderBonuses(ctx, tx, userid, int64(num), *v.Accrual); err != nil {
 				return err
 			}
-		case "REGISTERED":
-			if _, err := tx.ExecContext(ctx, accrualUpdate, v.Status, v.Accrual, false, num); err != nil {
+		}
+	}
+	return tx.Commit()
+}
+
+func (pg *PgDB) AccrualPollAttempts(ctx context.Context, attempts []models.PollAttempt) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, v := range attempts {
+		if _, err := tx.ExecContext(ctx, accrualAttempt, v.Number, v.Error); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (pg *PgDB) AccrualDeadLetter(ctx context.Context, maxAge time.Duration, maxAttempts int) (int64, error) {
+	var queuedBefore sql.NullTime
+	if maxAge > 0 {
+		queuedBefore = sql.NullTime{Time: time.Now().Add(-maxAge), Valid: true}
+	}
+	res, err := pg.db.ExecContext(ctx, accrualDeadLetter, time.Now(), maxAttempts, queuedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (pg *PgDB) ListDeadLetters(ctx context.Context, orders *[]models.DeadLetterOrder) error {
+	var (
+		number         int64
+		login          string
+		status         string
+		uploadedAt     time.Time
+		attempts       int64
+		lastError      sql.NullString
+		deadLetteredAt time.Time
+
+		rowsCount int64
+	)
+	rows, err := pg.db.QueryContext(ctx, listDeadLetters)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := rows.Scan(&number, &login, &status, &uploadedAt, &attempts, &lastError, &deadLetteredAt); err != nil {
+			return err
+		}
+		*orders = append(*orders, models.DeadLetterOrder{
+			Number:         fmt.Sprint(number),
+			Login:          login,
+			Status:         status,
+			UploadedAt:     uploadedAt.Format(time.RFC3339),
+			Attempts:       attempts,
+			LastError:      lastError.String,
+			DeadLetteredAt: deadLetteredAt.Format(time.RFC3339),
+		})
+		rowsCount++
+	}
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+	if rowsCount == 0 {
+		return prjerrors.ErrEmptyData
+	}
+	return nil
+}
+
+func (pg *PgDB) RequeueDeadLetter(ctx context.Context, number int64) error {
+	res, err := pg.db.ExecContext(ctx, requeueDeadLetter, time.Now(), number)
+	if err != nil {
+		return err
+	}
+	r, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if r == 0 {
+		return prjerrors.ErrOrderNotFound
+	}
+	return nil
+}
+
+// credited is the order accrual already added to the balance.
+func credited(status models.OrderStatus, accrual *float64) float64 {
+	if status != models.StatusProcessed || accrual == nil {
+		return 0
+	}
+	return *accrual
+}
+
+// revokeOrderBonuses takes back tier, campaign and referral bonuses credited
+// for the order, so they are granted again if the order is processed later.
+func (pg *PgDB) revokeOrderBonuses(ctx context.Context, tx *sql.Tx, userid, number int64) error {
+	type bonus struct {
+		kind         string
+		counterparty string
+		amount       float64
+	}
+	var bonuses []bonus
+	rows, err := tx.QueryContext(ctx, orderBonuses, userid, number,
+		[]string{models.LedgerTierBonus, models.LedgerCampaign, models.LedgerReferral})
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var b bonus
+		if err := rows.Scan(&b.kind, &b.counterparty, &b.amount); err != nil {
+			rows.Close()
+			return err
+		}
+		bonuses = append(bonuses, b)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+
+	for _, b := range bonuses {
+		if err := balanceAdd(ctx, tx, userid, b.kind, number, -b.amount, b.counterparty); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, dropCampaignBonuses, userid, number); err != nil {
+		return err
+	}
+	return revokeReferralBonus(ctx, tx, userid, number)
+}
+
+// grantOrderBonuses credits bonuses of the processed order the same way as
+// accrual system results do.
+func (pg *PgDB) grantOrderBonuses(ctx context.Context, tx *sql.Tx, userid, number int64, accrual float64) error {
+	if pg.tiers != nil {
+		if err := tierBonus(ctx, tx, userid, number, accrual); err != nil {
+			return err
+		}
+	}
+	if err := campaignBonuses(ctx, tx, pg.tiers, userid, number, accrual); err != nil {
+		return err
+	}
+	return pg.referralBonus(ctx, tx, userid, number, accrual)
+}
+
+// OverrideOrder sets order status and accrual regardless of transition
+// rules and corrects the balance by the accrual difference in one tx,
+// bonuses linked to the order follow the corrected accrual.
+func (pg *PgDB) OverrideOrder(ctx context.Context, override *models.OrderOverride) error {
+	num, err := strconv.Atoi(override.Number)
+	if err != nil {
+		return err
+	}
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var (
+		userid      int64
+		prevStatus  string
+		prevAccrual sql.NullFloat64
+	)
+	if err := tx.QueryRowContext(ctx, overrideCurrent, num).Scan(&userid, &prevStatus, &prevAccrual); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return prjerrors.ErrOrderNotFound
+		}
+		return err
+	}
+	override.PrevStatus = models.OrderStatus(prevStatus)
+	override.PrevAccrual = nil
+	if prevAccrual.Valid {
+		override.PrevAccrual = &prevAccrual.Float64
+	}
+	override.Correction = credited(override.Status, override.Accrual) - credited(override.PrevStatus, override.PrevAccrual)
+
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx, overrideUpdate, override.Status, override.Accrual, override.Status.Final(), num); err != nil {
+		return err
+	}
+	if override.Status != override.PrevStatus {
+		if _, err := tx.ExecContext(ctx, addStatusHistory, num, override.Status, now); err != nil {
+			return err
+		}
+	}
+	if override.Correction != 0 {
+		if err := balanceAdd(ctx, tx, userid, models.LedgerCorrection, int64(num), override.Correction, ""); err != nil {
+			return err
+		}
+		if err := pg.revokeOrderBonuses(ctx, tx, userid, int64(num)); err != nil {
+			return err
+		}
+		if accrual := credited(override.Status, override.Accrual); accrual > 0 {
+			if err := pg.grantOrderBonuses(ctx, tx, userid, int64(num), accrual); err != nil {
 				return err
 			}
 		}
 	}
+	if _, err := tx.ExecContext(ctx, addOverride, num, override.Author, override.Reason,
+		override.PrevStatus, override.PrevAccrual, override.Status, override.Accrual, override.Correction, now); err != nil {
+		return err
+	}
 	return tx.Commit()
 }
+
+// Statement passes opening balance, every ledger entry of the period and
+// closing balance to line as rows are read, nothing is kept in memory.
+func (pg *PgDB) Statement(ctx context.Context, userid int64, period models.Period, line func(line *models.BalanceEntry) error) error {
+	// one snapshot for opening balance and entries
+	tx, err := pg.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current float64
+	if err := tx.QueryRowContext(ctx, statementOpening, userid, period.From).Scan(&current); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err := line(&models.BalanceEntry{
+		Kind:      models.LedgerOpening,
+		Balance:   current,
+		CreatedAt: period.From.Format(time.RFC3339),
+	}); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, statementEntries, userid, period.From, period.To)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var (
+		kind         string
+		number       sql.NullInt64
+		counterparty string
+		amount       float64
+		createdAt    time.Time
+	)
+	for rows.Next() {
+		if err := rows.Scan(&kind, &number, &counterparty, &amount, &current, &createdAt); err != nil {
+			return err
+		}
+		entry := &models.BalanceEntry{
+			Kind:         kind,
+			Counterparty: counterparty,
+			Amount:       amount,
+			Balance:      current,
+			CreatedAt:    createdAt.Format(time.RFC3339),
+		}
+		if number.Valid {
+			entry.Order = fmt.Sprint(number.Int64)
+		}
+		if err := line(entry); err != nil {
+			return err
+		}
+	}
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+	return line(&models.BalanceEntry{
+		Kind:      models.LedgerClosing,
+		Balance:   current,
+		CreatedAt: period.To.Format(time.RFC3339),
+	})
+}
+
+// CreateRewardRule records the rule as pending, it becomes active once
+// accrual system accepts it.
+func (pg *PgDB) CreateRewardRule(ctx context.Context, rule *models.RewardRule) error {
+	return pg.db.QueryRowContext(ctx, createRewardRule, rule.Match, rule.Reward, rule.RewardType, rule.Author,
+		time.Now(), models.RewardRulePending).Scan(&rule.ID)
+}
+
+func (pg *PgDB) SetRewardRuleStatus(ctx context.Context, id int64, status string) error {
+	_, err := pg.db.ExecContext(ctx, setRewardRule, status, id)
+	return err
+}
+
+func (pg *PgDB) ListRewardRules(ctx context.Context, rules *[]models.RewardRule) error {
+	var (
+		match      string
+		reward     float64
+		rewardType string
+		author     string
+		createdAt  time.Time
+
+		rowsCount int64
+	)
+	rows, err := pg.db.QueryContext(ctx, listRewardRules, models.RewardRuleActive)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := rows.Scan(&match, &reward, &rewardType, &author, &createdAt); err != nil {
+			return err
+		}
+		*rules = append(*rules, models.RewardRule{
+			AccrualGoods: models.AccrualGoods{
+				Match:      match,
+				Reward:     reward,
+				RewardType: rewardType,
+			},
+			Author:    author,
+			CreatedAt: createdAt.Format(time.RFC3339),
+		})
+		rowsCount++
+	}
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+	if rowsCount == 0 {
+		return prjerrors.ErrEmptyData
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+const (
+	getReferrer      = "SELECT id FROM users WHERE referral_code=$1"
+	addReferral      = "INSERT INTO referrals (referee, referrer, created_at) VALUES ($1, $2, $3)"
+	pendingReferral  = "SELECT referrer FROM referrals WHERE (referee=$1 AND rewarded_at IS NULL) FOR UPDATE"
+	referrerRewards  = "SELECT COUNT(*) FROM referrals WHERE (referrer=$1 AND referrer_bonus > 0)"
+	rewardReferral   = "UPDATE referrals SET number=$1, referrer_bonus=$2, referee_bonus=$3, rewarded_at=$4 WHERE referee=$5"
+	rewardedBy       = "SELECT referrer, COALESCE(referrer_bonus, 0) FROM referrals WHERE (referee=$1 AND number=$2) FOR UPDATE"
+	unrewardReferral = "UPDATE referrals SET number=NULL, referrer_bonus=NULL, referee_bonus=NULL, rewarded_at=NULL WHERE referee=$1"
+	// points moved between the parties tell one person owns both accounts
+	referralTransfers = "SELECT EXISTS (SELECT 1 FROM balance_ledger WHERE (kind=$1 AND " +
+		"((userid=$2 AND counterparty=$3) OR (userid=$4 AND counterparty=$5))))"
+
+	getReferralCode = "SELECT COALESCE(referral_code, '') FROM users WHERE id=$1"
+	referralStats   = "SELECT COUNT(*), COUNT(rewarded_at) FROM referrals WHERE referrer=$1"
+	referralEarned  = "SELECT COALESCE(SUM(amount), 0) FROM balance_ledger WHERE (userid=$1 AND kind=$2)"
+)
+
+const (
+	referralCodeIndex    = "users_referral_code_idx"
+	referralCodeAttempts = 3
+)
+
+var errReferralCodeTaken = errors.New("referral code already taken")
+
+// SetReferralPolicy sets bonuses paid for referrals, zero bonuses disable
+// the program.
+func (pg *PgDB) SetReferralPolicy(policy models.ReferralPolicy) {
+	pg.referral = policy
+}
+
+// referrerByCode finds the inviting user, code of the user registering
+// from the client logged in as the code owner is a self-referral. The check
+// is best effort, a clean client passes it, referralBonus checks the
+// parties again before paying.
+func referrerByCode(ctx context.Context, tx *sql.Tx, reg *models.User) (int64, error) {
+	var referrer int64
+	if err := tx.QueryRowContext(ctx, getReferrer, strings.ToUpper(reg.ReferralCode)).Scan(&referrer); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, prjerrors.ErrReferralCode
+		}
+		return 0, err
+	}
+	if referrer == reg.SessionUser {
+		return 0, prjerrors.ErrSelfReferral
+	}
+	return referrer, nil
+}
+
+// referralBonus rewards both parties once the referee first order with
+// accrual is processed, orders without accrual do not count. Referrer over
+// the rewards limit gets nothing, the referee is still rewarded. Parties
+// which transferred points to each other are a self-referral, the referral
+// is left unrewarded.
+func (pg *PgDB) referralBonus(ctx context.Context, tx *sql.Tx, userid, number int64, accrual float64) error {
+	if accrual <= 0 || (pg.referral.ReferrerBonus <= 0 && pg.referral.RefereeBonus <= 0) {
+		return nil
+	}
+	var referrer int64
+	if err := tx.QueryRowContext(ctx, pendingReferral, userid).Scan(&referrer); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	var referrerLogin, refereeLogin string
+	if err := tx.QueryRowContext(ctx, getUserLogin, referrer).Scan(&referrerLogin); err != nil {
+		return err
+	}
+	if err := tx.QueryRowContext(ctx, getUserLogin, userid).Scan(&refereeLogin); err != nil {
+		return err
+	}
+	var linked bool
+	if err := tx.QueryRowContext(ctx, referralTransfers, models.LedgerTransferOut,
+		userid, referrerLogin, referrer, refereeLogin).Scan(&linked); err != nil {
+		return err
+	}
+	if linked {
+		slog.Warn("self-referral not rewarded", slog.String("referrer", referrerLogin),
+			slog.String("referee", refereeLogin), slog.Int64("order", number))
+		return nil
+	}
+
+	referrerBonus := pg.referral.ReferrerBonus
+	if pg.referral.MaxRewards > 0 && referrerBonus > 0 {
+		var rewards int
+		if err := tx.QueryRowContext(ctx, referrerRewards, referrer).Scan(&rewards); err != nil {
+			return err
+		}
+		if rewards >= pg.referral.MaxRewards {
+			referrerBonus = 0
+		}
+	}
+
+	if pg.referral.RefereeBonus > 0 {
+		if err := balanceAdd(ctx, tx, userid, models.LedgerReferral, number, pg.referral.RefereeBonus, referrerLogin); err != nil {
+			return err
+		}
+	}
+	// referee order number is not shown to the referrer
+	if referrerBonus > 0 {
+		if err := balanceAdd(ctx, tx, referrer, models.LedgerReferral, 0, referrerBonus, refereeLogin); err != nil {
+			return err
+		}
+	}
+	_, err := tx.ExecContext(ctx, rewardReferral, number, referrerBonus, pg.referral.RefereeBonus, time.Now(), userid)
+	return err
+}
+
+// revokeReferralBonus takes back referrer bonus paid for the order and makes
+// the referral pending again, referee bonus is revoked with order bonuses.
+func revokeReferralBonus(ctx context.Context, tx *sql.Tx, userid, number int64) error {
+	var (
+		referrer      int64
+		referrerBonus float64
+	)
+	if err := tx.QueryRowContext(ctx, rewardedBy, userid, number).Scan(&referrer, &referrerBonus); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	if referrerBonus > 0 {
+		var refereeLogin string
+		if err := tx.QueryRowContext(ctx, getUserLogin, userid).Scan(&refereeLogin); err != nil {
+			return err
+		}
+		if err := balanceAdd(ctx, tx, referrer, models.LedgerReferral, 0, -referrerBonus, refereeLogin); err != nil {
+			return err
+		}
+	}
+	_, err := tx.ExecContext(ctx, unrewardReferral, userid)
+	return err
+}
+
+func (pg *PgDB) GetReferral(ctx context.Context, userid int64, referral *models.Referral) error {
+	if err := pg.db.QueryRowContext(ctx, getReferralCode, userid).Scan(&referral.Code); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return prjerrors.ErrNotExists
+		}
+		return err
+	}
+	if err := pg.db.QueryRowContext(ctx, referralStats, userid).Scan(&referral.Invited, &referral.Rewarded); err != nil {
+		return err
+	}
+	return pg.db.QueryRowContext(ctx, referralEarned, userid, models.LedgerReferral).Scan(&referral.Earned)
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+const (
+	numberAuthorization = "SELECT id, merchant FROM merchant_authorizations WHERE number=$1 FOR UPDATE"
+	reversalWithdrawal  = "SELECT orders.userid, orders.sum, COALESCE(withdrawal_approvals.status, '') FROM orders " +
+		"LEFT JOIN withdrawal_approvals ON withdrawal_approvals.number=orders.number " +
+		"WHERE (orders.number=$1 AND orders.processable=false) FOR UPDATE OF orders"
+	reversedSum = "SELECT COALESCE(SUM(amount), 0) FROM withdrawal_reversals WHERE number=$1"
+	addReversal = "INSERT INTO withdrawal_reversals (number, userid, amount, author, merchant, reason, created_at) " +
+		"VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), $7)"
+	refundWithdraw = "UPDATE balance SET withdrawn=(withdrawn - $1) WHERE userid=$2"
+)
+
+// ReverseWithdrawal returns part or all of withdrawal by order number,
+// refund of captured merchant authorization is kept in sync.
+func (pg *PgDB) ReverseWithdrawal(ctx context.Context, reversal *models.Reversal) error {
+	num, err := strconv.ParseInt(reversal.Order, 10, 64)
+	if err != nil {
+		return err
+	}
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// authorization first, the same lock order as merchant refund
+	var (
+		authID   int64
+		merchant string
+	)
+	if err := tx.QueryRowContext(ctx, numberAuthorization, num).Scan(&authID, &merchant); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if reversal.Merchant != "" && (authID == 0 || merchant != reversal.Merchant) {
+		return prjerrors.ErrOrderNotFound
+	}
+
+	if err := reverseWithdrawal(ctx, tx, num, reversal); err != nil {
+		return err
+	}
+	if authID != 0 {
+		status := models.AuthorizationCaptured
+		if reversal.Remaining == 0 {
+			status = models.AuthorizationRefunded
+		}
+		if _, err := tx.ExecContext(ctx, refundAuthorization, reversal.Reversed, status, time.Now(), authID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// reverseWithdrawal credits reversal amount back to current and to the lots
+// it was spent from and takes it from withdrawn, authorization of the
+// withdrawal must be locked by caller.
+func reverseWithdrawal(ctx context.Context, tx *sql.Tx, number int64, reversal *models.Reversal) error {
+	var (
+		userid   int64
+		sum      float64
+		approval string
+		reversed float64
+	)
+	if err := tx.QueryRowContext(ctx, reversalWithdrawal, number).Scan(&userid, &sum, &approval); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return prjerrors.ErrOrderNotFound
+		}
+		return err
+	}
+	// held or rejected sum never reached withdrawn
+	if approval != "" && approval != models.WithdrawalApproved {
+		return prjerrors.ErrWithdrawalNotFinal
+	}
+	if err := tx.QueryRowContext(ctx, reversedSum, number).Scan(&reversed); err != nil {
+		return err
+	}
+
+	remaining := math.Round((sum-reversed)*100) / 100
+	amount := reversal.Amount
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount <= 0 || amount > remaining {
+		return prjerrors.ErrReversalAmount
+	}
+
+	if _, err := tx.ExecContext(ctx, refundWithdraw, amount, userid); err != nil {
+		return err
+	}
+	if err := balanceMove(ctx, tx, userid, models.LedgerRefund, number, amount, reversal.Merchant,
+		lotSpend{spendWithdrawal, number}); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, addReversal, number, userid, amount,
+		reversal.Author, reversal.Merchant, reversal.Reason, time.Now()); err != nil {
+		return err
+	}
+	reversal.Amount = amount
+	reversal.Reversed = math.Round((reversed+amount)*100) / 100
+	reversal.Remaining = math.Round((remaining-amount)*100) / 100
+	return nil
+}
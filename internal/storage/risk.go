@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+const (
+	// blocked valid order does not end the invalid streak, dismissed review
+	// of an order event does
+	riskProfile = "SELECT (SELECT COUNT(*) FROM risk_events WHERE (userid=$1 AND kind=$2 AND valid=false AND id > " +
+		"(SELECT COALESCE(MAX(id), 0) FROM risk_events WHERE (userid=$1 AND kind=$2 " +
+		"AND ((valid=true AND action<>$3) OR resolution=$5))))), " +
+		"(SELECT MIN(created_at) FROM balance_ledger WHERE (userid=$1 AND kind=$4)), " +
+		"COALESCE((SELECT current FROM balance WHERE userid=$1), 0)"
+	addRiskEvent = "INSERT INTO risk_events (userid, kind, number, sum, valid, action, rule, created_at) " +
+		"VALUES ($1, $2, $3, NULLIF($4, 0), $5, $6, NULLIF($7, ''), $8) RETURNING id"
+	listRiskEvents = "SELECT risk_events.id, users.login, risk_events.kind, risk_events.number, COALESCE(risk_events.sum, 0), " +
+		"risk_events.valid, risk_events.action, COALESCE(risk_events.rule, ''), risk_events.created_at, " +
+		"COALESCE(risk_events.resolution, ''), COALESCE(risk_events.reviewed_by, ''), risk_events.reviewed_at, " +
+		"COALESCE(risk_events.note, '') FROM risk_events JOIN users ON users.id=risk_events.userid " +
+		"WHERE (risk_events.action=$1 AND (risk_events.reviewed_at IS NOT NULL)=$2) " +
+		"ORDER BY risk_events.created_at DESC, risk_events.id DESC LIMIT $3 OFFSET $4"
+	lockRiskEvent   = "SELECT action, reviewed_at FROM risk_events WHERE id=$1 FOR UPDATE"
+	reviewRiskEvent = "UPDATE risk_events SET resolution=$1, reviewed_by=$2, reviewed_at=$3, note=NULLIF($4, '') WHERE id=$5"
+)
+
+// RiskProfile gives user history for risk rules.
+func (pg *PgDB) RiskProfile(ctx context.Context, userid int64, profile *models.RiskProfile) error {
+	var firstAccrual sql.NullTime
+	if err := pg.db.QueryRowContext(ctx, riskProfile, userid, models.RiskEventOrder, models.RiskBlock,
+		models.LedgerAccrual, models.RiskDismissed).Scan(&profile.InvalidStreak, &firstAccrual, &profile.Current); err != nil {
+		return err
+	}
+	profile.FirstAccrualAt = time.Time{}
+	if firstAccrual.Valid {
+		profile.FirstAccrualAt = firstAccrual.Time
+	}
+	return nil
+}
+
+// AddRiskEvent records risk engine decision.
+func (pg *PgDB) AddRiskEvent(ctx context.Context, event *models.RiskEvent) error {
+	now := time.Now()
+	if err := pg.db.QueryRowContext(ctx, addRiskEvent, event.UserID, event.Kind, event.Order, event.Sum,
+		event.Valid, event.Action, event.Rule, now).Scan(&event.ID); err != nil {
+		return err
+	}
+	event.CreatedAt = now.Format(time.RFC3339)
+	return nil
+}
+
+// ListRiskEvents gives events with action, reviewed or waiting for review,
+// newest first.
+func (pg *PgDB) ListRiskEvents(ctx context.Context, action string, reviewed bool, page models.Page, events *[]models.RiskEvent) error {
+	rows, err := pg.db.QueryContext(ctx, listRiskEvents, action, reviewed, page.Limit, page.Offset)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			event      models.RiskEvent
+			createdAt  time.Time
+			reviewedAt sql.NullTime
+		)
+		if err := rows.Scan(&event.ID, &event.Login, &event.Kind, &event.Order, &event.Sum, &event.Valid,
+			&event.Action, &event.Rule, &createdAt, &event.Resolution, &event.ReviewedBy, &reviewedAt, &event.Note); err != nil {
+			return err
+		}
+		event.CreatedAt = createdAt.Format(time.RFC3339)
+		if reviewedAt.Valid {
+			event.ReviewedAt = reviewedAt.Time.Format(time.RFC3339)
+		}
+		*events = append(*events, event)
+	}
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+	if len(*events) == 0 {
+		return prjerrors.ErrEmptyData
+	}
+	return nil
+}
+
+// ReviewRiskEvent resolves flagged or blocked event once, dismissed order
+// event ends the user invalid orders streak.
+func (pg *PgDB) ReviewRiskEvent(ctx context.Context, review *models.RiskReview) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var (
+		action     string
+		reviewedAt sql.NullTime
+	)
+	if err := tx.QueryRowContext(ctx, lockRiskEvent, review.ID).Scan(&action, &reviewedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return prjerrors.ErrRiskEventNotFound
+		}
+		return err
+	}
+	// allowed events are not subject to review
+	if action == models.RiskAllow {
+		return prjerrors.ErrRiskEventNotFound
+	}
+	if reviewedAt.Valid {
+		return prjerrors.ErrRiskEventReviewed
+	}
+	if _, err := tx.ExecContext(ctx, reviewRiskEvent, review.Resolution, review.Author, time.Now(), review.Note, review.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -2,21 +2,70 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	"github.com/sourcecd/gofermart/internal/models"
 )
 
 type Store interface {
+	Ping(ctx context.Context) error
 	CreateDatabaseScheme(ctx context.Context) error
 	InitializeSecurityKey(ctx context.Context) error
 	GetSecurityKey(ctx context.Context) (string, error)
 	RegisterUser(ctx context.Context, reg *models.User) (int64, error)
 	AuthUser(ctx context.Context, reg *models.User) (int64, error)
+	GetUserLogin(ctx context.Context, userid int64) (string, error)
 	CreateOrder(ctx context.Context, userid, orderid int64) error
+	CreateOrderReceipt(ctx context.Context, userid int64, receipt *models.AccrualOrder) error
+	OrderGoods(ctx context.Context, orderid int64) ([]models.Good, error)
 	ListOrders(ctx context.Context, userid int64, orderList *[]models.Order) error
+	OrderStatusHistory(ctx context.Context, userid, orderid int64, history *[]models.OrderStatusChange) error
 	GetBalance(ctx context.Context, userid int64, balance *models.Balance) error
+	PendingOrders(ctx context.Context, userid int64, orders *[]models.AccrualOrder) error
 	Withdraw(ctx context.Context, userid int64, withdraw *models.Withdraw) error
+	Transfer(ctx context.Context, userid int64, transfer *models.Transfer, limits models.TransferLimits) error
 	Withdrawals(ctx context.Context, userid int64, withdrawals *[]models.Withdrawals) error
+	ListWithdrawalApprovals(ctx context.Context, status string, approvals *[]models.WithdrawalApproval) error
+	DecideWithdrawal(ctx context.Context, decision *models.WithdrawalDecision) error
+	ReverseWithdrawal(ctx context.Context, reversal *models.Reversal) error
+	GetUserWithdrawalLimits(ctx context.Context, login string, limits *models.UserWithdrawalLimits) error
+	SetUserWithdrawalLimits(ctx context.Context, limits *models.UserWithdrawalLimits) error
+	DeleteUserWithdrawalLimits(ctx context.Context, login string) error
+	RiskProfile(ctx context.Context, userid int64, profile *models.RiskProfile) error
+	AddRiskEvent(ctx context.Context, event *models.RiskEvent) error
+	ListRiskEvents(ctx context.Context, action string, reviewed bool, page models.Page, events *[]models.RiskEvent) error
+	ReviewRiskEvent(ctx context.Context, review *models.RiskReview) error
+	CreateVoucherBatch(ctx context.Context, batch *models.VoucherBatch) error
+	GetVoucher(ctx context.Context, code string, voucher *models.Voucher) error
+	RedeemVoucher(ctx context.Context, userid int64, redemption *models.VoucherRedemption) error
+	CreatePaymentToken(ctx context.Context, userid int64, token *models.PaymentToken) error
+	Authorize(ctx context.Context, auth *models.Authorization) error
+	GetAuthorization(ctx context.Context, merchant string, id int64, auth *models.Authorization) error
+	CaptureAuthorization(ctx context.Context, op *models.AuthorizationOp, auth *models.Authorization) error
+	VoidAuthorization(ctx context.Context, op *models.AuthorizationOp, auth *models.Authorization) error
+	RefundAuthorization(ctx context.Context, op *models.AuthorizationOp, auth *models.Authorization) error
+	ExpireAuthorizations(ctx context.Context) (int64, error)
 	AccrualSystemPoll(ctx context.Context, orders *[]int64) error
 	AccrualSystemSave(ctx context.Context, accrual []models.Accrual) error
+	AccrualPollAttempts(ctx context.Context, attempts []models.PollAttempt) error
+	AccrualDeadLetter(ctx context.Context, maxAge time.Duration, maxAttempts int) (int64, error)
+	ListDeadLetters(ctx context.Context, orders *[]models.DeadLetterOrder) error
+	RequeueDeadLetter(ctx context.Context, number int64) error
+	CreateRewardRule(ctx context.Context, rule *models.RewardRule) error
+	SetRewardRuleStatus(ctx context.Context, id int64, status string) error
+	ListRewardRules(ctx context.Context, rules *[]models.RewardRule) error
+	CreateCampaign(ctx context.Context, campaign *models.Campaign) error
+	ListCampaigns(ctx context.Context, campaigns *[]models.Campaign) error
+	GetCampaign(ctx context.Context, id int64, campaign *models.Campaign) error
+	UpdateCampaign(ctx context.Context, campaign *models.Campaign) error
+	DeleteCampaign(ctx context.Context, id int64) error
+	BalanceHistory(ctx context.Context, userid int64, page models.Page, entries *[]models.BalanceEntry) error
+	BalanceAsOf(ctx context.Context, userid int64, date time.Time, balance *models.BalanceAsOf) error
+	Statement(ctx context.Context, userid int64, period models.Period, line func(line *models.BalanceEntry) error) error
+	ExpirePoints(ctx context.Context) (int64, error)
+	RecalculateTiers(ctx context.Context, policy *models.TierPolicy) (int64, error)
+	GetUserTier(ctx context.Context, userid int64, policy *models.TierPolicy, tier *models.UserTier) error
+	GetUserID(ctx context.Context, login string) (int64, error)
+	GetReferral(ctx context.Context, userid int64, referral *models.Referral) error
+	OverrideOrder(ctx context.Context, override *models.OrderOverride) error
 }
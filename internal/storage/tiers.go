@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/sourcecd/gofermart/internal/models"
+)
+
+const (
+	userMultiplier = "SELECT multiplier FROM user_tiers WHERE userid=$1"
+	getUserTier    = "SELECT tier, multiplier, updated_at FROM user_tiers WHERE userid=$1"
+	userTierTotal  = "SELECT COALESCE(SUM(amount), 0) FROM balance_ledger WHERE (userid=$1 AND kind=$2 AND created_at >= $3)"
+	// settled withdrawals and merchant captures net of reversals, pending
+	// and rejected holds are not spent
+	spentWithdrawals = "SELECT orders.userid, orders.processed_at, orders.sum - COALESCE(SUM(withdrawal_reversals.amount), 0) AS net " +
+		"FROM orders LEFT JOIN withdrawal_approvals ON withdrawal_approvals.number=orders.number " +
+		"LEFT JOIN withdrawal_reversals ON withdrawal_reversals.number=orders.number " +
+		"WHERE (orders.processable=false AND COALESCE(withdrawal_approvals.status, '') NOT IN ('" +
+		models.WithdrawalPendingApproval + "', '" + models.WithdrawalRejected + "')) " +
+		"GROUP BY orders.number, orders.userid, orders.processed_at, orders.sum"
+	userTierSpent = "SELECT COALESCE(SUM(net), 0) FROM (" + spentWithdrawals + ") spent WHERE (userid=$1 AND processed_at >= $2)"
+	tierTotals    = "SELECT users.id, COALESCE(SUM(balance_ledger.amount), 0) FROM users " +
+		"LEFT JOIN balance_ledger ON (balance_ledger.userid=users.id AND balance_ledger.kind=$1 AND balance_ledger.created_at >= $2) " +
+		"GROUP BY users.id"
+	tierSpentTotals = "SELECT users.id, COALESCE(SUM(spent.net), 0) FROM users " +
+		"LEFT JOIN (" + spentWithdrawals + ") spent ON (spent.userid=users.id AND spent.processed_at >= $1) GROUP BY users.id"
+	upsertUserTier = "INSERT INTO user_tiers (userid, tier, multiplier, total, updated_at) VALUES ($1, $2, $3, $4, $5) " +
+		"ON CONFLICT (userid) DO UPDATE SET tier=$2, multiplier=$3, total=$4, updated_at=$5"
+)
+
+// SetTierPolicy sets loyalty tiers policy, nil disables tier bonuses and
+// tier matching of campaigns and withdrawal limits.
+func (pg *PgDB) SetTierPolicy(policy *models.TierPolicy) {
+	pg.tiers = policy
+}
+
+// tierBonus credits accrual part over the user tier multiplier as separate
+// ledger entry, users without tier get nothing.
+func tierBonus(ctx context.Context, tx *sql.Tx, userid, number int64, accrual float64) error {
+	var multiplier float64
+	if err := tx.QueryRowContext(ctx, userMultiplier, userid).Scan(&multiplier); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	bonus := math.Round(accrual*(multiplier-1)*100) / 100
+	if bonus <= 0 {
+		return nil
+	}
+	return balanceAdd(ctx, tx, userid, models.LedgerTierBonus, number, bonus, "")
+}
+
+// RecalculateTiers sets every user tier by rolling total of the policy,
+// returns number of users recalculated.
+func (pg *PgDB) RecalculateTiers(ctx context.Context, policy *models.TierPolicy) (int64, error) {
+	now := time.Now()
+	type total struct {
+		userid int64
+		sum    float64
+	}
+	var totals []total
+	var (
+		rows *sql.Rows
+		err  error
+	)
+	if policy.Basis == models.TierBasisSpent {
+		rows, err = pg.db.QueryContext(ctx, tierSpentTotals, now.Add(-policy.Window))
+	} else {
+		rows, err = pg.db.QueryContext(ctx, tierTotals, models.LedgerAccrual, now.Add(-policy.Window))
+	}
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var t total
+		if err := rows.Scan(&t.userid, &t.sum); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		totals = append(totals, t)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return 0, rows.Err()
+	}
+
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	for _, t := range totals {
+		name, multiplier := "", 1.0
+		if current, _ := policy.Tier(t.sum); current != nil {
+			name, multiplier = current.Name, current.Multiplier
+		}
+		if _, err := tx.ExecContext(ctx, upsertUserTier, t.userid, name, multiplier, t.sum, now); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(totals)), tx.Commit()
+}
+
+// GetUserTier gives applied user tier with live rolling total and progress
+// to the next tier.
+func (pg *PgDB) GetUserTier(ctx context.Context, userid int64, policy *models.TierPolicy, tier *models.UserTier) error {
+	var updatedAt time.Time
+	tier.Multiplier = 1
+	if err := pg.db.QueryRowContext(ctx, getUserTier, userid).Scan(&tier.Tier, &tier.Multiplier, &updatedAt); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+	} else {
+		tier.UpdatedAt = updatedAt.Format(time.RFC3339)
+	}
+
+	since := time.Now().Add(-policy.Window)
+	var row *sql.Row
+	if policy.Basis == models.TierBasisSpent {
+		row = pg.db.QueryRowContext(ctx, userTierSpent, userid, since)
+	} else {
+		row = pg.db.QueryRowContext(ctx, userTierTotal, userid, models.LedgerAccrual, since)
+	}
+	if err := row.Scan(&tier.Total); err != nil {
+		return err
+	}
+	tier.Basis = policy.Basis
+	if _, next := policy.Tier(tier.Total); next != nil {
+		tier.NextTier = next.Name
+		tier.NextThreshold = next.Threshold
+		tier.Remaining = next.Threshold - tier.Total
+	}
+	return nil
+}
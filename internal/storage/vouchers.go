@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sourcecd/gofermart/internal/crypto"
+	"github.com/sourcecd/gofermart/internal/models"
+	"github.com/sourcecd/gofermart/internal/prjerrors"
+)
+
+const (
+	addVoucherBatch = "INSERT INTO voucher_batches (points, max_redemptions, expires_at, author, created_at) " +
+		"VALUES ($1, $2, $3, $4, $5) RETURNING id"
+	addVoucher = "INSERT INTO vouchers (code, batch_id) VALUES ($1, $2)"
+	getVoucher = "SELECT vouchers.code, vouchers.batch_id, voucher_batches.points, voucher_batches.max_redemptions, " +
+		"vouchers.redeemed, voucher_batches.expires_at, voucher_batches.created_at FROM vouchers " +
+		"JOIN voucher_batches ON voucher_batches.id=vouchers.batch_id WHERE vouchers.code=$1"
+	lockVoucher = "SELECT vouchers.redeemed, voucher_batches.points, voucher_batches.max_redemptions, voucher_batches.expires_at " +
+		"FROM vouchers JOIN voucher_batches ON voucher_batches.id=vouchers.batch_id WHERE vouchers.code=$1 FOR UPDATE OF vouchers"
+	addRedemption  = "INSERT INTO voucher_redemptions (code, userid, points, created_at) VALUES ($1, $2, $3, $4)"
+	redeemVoucher  = "UPDATE vouchers SET redeemed=(redeemed + 1) WHERE code=$1"
+	voucherCurrent = "SELECT current FROM balance WHERE userid=$1"
+)
+
+// CreateVoucherBatch generates batch codes in one tx, either all codes are
+// issued or none.
+func (pg *PgDB) CreateVoucherBatch(ctx context.Context, batch *models.VoucherBatch) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var expiresAt sql.NullTime
+	if batch.ExpiresAt != nil {
+		expiresAt = sql.NullTime{Time: *batch.ExpiresAt, Valid: true}
+	}
+	now := time.Now()
+	if err := tx.QueryRowContext(ctx, addVoucherBatch, batch.Points, batch.MaxRedemptions, expiresAt,
+		batch.Author, now).Scan(&batch.ID); err != nil {
+		return err
+	}
+	codes := make([]string, 0, batch.Count)
+	for i := 0; i < batch.Count; i++ {
+		code, err := crypto.GenerateVoucherCode()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, addVoucher, code, batch.ID); err != nil {
+			return err
+		}
+		codes = append(codes, code)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	batch.Codes = codes
+	batch.CreatedAt = now.Format(time.RFC3339)
+	return nil
+}
+
+func (pg *PgDB) GetVoucher(ctx context.Context, code string, voucher *models.Voucher) error {
+	var (
+		expiresAt sql.NullTime
+		createdAt time.Time
+	)
+	if err := pg.db.QueryRowContext(ctx, getVoucher, code).Scan(&voucher.Code, &voucher.BatchID, &voucher.Points,
+		&voucher.MaxRedemptions, &voucher.Redeemed, &expiresAt, &createdAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return prjerrors.ErrVoucherNotFound
+		}
+		return err
+	}
+	voucher.ExpiresAt = ""
+	if expiresAt.Valid {
+		voucher.ExpiresAt = expiresAt.Time.Format(time.RFC3339)
+	}
+	voucher.CreatedAt = createdAt.Format(time.RFC3339)
+	return nil
+}
+
+// RedeemVoucher credits voucher points to the user. The voucher row lock
+// keeps concurrent redemptions within the cap, every user redeems a code
+// once.
+func (pg *PgDB) RedeemVoucher(ctx context.Context, userid int64, redemption *models.VoucherRedemption) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var (
+		redeemed       int
+		points         float64
+		maxRedemptions int
+		expiresAt      sql.NullTime
+	)
+	if err := tx.QueryRowContext(ctx, lockVoucher, redemption.Code).Scan(&redeemed, &points,
+		&maxRedemptions, &expiresAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return prjerrors.ErrVoucherNotFound
+		}
+		return err
+	}
+	now := time.Now()
+	if expiresAt.Valid && !expiresAt.Time.After(now) {
+		return prjerrors.ErrVoucherExpired
+	}
+	if redeemed >= maxRedemptions {
+		return prjerrors.ErrVoucherRedeemed
+	}
+
+	if _, err := tx.ExecContext(ctx, addRedemption, redemption.Code, userid, points, now); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
+			return prjerrors.ErrVoucherRedeemed
+		}
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, redeemVoucher, redemption.Code); err != nil {
+		return err
+	}
+	if err := balanceAdd(ctx, tx, userid, models.LedgerVoucher, 0, points, ""); err != nil {
+		return err
+	}
+	if err := tx.QueryRowContext(ctx, voucherCurrent, userid).Scan(&redemption.Current); err != nil {
+		return err
+	}
+	redemption.Points = points
+	return tx.Commit()
+}